@@ -0,0 +1,184 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"os"
+
+	"tuxedo-core/compiler"
+	"tuxedo-core/config"
+	"tuxedo-core/services"
+	"tuxedo-core/validation"
+)
+
+// runCommand runs a command-line subcommand instead of starting the server
+// and returns the process exit code
+func runCommand(cfg *config.Config, args []string) int {
+	switch args[0] {
+	case "compile":
+		return compileCommand(cfg, args[1:])
+	case "validate":
+		return validateCommand(cfg, args[1:])
+	case "asset-report":
+		return assetReportCommand(cfg)
+	default:
+		fmt.Fprintf(os.Stderr, "Unknown command %q\n", args[0])
+		fmt.Fprintln(os.Stderr, "Usage: tuxedo-core [compile [scene...] | validate [scene...] | asset-report]")
+		return 2
+	}
+}
+
+// indexedScenes indexes the project once for a command, without the cache
+// file, and returns a scene service answering from that index
+func indexedScenes(cfg *config.Config) (*services.SceneService, *services.ProjectIndex, error) {
+	index := services.NewProjectIndex(cfg.GetScenesPath(), cfg.GetAssetsPath(), "")
+	if err := index.Build(); err != nil {
+		return nil, nil, err
+	}
+	scenes := services.NewSceneService(cfg.GetScenesPath())
+	scenes.UseIndex(index)
+	return scenes, index, nil
+}
+
+// compileCommand compiles the named scenes, or every scene when none are given
+func compileCommand(cfg *config.Config, names []string) int {
+	scenes, _, err := indexedScenes(cfg)
+	if err != nil {
+		log.Printf("Compile failed: %v", err)
+		return 1
+	}
+	c := compiler.New(scenes, compiler.Options{
+		ComponentsModule: cfg.Compiler.ComponentsModule,
+	})
+
+	if len(names) == 0 {
+		results, err := c.CompileAll()
+		for _, result := range results {
+			log.Printf("Compiled %s -> %s", result.Scene, result.Output)
+		}
+		if err != nil {
+			log.Printf("Compile failed:\n%v", err)
+			return 1
+		}
+		return 0
+	}
+
+	status := 0
+	for _, name := range names {
+		result, err := c.Compile(name)
+		if err != nil {
+			log.Printf("Compile failed: %s: %v", name, err)
+			status = 1
+			continue
+		}
+		log.Printf("Compiled %s -> %s", result.Scene, result.Output)
+	}
+	return status
+}
+
+// validateCommand validates the named scenes, or every scene when none are
+// given, printing one line per diagnostic. It fails when any error is found
+// so it can gate CI builds.
+func validateCommand(cfg *config.Config, names []string) int {
+	scenes, index, err := indexedScenes(cfg)
+	if err != nil {
+		log.Printf("Validate failed: %v", err)
+		return 1
+	}
+	textures := services.NewTextureIndex(index, cfg.Project.YukonPath).Textures()
+	components := services.NewComponentRegistry(cfg.GetComponentsPath())
+	if err := components.Load(); err != nil {
+		log.Printf("Validate failed: %v", err)
+		return 1
+	}
+	project, err := validation.LoadProject(scenes, cfg.Project.YukonPath, textures, components)
+	if err != nil {
+		log.Printf("Validate failed: %v", err)
+		return 1
+	}
+
+	report := &validation.Report{}
+	if len(names) == 0 {
+		if report, err = validation.ValidateProject(scenes, project); err != nil {
+			log.Printf("Validate failed: %v", err)
+			return 1
+		}
+	}
+	for _, name := range names {
+		sceneReport, err := validation.ValidateScene(scenes, project, name)
+		if err != nil {
+			log.Printf("Validate failed: %s: %v", name, err)
+			return 1
+		}
+		report.Scenes += sceneReport.Scenes
+		report.Errors += sceneReport.Errors
+		report.Warnings += sceneReport.Warnings
+		report.Diagnostics = append(report.Diagnostics, sceneReport.Diagnostics...)
+	}
+
+	for _, d := range report.Diagnostics {
+		fmt.Printf("%s: %s %s %s: %s\n", d.Scene, d.Severity, d.Path, d.Code, d.Message)
+	}
+	fmt.Printf("%d scenes, %d errors, %d warnings\n", report.Scenes, report.Errors, report.Warnings)
+
+	if report.Errors > 0 {
+		return 1
+	}
+	return 0
+}
+
+// assetReportCommand prints the missing asset references of every scene and
+// the assets nothing uses. Like validate, it fails when a reference is
+// missing.
+func assetReportCommand(cfg *config.Config) int {
+	scenes, index, err := indexedScenes(cfg)
+	if err != nil {
+		log.Printf("Asset report failed: %v", err)
+		return 1
+	}
+	textures := services.NewTextureIndex(index, cfg.Project.YukonPath)
+
+	report, err := services.BuildAssetReport(scenes, index, textures.Textures())
+	if err != nil {
+		log.Printf("Asset report failed: %v", err)
+		return 1
+	}
+
+	for _, m := range report.Missing {
+		if m.ObjectID != "" {
+			fmt.Printf("%s: missing %s %s: %s\n", m.Scene, m.Kind, m.ObjectID, m.Message)
+		} else {
+			fmt.Printf("%s: missing %s: %s\n", m.Scene, m.Kind, m.Message)
+		}
+	}
+	for _, u := range report.Unused {
+		name := u.Path
+		if u.Key != "" {
+			name = u.Key
+		}
+		fmt.Printf("unused %s %s: %d files, %s\n", u.Kind, name, len(u.Files), formatBytes(u.Bytes))
+	}
+	fmt.Printf("%d scenes, %d missing, %d unused files (%s of %s)\n", report.Scenes, len(report.Missing),
+		report.UnusedFiles, formatBytes(report.UnusedBytes), formatBytes(report.AssetBytes))
+
+	if len(report.Missing) > 0 {
+		return 1
+	}
+	return 0
+}
+
+// formatBytes writes a byte count in KB, MB or GB
+func formatBytes(n int64) string {
+	const unit = 1024
+	if n < unit {
+		return fmt.Sprintf("%d B", n)
+	}
+	value, suffix := float64(n)/unit, "KB"
+	for _, next := range []string{"MB", "GB"} {
+		if value < unit {
+			break
+		}
+		value, suffix = value/unit, next
+	}
+	return fmt.Sprintf("%.1f %s", value, suffix)
+}
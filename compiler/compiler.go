@@ -0,0 +1,178 @@
+// Package compiler turns .scene files into the JavaScript scene and prefab
+// classes Yukon loads at runtime, in the same layout Phaser Editor produces.
+package compiler
+
+import (
+	"errors"
+	"fmt"
+	"path"
+	"path/filepath"
+
+	"tuxedo-core/models"
+	"tuxedo-core/services"
+)
+
+const defaultComponentsModule = "@components/components"
+
+// ErrInvalidScene is wrapped by errors caused by the scene content, such as
+// an instance of a prefab that does not exist
+var ErrInvalidScene = errors.New("invalid scene")
+
+// Options controls code generation details that are not stored in scenes
+type Options struct {
+	// ComponentsModule is the import path of Button, MoveTo and the other user components
+	ComponentsModule string
+}
+
+// Compiler generates JavaScript classes for the scenes under a scenes directory
+type Compiler struct {
+	scenesPath string
+	scenes     *services.SceneService
+	options    Options
+}
+
+// Result describes the output of compiling one scene
+type Result struct {
+	Scene  string `json:"scene"`
+	Output string `json:"output"` // Output file relative to the scenes directory
+	Code   string `json:"-"`
+}
+
+// New returns a compiler reading scenes through the scene service, whose
+// index, when it uses one, lists the scenes and prefabs
+func New(scenes *services.SceneService, options Options) *Compiler {
+	if options.ComponentsModule == "" {
+		options.ComponentsModule = defaultComponentsModule
+	}
+	return &Compiler{
+		scenesPath: scenes.Root(),
+		scenes:     scenes,
+		options:    options,
+	}
+}
+
+// Compile generates the class for one scene, e.g. rooms/town/Town, and
+// writes it next to the .scene file, keeping any user code regions
+func (c *Compiler) Compile(name string) (*Result, error) {
+	prefabs, err := c.loadPrefabs()
+	if err != nil {
+		return nil, err
+	}
+	return c.compile(name, prefabs)
+}
+
+// CompileAll compiles every scene in the project. Scenes that fail are
+// reported in the returned error without stopping the others.
+func (c *Compiler) CompileAll() ([]*Result, error) {
+	prefabs, err := c.loadPrefabs()
+	if err != nil {
+		return nil, err
+	}
+
+	names, err := c.scenes.SceneNames()
+	if err != nil {
+		return nil, err
+	}
+
+	var results []*Result
+	var errs []error
+	for _, name := range names {
+		result, err := c.compile(name, prefabs)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", name, err))
+			continue
+		}
+		results = append(results, result)
+	}
+
+	return results, errors.Join(errs...)
+}
+
+func (c *Compiler) compile(name string, prefabs *prefabTable) (*Result, error) {
+	scene, err := c.scenes.LoadScene(filepath.FromSlash(name))
+	if err != nil {
+		return nil, err
+	}
+
+	if lang := settingString(scene, "compilerOutputLanguage", "JAVASCRIPT"); lang != "JAVASCRIPT" {
+		return nil, fmt.Errorf("%w: unsupported output language %q", ErrInvalidScene, lang)
+	}
+
+	output := name + ".js"
+	outputPath := filepath.Join(c.scenesPath, filepath.FromSlash(output))
+
+	user, err := readUserCode(outputPath)
+	if err != nil {
+		return nil, err
+	}
+
+	objects, err := scene.TypedObjects()
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrInvalidScene, err)
+	}
+
+	g := newGenerator(c, name, scene, objects, prefabs, user)
+	code, err := g.generate()
+	if err != nil {
+		return nil, err
+	}
+
+	if err := services.WriteFileAtomic(outputPath, []byte(code), 0644); err != nil {
+		return nil, err
+	}
+
+	return &Result{Scene: name, Output: output, Code: code}, nil
+}
+
+// prefabTable resolves the prefabs instances refer to, as the scene
+// service expands them, and lists the names of every prefab
+type prefabTable struct {
+	resolver *services.PrefabResolver
+	names    []string
+}
+
+func (c *Compiler) loadPrefabs() (*prefabTable, error) {
+	prefabs, err := c.scenes.Prefabs()
+	if err != nil {
+		return nil, err
+	}
+
+	table := &prefabTable{resolver: c.scenes.NewPrefabResolver()}
+	for _, name := range prefabs {
+		table.names = append(table.names, name)
+	}
+	return table, nil
+}
+
+// resolve returns the prefab with the ID. Missing prefabs and prefab
+// cycles are errors of the scene being compiled.
+func (t *prefabTable) resolve(id string) (*services.ResolvedPrefab, error) {
+	prefab, err := t.resolver.Resolve(id)
+	if errors.Is(err, services.ErrPrefabNotFound) || errors.Is(err, services.ErrPrefabCycle) {
+		return nil, fmt.Errorf("%w: %w", ErrInvalidScene, err)
+	}
+	return prefab, err
+}
+
+// className is the JavaScript class generated for a scene name
+func className(name string) string {
+	return identifier(path.Base(name))
+}
+
+// settingString reads a string setting Phaser Editor stores on the scene
+func settingString(scene *models.Scene, key, fallback string) string {
+	var value string
+	if ok, err := scene.Settings.Extra.Decode(key, &value); !ok || err != nil || value == "" {
+		return fallback
+	}
+	return value
+}
+
+// settingBool reads a boolean setting Phaser Editor stores on the scene
+func settingBool(scene *models.Scene, key string, fallback bool) bool {
+	var value bool
+	if ok, err := scene.Settings.Extra.Decode(key, &value); !ok || err != nil {
+		return fallback
+	}
+	return value
+}
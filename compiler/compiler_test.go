@@ -0,0 +1,93 @@
+package compiler
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"tuxedo-core/services"
+)
+
+// newTestCompiler writes the scenes, keyed by name, into a temporary
+// scenes folder and returns a compiler for it
+func newTestCompiler(t *testing.T, scenes map[string]string) *Compiler {
+	t.Helper()
+	root := t.TempDir()
+	for name, scene := range scenes {
+		path := filepath.Join(root, filepath.FromSlash(name)+".scene")
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(scene), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return New(services.NewSceneService(root), Options{})
+}
+
+func prefabScene(id, key, root string) string {
+	return `{"id": "` + id + `", "sceneType": "PREFAB", "settings": {"sceneKey": "` + key + `"}, "displayList": [` + root + `]}`
+}
+
+func assertContains(t *testing.T, code string, want ...string) {
+	t.Helper()
+	for _, line := range want {
+		if !strings.Contains(code, line) {
+			t.Errorf("generated code has no %q:\n%s", line, code)
+		}
+	}
+}
+
+func TestCompileAliasesPrefabsWithTheSameName(t *testing.T) {
+	c := newTestCompiler(t, map[string]string{
+		"a/Button": prefabScene("p-a", "ButtonA", `{"type": "Image", "id": "a1", "label": "button", "texture": {"key": "ui", "frame": "a"}}`),
+		"b/Button": prefabScene("p-b", "ButtonB", `{"type": "Container", "id": "b1", "label": "button"}`),
+		"rooms/Town": `{"id": "s-town", "sceneType": "SCENE", "settings": {"sceneKey": "Town"}, "displayList": [
+			{"prefabId": "p-a", "id": "i1", "label": "first", "x": 1, "y": 2},
+			{"prefabId": "p-b", "id": "i2", "label": "second", "x": 3, "y": 4},
+			{"prefabId": "p-a", "id": "i3", "label": "third", "x": 5, "y": 6}
+		]}`,
+	})
+
+	result, err := c.Compile("rooms/Town")
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertContains(t, result.Code,
+		`import Button from "../a/Button";`,
+		`import Button2 from "../b/Button";`,
+		`const first = new Button(this, 1, 2);`,
+		`const second = new Button2(this, 3, 4);`,
+		`const third = new Button(this, 5, 6);`,
+	)
+}
+
+func TestCompileAliasesPrefabsNamedLikeTheClass(t *testing.T) {
+	c := newTestCompiler(t, map[string]string{
+		"prefabs/Sign": prefabScene("p-sign", "Sign", `{"type": "Image", "id": "s1", "label": "sign", "texture": {"key": "town", "frame": "sign"}}`),
+		"variants/Sign": prefabScene("p-variant", "SignVariant", `{"prefabId": "p-sign", "id": "v1", "label": "sign"}`),
+		"rooms/Sign": `{"id": "s-room", "sceneType": "SCENE", "settings": {"sceneKey": "Sign"}, "displayList": [
+			{"prefabId": "p-sign", "id": "i1", "label": "sign", "x": 1, "y": 2}
+		]}`,
+	})
+
+	room, err := c.Compile("rooms/Sign")
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertContains(t, room.Code,
+		`import Sign2 from "../prefabs/Sign";`,
+		`export default class Sign extends Phaser.Scene {`,
+		`const sign = new Sign2(this, 1, 2);`,
+	)
+
+	variant, err := c.Compile("variants/Sign")
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertContains(t, variant.Code,
+		`import Sign2 from "../prefabs/Sign";`,
+		`export default class Sign extends Sign2 {`,
+	)
+}
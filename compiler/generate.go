@@ -0,0 +1,673 @@
+package compiler
+
+import (
+	"fmt"
+	"path"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+
+	"tuxedo-core/models"
+)
+
+// expressionProperties are component properties holding JavaScript code
+// rather than string values
+var expressionProperties = map[string]bool{
+	"Button.callback":               true,
+	"SimpleButton.callback":         true,
+	"SimpleButton.hoverCallback":    true,
+	"SimpleButton.hoverOutCallback": true,
+}
+
+// assignedProperties are undeclared object properties compiled to plain assignments
+var assignedProperties = []string{"alpha", "flipX", "flipY"}
+
+// Object scopes that turn an object into a class field
+const (
+	scopeClass  = "CLASS"
+	scopePublic = "PUBLIC"
+)
+
+type classField struct {
+	name   string
+	jsType string
+}
+
+// generator builds the class for a single scene
+type generator struct {
+	c       *Compiler
+	name    string
+	scene   *models.Scene
+	objects models.Objects // The scene's display list
+	prefabs *prefabTable
+	user    userCode
+	indent  string
+
+	sceneRef  string          // expression for the Phaser.Scene inside generated methods
+	names     map[string]bool // identifiers in use in the current method
+	vars      map[string]string
+	listed    map[string]bool // object IDs referenced by an object list
+	fields    []classField
+	prefabImp map[string]string // class name -> import path
+	prefabCls map[string]string // prefab ID -> imported class name
+	compImp   map[string]bool
+	compNames map[string]bool // components used anywhere in the scene
+}
+
+func newGenerator(c *Compiler, name string, scene *models.Scene, objects models.Objects, prefabs *prefabTable, user userCode) *generator {
+	indent := "\t"
+	if settingBool(scene, "compilerInsertSpaces", false) {
+		size := 4
+		scene.Settings.Extra.Decode("compilerTabSize", &size)
+		indent = strings.Repeat(" ", size)
+	}
+
+	g := &generator{
+		c:         c,
+		name:      name,
+		scene:     scene,
+		objects:   objects,
+		prefabs:   prefabs,
+		user:      user,
+		indent:    indent,
+		names:     map[string]bool{},
+		vars:      map[string]string{},
+		listed:    map[string]bool{},
+		prefabImp: map[string]string{},
+		prefabCls: map[string]string{},
+		compNames: map[string]bool{},
+		compImp:   map[string]bool{},
+	}
+	for _, list := range scene.Lists {
+		for _, id := range list.ObjectIDs {
+			g.listed[id] = true
+		}
+	}
+
+	// Class names must never be shadowed by object variables
+	g.names[className(name)] = true
+	for _, prefab := range prefabs.names {
+		g.names[className(prefab)] = true
+	}
+	models.WalkTyped(objects, func(obj models.Object) {
+		for _, component := range obj.Base().Components {
+			g.names[component] = true
+			g.compNames[component] = true
+		}
+	})
+	return g
+}
+
+func (g *generator) generate() (string, error) {
+	if g.scene.SceneType == "PREFAB" {
+		return g.generatePrefab()
+	}
+	return g.generateScene()
+}
+
+func (g *generator) generateScene() (string, error) {
+	g.sceneRef = "this"
+	g.names["this"] = true
+
+	create := newCodeWriter(g.indent)
+	create.depth = 2
+	for _, obj := range g.objects {
+		if _, err := g.object(create, obj, ""); err != nil {
+			return "", err
+		}
+	}
+	g.lists(create)
+	g.assignFields(create)
+	create.blank()
+	create.line(`this.events.emit("scene-awake");`)
+
+	w := g.beginFile()
+	g.classHeader(w, settingString(g.scene, "superClassName", "Phaser.Scene"))
+
+	w.open("constructor() {")
+	w.line("super(%s);", jsString(g.scene.Settings.SceneKey))
+	w.blank()
+	g.declareFields(w)
+	w.region(regionCtrCode, g.user, "// Write your code here.")
+	w.close("}")
+	w.blank()
+
+	if len(g.scene.Settings.PreloadPacks) > 0 {
+		w.line("/** @returns {void} */")
+		w.open("%s() {", settingString(g.scene, "preloadMethodName", "_preload"))
+		w.blank()
+		for _, pack := range g.scene.Settings.PreloadPacks {
+			key := strings.TrimSuffix(path.Base(pack), ".json")
+			w.line("this.load.pack(%s, %s);", jsString(key), jsString(pack))
+		}
+		w.close("}")
+		w.blank()
+	}
+
+	w.line("/** @returns {void} */")
+	w.open("%s() {", settingString(g.scene, "createMethodName", "_create"))
+	w.raw(create.String())
+	w.close("}")
+	w.blank()
+
+	return g.endFile(w), nil
+}
+
+func (g *generator) generatePrefab() (string, error) {
+	if len(g.objects) == 0 {
+		return "", fmt.Errorf("%w: prefab has no root object", ErrInvalidScene)
+	}
+	root := g.objects[0]
+
+	g.sceneRef = "scene"
+	for _, reserved := range []string{"this", "scene", "x", "y", "texture", "frame"} {
+		g.names[reserved] = true
+	}
+
+	superClass, typed, err := g.prefabBase(root)
+	if err != nil {
+		return "", err
+	}
+	superClass = settingString(g.scene, "superClassName", superClass)
+
+	params, superArgs := prefabConstructor(typed)
+
+	body := newCodeWriter(g.indent)
+	body.depth = 2
+	if err := g.rootObject(body, typed); err != nil {
+		return "", err
+	}
+	for _, obj := range models.Children(root) {
+		child, err := g.object(body, obj, "this")
+		if err != nil {
+			return "", err
+		}
+		if child != "" {
+			body.line("this.add(%s);", child)
+		}
+	}
+	g.lists(body)
+	g.assignFields(body)
+
+	w := g.beginFile()
+	g.classHeader(w, superClass)
+
+	w.open("constructor(%s) {", params)
+	w.line("super(%s);", superArgs)
+	w.raw(body.String())
+	w.blank()
+	w.region(regionCtrCode, g.user, "// Write your code here.")
+	w.close("}")
+	w.blank()
+
+	return g.endFile(w), nil
+}
+
+// prefabBase returns the class a prefab extends and the root object the
+// prefab class is built from. The root of a variant is an instance of the
+// prefab it is based on, viewed as that prefab's root type.
+func (g *generator) prefabBase(root models.Object) (string, models.Object, error) {
+	instance, ok := root.(*models.Instance)
+	if !ok {
+		factory, ok := factoryOf(root)
+		if !ok {
+			return "", nil, fmt.Errorf("%w: unsupported prefab root type %q", ErrInvalidScene, root.Base().Type)
+		}
+		return factory.jsType, root, nil
+	}
+	return g.instance(instance)
+}
+
+// prefabConstructor returns the constructor parameters and super call
+// arguments for a prefab class built from root
+func prefabConstructor(root models.Object) (string, string) {
+	if _, ok := root.(*models.Layer); ok {
+		return "scene", "scene"
+	}
+
+	base := root.Base()
+	variant := base.PrefabId != ""
+	var x, y string
+	if t := objectTransform(root); t != nil {
+		x = "x ?? " + jsNumber(t.X)
+		y = "y ?? " + jsNumber(t.Y)
+	}
+
+	if texture, ok := objectTexture(root); ok {
+		key, frame := "", ""
+		if texture != nil {
+			key, frame = texture.Key, texture.Frame
+		}
+		textureArg := "texture || " + jsString(key)
+		frameArg := "frame"
+		if frame != "" {
+			frameArg = "frame ?? " + jsString(frame)
+		}
+		if variant && !slices.Contains(base.Unlock, "texture") {
+			textureArg, frameArg = "texture", "frame"
+		}
+		if variant {
+			return "scene, x, y, texture, frame", strings.Join([]string{"scene", x, y, textureArg, frameArg}, ", ")
+		}
+		args := creationArgs(root, x, y, []string{textureArg, frameArg})
+		return "scene, x, y, texture, frame", strings.Join(append([]string{"scene"}, args...), ", ")
+	}
+
+	if variant {
+		return "scene, x, y", strings.Join([]string{"scene", x, y}, ", ")
+	}
+	return "scene, x, y", strings.Join(append([]string{"scene"}, creationArgs(root, x, y, nil)...), ", ")
+}
+
+// rootObject emits the properties and components of a prefab root, which
+// is the class instance itself
+func (g *generator) rootObject(w *codeWriter, root models.Object) error {
+	statements := g.properties(root, "this")
+	if len(statements) > 0 {
+		w.blank()
+		for _, s := range statements {
+			w.line("%s", s)
+		}
+	}
+	g.components(w, root.Base(), "this", root.Base().Label)
+	return nil
+}
+
+// instance returns the class of a prefab instance and the instance viewed
+// as an object of its prefab's root type
+func (g *generator) instance(obj *models.Instance) (string, models.Object, error) {
+	prefab, err := g.prefabs.resolve(obj.PrefabId)
+	if err != nil {
+		return "", nil, err
+	}
+	class, err := g.importPrefab(obj.PrefabId)
+	if err != nil {
+		return "", nil, err
+	}
+	view, err := obj.As(prefab.Root.Type)
+	if err != nil {
+		return "", nil, fmt.Errorf("%w: object %s: %w", ErrInvalidScene, obj.ID, err)
+	}
+	return class, view, nil
+}
+
+// object emits the code creating obj and returns the variable bound to it,
+// or "" when the object is created by a bare statement. parent is the
+// container expression the object is added to, "" for the scene itself.
+func (g *generator) object(w *codeWriter, obj models.Object, parent string) (string, error) {
+	base := obj.Base()
+	w.blank()
+	w.line("// %s", commentText(base.Label))
+
+	var (
+		create string
+		jsType string
+		typed  = obj // The object whose properties are applied
+	)
+
+	x, y := "0", "0"
+	if t := objectTransform(obj); t != nil {
+		x, y = jsNumber(t.X), jsNumber(t.Y)
+	}
+
+	instance, isInstance := obj.(*models.Instance)
+	if isInstance {
+		class, view, err := g.instance(instance)
+		if err != nil {
+			return "", err
+		}
+		args := []string{g.sceneRef}
+		if _, ok := view.(*models.Layer); !ok {
+			args = append(args, x, y)
+		}
+		if texture, _ := objectTexture(view); texture != nil && slices.Contains(base.Unlock, "texture") {
+			args = append(args, jsString(texture.Key))
+			if texture.Frame != "" {
+				args = append(args, jsString(texture.Frame))
+			}
+		}
+		create = fmt.Sprintf("new %s(%s)", class, strings.Join(args, ", "))
+		jsType = class
+		typed = view
+	} else {
+		factory, ok := factoryOf(obj)
+		if !ok {
+			w.line("// unsupported object type %s", jsString(base.Type))
+			return "", nil
+		}
+		var texture []string
+		if t, ok := objectTexture(obj); ok {
+			texture = textureArgs(t)
+		}
+		create = fmt.Sprintf("%s.add.%s(%s)", g.sceneRef, factory.method, strings.Join(creationArgs(obj, x, y, texture), ", "))
+		jsType = factory.jsType
+	}
+
+	children := models.Children(obj)
+	scope := objectScope(base)
+	needsVar := isInstance || parent != "" || len(children) > 0 || len(base.Components) > 0 ||
+		g.listed[base.ID] || scope == scopeClass || scope == scopePublic
+
+	statements := []string{}
+	varName := ""
+	if needsVar || len(g.properties(typed, "_")) > 0 || hasComponentProperties(typed.Base()) {
+		varName = g.declare(base.Label)
+		statements = g.properties(typed, varName)
+		w.line("const %s = %s;", varName, create)
+		if isInstance && parent == "" {
+			w.line("%s.add.existing(%s);", g.sceneRef, varName)
+		}
+	} else {
+		w.line("%s;", create)
+	}
+	for _, s := range statements {
+		w.line("%s", s)
+	}
+
+	if varName == "" {
+		return "", nil
+	}
+	g.vars[base.ID] = varName
+	if scope == scopeClass || scope == scopePublic {
+		g.fields = append(g.fields, classField{name: varName, jsType: jsType})
+	}
+
+	for _, childObj := range children {
+		child, err := g.object(w, childObj, varName)
+		if err != nil {
+			return "", err
+		}
+		if child != "" {
+			w.line("%s.add(%s);", varName, child)
+		}
+	}
+
+	g.components(w, typed.Base(), varName, base.Label)
+
+	return varName, nil
+}
+
+// properties returns the statements that apply obj's settings to the
+// variable v. Prefab instances only carry their unlocked properties.
+func (g *generator) properties(obj models.Object, v string) []string {
+	base := obj.Base()
+	s := &statementList{v: v, allowed: base.UsesOwnProperty}
+
+	if origin, def := objectOrigin(obj); origin != nil &&
+		((origin.OriginX != nil && s.allowed("originX")) || (origin.OriginY != nil && s.allowed("originY"))) {
+		ox, oy := def, def
+		if origin.OriginX != nil {
+			ox = *origin.OriginX
+		}
+		if origin.OriginY != nil {
+			oy = *origin.OriginY
+		}
+		s.add("%s.setOrigin(%s, %s);", v, jsNumber(ox), jsNumber(oy))
+	}
+	if t := objectTransform(obj); t != nil {
+		s.number("scaleX", t.ScaleX)
+		s.number("scaleY", t.ScaleY)
+		s.number("angle", t.Angle)
+		if t.Visible != nil && !*t.Visible && s.allowed("visible") {
+			s.add("%s.visible = false;", v)
+		}
+	}
+	for _, prop := range assignedProperties {
+		if raw, ok := base.Properties.Get(prop); ok && s.allowed(prop) {
+			s.add("%s.%s = %s;", v, prop, jsValue(raw, false))
+		}
+	}
+
+	typeProperties(s, obj)
+	return s.out
+}
+
+// components emits the user components attached to obj. Components listed
+// on the object are created; properties of other components are overrides
+// of components the prefab already attaches.
+func (g *generator) components(w *codeWriter, obj *models.ObjectBase, v, label string) {
+	props := componentProperties(obj)
+	if len(obj.Components) == 0 && len(props) == 0 {
+		return
+	}
+
+	w.blank()
+	w.line("// %s (components)", commentText(label))
+
+	for _, component := range obj.Components {
+		g.compImp[component] = true
+		keys := props[component]
+		delete(props, component)
+		if len(keys) == 0 {
+			w.line("new %s(%s);", component, v)
+			continue
+		}
+		compVar := g.declare(v + component)
+		w.line("const %s = new %s(%s);", compVar, component, v)
+		for _, key := range keys {
+			g.componentAssignment(w, obj, compVar, component, key)
+		}
+	}
+
+	overrides := make([]string, 0, len(props))
+	for component := range props {
+		overrides = append(overrides, component)
+	}
+	sort.Strings(overrides)
+	for _, component := range overrides {
+		g.compImp[component] = true
+		compVar := g.declare(v + component)
+		w.line("const %s = %s.getComponent(%s);", compVar, component, v)
+		for _, key := range props[component] {
+			g.componentAssignment(w, obj, compVar, component, key)
+		}
+	}
+}
+
+func (g *generator) componentAssignment(w *codeWriter, obj *models.ObjectBase, compVar, component, key string) {
+	raw, _ := obj.Properties.Get(key)
+	property := strings.TrimPrefix(key, component+".")
+	w.line("%s.%s = %s;", compVar, property, jsValue(raw, expressionProperties[key]))
+}
+
+// componentProperties groups obj's "Component.property" keys by component
+func componentProperties(obj *models.ObjectBase) map[string][]string {
+	props := map[string][]string{}
+	for _, key := range obj.Properties.Keys() {
+		component, _, ok := strings.Cut(key, ".")
+		if !ok {
+			continue
+		}
+		if !obj.UsesOwnProperty(key) {
+			continue
+		}
+		props[component] = append(props[component], key)
+	}
+	return props
+}
+
+func hasComponentProperties(obj *models.ObjectBase) bool {
+	return len(componentProperties(obj)) > 0
+}
+
+// lists emits the object lists as class fields
+func (g *generator) lists(w *codeWriter) {
+	for _, list := range g.scene.Lists {
+		var members []string
+		for _, id := range list.ObjectIDs {
+			if v, ok := g.vars[id]; ok {
+				members = append(members, v)
+			}
+		}
+		name := g.declare(list.Label)
+		w.blank()
+		w.line("// lists")
+		w.line("const %s = [%s];", name, strings.Join(members, ", "))
+		g.fields = append(g.fields, classField{name: name, jsType: "Array<any>"})
+	}
+}
+
+// assignFields stores the class field objects on the instance
+func (g *generator) assignFields(w *codeWriter) {
+	if len(g.fields) == 0 {
+		return
+	}
+	w.blank()
+	for _, f := range g.fields {
+		w.line("this.%s = %s;", f.name, f.name)
+	}
+}
+
+// declareFields writes the JSDoc typed field declarations in the constructor
+func (g *generator) declareFields(w *codeWriter) {
+	if len(g.fields) == 0 {
+		return
+	}
+	for _, f := range g.fields {
+		w.line("/** @type {%s} */", f.jsType)
+		w.line("this.%s;", f.name)
+	}
+	w.blank()
+}
+
+// declare reserves a unique variable name based on label
+func (g *generator) declare(label string) string {
+	base := identifier(label)
+	name := base
+	for i := 1; g.names[name]; i++ {
+		name = base + "_" + strconv.Itoa(i)
+	}
+	g.names[name] = true
+	return name
+}
+
+// importPrefab registers the import of a prefab class and returns its name.
+// A prefab whose class name is already taken by another imported prefab,
+// a component or the class being generated is imported under an alias,
+// e.g. Button2.
+func (g *generator) importPrefab(id string) (string, error) {
+	if class, ok := g.prefabCls[id]; ok {
+		return class, nil
+	}
+	prefab, err := g.prefabs.resolve(id)
+	if err != nil {
+		return "", err
+	}
+	rel, err := relativeImport(g.name, prefab.Name)
+	if err != nil {
+		return "", err
+	}
+
+	taken := func(class string) bool {
+		_, imported := g.prefabImp[class]
+		return imported || g.compNames[class] || class == className(g.name)
+	}
+	class := className(prefab.Name)
+	if taken(class) {
+		base := class
+		for i := 2; taken(class) || g.names[class]; i++ {
+			class = base + strconv.Itoa(i)
+		}
+		g.names[class] = true
+	}
+
+	g.prefabImp[class] = rel
+	g.prefabCls[id] = class
+	return class, nil
+}
+
+// relativeImport returns the module path of target as imported from scene
+func relativeImport(scene, target string) (string, error) {
+	from := strings.Split(path.Dir(scene), "/")
+	to := strings.Split(target, "/")
+	if path.Dir(scene) == "." {
+		from = nil
+	}
+
+	common := 0
+	for common < len(from) && common < len(to)-1 && from[common] == to[common] {
+		common++
+	}
+
+	parts := []string{}
+	for range from[common:] {
+		parts = append(parts, "..")
+	}
+	parts = append(parts, to[common:]...)
+
+	rel := strings.Join(parts, "/")
+	if !strings.HasPrefix(rel, "../") {
+		rel = "./" + rel
+	}
+	return rel, nil
+}
+
+// beginFile writes the user header, compiled code marker and imports
+func (g *generator) beginFile() *codeWriter {
+	w := newCodeWriter(g.indent)
+	if g.user.exists {
+		w.raw(g.user.header)
+	} else {
+		w.raw("\n// You can write more code here\n\n")
+	}
+	w.line("%s", compiledStart)
+	w.blank()
+
+	classes := make([]string, 0, len(g.prefabImp))
+	for class := range g.prefabImp {
+		classes = append(classes, class)
+	}
+	sort.Strings(classes)
+	for _, class := range classes {
+		w.line("import %s from %s;", class, jsString(g.prefabImp[class]))
+	}
+
+	if len(g.compImp) > 0 {
+		components := make([]string, 0, len(g.compImp))
+		for component := range g.compImp {
+			components = append(components, component)
+		}
+		sort.Strings(components)
+		w.line("import { %s } from %s;", strings.Join(components, ", "), jsString(g.c.options.ComponentsModule))
+	}
+
+	w.region(regionImports, g.user)
+	w.blank()
+	return w
+}
+
+func (g *generator) classHeader(w *codeWriter, superClass string) {
+	export := ""
+	if settingBool(g.scene, "exportClass", true) {
+		export = "export default "
+	}
+	w.open("%sclass %s extends %s {", export, className(g.name), superClass)
+	w.blank()
+}
+
+// endFile closes the class, writes the user code region and the footer
+func (g *generator) endFile(w *codeWriter) string {
+	w.region(regionUserCode, g.user, "", "// Write your code here", "")
+	w.close("}")
+	w.blank()
+	w.raw(compiledEnd)
+
+	if g.user.exists {
+		w.raw(g.user.footer)
+	} else {
+		w.raw("\n\n// You can write more code here\n")
+	}
+	return w.String()
+}
+
+func objectScope(obj *models.ObjectBase) string {
+	var scope string
+	obj.Properties.Decode("scope", &scope)
+	return scope
+}
+
+// commentText keeps labels from terminating a line comment early
+func commentText(label string) string {
+	return strings.NewReplacer("\n", " ", "\r", " ").Replace(label)
+}
@@ -0,0 +1,382 @@
+package compiler
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"tuxedo-core/models"
+)
+
+// objectFactory describes how a display object type is created
+type objectFactory struct {
+	method string // GameObjectFactory method, e.g. image for scene.add.image
+	jsType string
+}
+
+// factoryOf returns the factory of a typed object, and false for objects
+// the compiler cannot create
+func factoryOf(obj models.Object) (objectFactory, bool) {
+	switch obj.(type) {
+	case *models.Image:
+		return objectFactory{method: "image", jsType: "Phaser.GameObjects.Image"}, true
+	case *models.Sprite:
+		return objectFactory{method: "sprite", jsType: "Phaser.GameObjects.Sprite"}, true
+	case *models.TileSprite:
+		return objectFactory{method: "tileSprite", jsType: "Phaser.GameObjects.TileSprite"}, true
+	case *models.NineSlice:
+		return objectFactory{method: "nineslice", jsType: "Phaser.GameObjects.NineSlice"}, true
+	case *models.Text:
+		return objectFactory{method: "text", jsType: "Phaser.GameObjects.Text"}, true
+	case *models.BitmapText:
+		return objectFactory{method: "bitmapText", jsType: "Phaser.GameObjects.BitmapText"}, true
+	case *models.Rectangle:
+		return objectFactory{method: "rectangle", jsType: "Phaser.GameObjects.Rectangle"}, true
+	case *models.Ellipse:
+		return objectFactory{method: "ellipse", jsType: "Phaser.GameObjects.Ellipse"}, true
+	case *models.Polygon:
+		return objectFactory{method: "polygon", jsType: "Phaser.GameObjects.Polygon"}, true
+	case *models.Zone:
+		return objectFactory{method: "zone", jsType: "Phaser.GameObjects.Zone"}, true
+	case *models.Container:
+		return objectFactory{method: "container", jsType: "Phaser.GameObjects.Container"}, true
+	case *models.Layer:
+		return objectFactory{method: "layer", jsType: "Phaser.GameObjects.Layer"}, true
+	}
+	return objectFactory{}, false
+}
+
+// creationArgs returns the arguments creating obj, after the scene for
+// constructors. x and y are the position expressions and texture the key
+// and frame expressions of textured objects. Trailing undefined arguments
+// are left out.
+func creationArgs(obj models.Object, x, y string, texture []string) []string {
+	var args []string
+	switch o := obj.(type) {
+	case *models.Image, *models.Sprite:
+		args = append([]string{x, y}, texture...)
+	case *models.TileSprite:
+		args = append([]string{x, y, optionalNumber(o.Width), optionalNumber(o.Height)}, texture...)
+	case *models.NineSlice:
+		args = append([]string{x, y}, texture...)
+		args = append(args, optionalNumber(o.Width), optionalNumber(o.Height),
+			optionalNumber(o.LeftWidth), optionalNumber(o.RightWidth),
+			optionalNumber(o.TopHeight), optionalNumber(o.BottomHeight))
+	case *models.Text:
+		args = []string{x, y, jsString(o.Text), "{}"}
+	case *models.BitmapText:
+		align := "undefined"
+		if o.Align != nil {
+			align = strconv.Itoa(*o.Align)
+		}
+		args = []string{x, y, jsString(o.Font), jsString(o.Text), optionalNumber(o.FontSize), align}
+	case *models.Rectangle:
+		args = []string{x, y, optionalNumber(o.Width), optionalNumber(o.Height)}
+	case *models.Ellipse:
+		args = []string{x, y, optionalNumber(o.Width), optionalNumber(o.Height)}
+	case *models.Polygon:
+		args = []string{x, y, jsString(o.Points)}
+	case *models.Zone:
+		args = []string{x, y, optionalNumber(o.Width), optionalNumber(o.Height)}
+	case *models.Container:
+		args = []string{x, y}
+	}
+
+	for len(args) > 0 && args[len(args)-1] == "undefined" {
+		args = args[:len(args)-1]
+	}
+	return args
+}
+
+// textureArgs returns the key and frame arguments of a texture
+func textureArgs(texture *models.Texture) []string {
+	if texture == nil {
+		return []string{jsString("__DEFAULT")}
+	}
+	frame := "undefined"
+	if texture.Frame != "" {
+		frame = jsString(texture.Frame)
+	}
+	return []string{jsString(texture.Key), frame}
+}
+
+// objectTexture returns the texture of textured objects, and false for
+// objects that take none
+func objectTexture(obj models.Object) (*models.Texture, bool) {
+	switch o := obj.(type) {
+	case *models.Image:
+		return o.Texture, true
+	case *models.Sprite:
+		return o.Texture, true
+	case *models.TileSprite:
+		return o.Texture, true
+	case *models.NineSlice:
+		return o.Texture, true
+	}
+	return nil, false
+}
+
+// objectTransform returns the transform of objects that have one
+func objectTransform(obj models.Object) *models.Transform {
+	switch o := obj.(type) {
+	case *models.Image:
+		return &o.Transform
+	case *models.Sprite:
+		return &o.Transform
+	case *models.TileSprite:
+		return &o.Transform
+	case *models.NineSlice:
+		return &o.Transform
+	case *models.Text:
+		return &o.Transform
+	case *models.BitmapText:
+		return &o.Transform
+	case *models.Rectangle:
+		return &o.Transform
+	case *models.Ellipse:
+		return &o.Transform
+	case *models.Polygon:
+		return &o.Transform
+	case *models.Zone:
+		return &o.Transform
+	case *models.Container:
+		return &o.Transform
+	case *models.Instance:
+		return &o.Transform
+	case *models.Generic:
+		return &o.Transform
+	}
+	return nil
+}
+
+// objectOrigin returns the origin of objects that have one, together with
+// Phaser's default origin for the type
+func objectOrigin(obj models.Object) (*models.Origin, float64) {
+	switch o := obj.(type) {
+	case *models.Image:
+		return &o.Origin, 0.5
+	case *models.Sprite:
+		return &o.Origin, 0.5
+	case *models.TileSprite:
+		return &o.Origin, 0.5
+	case *models.NineSlice:
+		return &o.Origin, 0.5
+	case *models.Text:
+		return &o.Origin, 0
+	case *models.BitmapText:
+		return &o.Origin, 0
+	case *models.Rectangle:
+		return &o.Origin, 0.5
+	case *models.Ellipse:
+		return &o.Origin, 0.5
+	case *models.Polygon:
+		return &o.Origin, 0.5
+	case *models.Zone:
+		return &o.Origin, 0.5
+	}
+	return nil, 0
+}
+
+// statementList collects the statements applying an object's settings to
+// the variable v, skipping properties a prefab instance takes from its
+// prefab
+type statementList struct {
+	v       string
+	allowed func(property string) bool
+	out     []string
+}
+
+func (s *statementList) add(format string, args ...any) {
+	s.out = append(s.out, fmt.Sprintf(format, args...))
+}
+
+// number assigns a numeric property that is set
+func (s *statementList) number(property string, value *float64) {
+	if value != nil && s.allowed(property) {
+		s.add("%s.%s = %s;", s.v, property, jsNumber(*value))
+	}
+}
+
+// size resizes a prefab instance with an unlocked width or height. Objects
+// the compiler creates get their size from the factory.
+func (s *statementList) size(size models.Size) {
+	width, height := s.v+".width", s.v+".height"
+	own := false
+	if size.Width != nil && s.allowed("width") {
+		width, own = jsNumber(*size.Width), true
+	}
+	if size.Height != nil && s.allowed("height") {
+		height, own = jsNumber(*size.Height), true
+	}
+	if own {
+		s.add("%s.setSize(%s, %s);", s.v, width, height)
+	}
+}
+
+// shape sets the fill and stroke of a shape object
+func (s *statementList) shape(shape models.Shape) {
+	if shape.IsFilled != nil && s.allowed("isFilled") {
+		s.add("%s.isFilled = %t;", s.v, *shape.IsFilled)
+	}
+	if shape.FillColor != "" && s.allowed("fillColor") {
+		s.add("%s.fillColor = %s;", s.v, jsColor(shape.FillColor))
+	}
+	s.number("fillAlpha", shape.FillAlpha)
+	if shape.IsStroked != nil && s.allowed("isStroked") {
+		s.add("%s.isStroked = %t;", s.v, *shape.IsStroked)
+	}
+	if shape.StrokeColor != "" && s.allowed("strokeColor") {
+		s.add("%s.strokeColor = %s;", s.v, jsColor(shape.StrokeColor))
+	}
+	s.number("strokeAlpha", shape.StrokeAlpha)
+	s.number("lineWidth", shape.LineWidth)
+}
+
+// typeProperties adds the statements for the fields of obj's type
+func typeProperties(s *statementList, obj models.Object) {
+	instance := obj.Base().PrefabId != ""
+
+	switch o := obj.(type) {
+	case *models.Sprite:
+		if o.AnimationKey != "" && o.AnimationPlayMethod != nil && s.allowed("animationKey") {
+			switch *o.AnimationPlayMethod {
+			case 1:
+				s.add("%s.play(%s);", s.v, jsString(o.AnimationKey))
+			case 2:
+				s.add("%s.playReverse(%s);", s.v, jsString(o.AnimationKey))
+			}
+		}
+	case *models.TileSprite:
+		if instance {
+			s.size(o.Size)
+		}
+		s.number("tilePositionX", o.TilePositionX)
+		s.number("tilePositionY", o.TilePositionY)
+		s.number("tileScaleX", o.TileScaleX)
+		s.number("tileScaleY", o.TileScaleY)
+	case *models.NineSlice:
+		if instance {
+			s.size(o.Size)
+		}
+	case *models.Text:
+		if instance && o.Text != "" && s.allowed("text") {
+			s.add("%s.text = %s;", s.v, jsString(o.Text))
+		}
+		if style := textStyle(o, s.allowed); style != "" {
+			s.add("%s.setStyle(%s);", s.v, style)
+		}
+		if padding := textPadding(o); padding != "" {
+			s.add("%s.setPadding(%s);", s.v, padding)
+		}
+		if (o.FixedWidth != nil && s.allowed("fixedWidth")) || (o.FixedHeight != nil && s.allowed("fixedHeight")) {
+			s.add("%s.setFixedSize(%s, %s);", s.v, jsNumber(value(o.FixedWidth)), jsNumber(value(o.FixedHeight)))
+		}
+	case *models.BitmapText:
+		if instance {
+			if o.Text != "" && s.allowed("text") {
+				s.add("%s.text = %s;", s.v, jsString(o.Text))
+			}
+			if o.Font != "" && s.allowed("font") {
+				s.add("%s.setFont(%s);", s.v, jsString(o.Font))
+			}
+			s.number("fontSize", o.FontSize)
+			if o.Align != nil && s.allowed("align") {
+				s.add("%s.align = %d;", s.v, *o.Align)
+			}
+		}
+		s.number("letterSpacing", o.LetterSpacing)
+		s.number("maxWidth", o.MaxWidth)
+	case *models.Rectangle:
+		if instance {
+			s.size(o.Size)
+		}
+		s.shape(o.Shape)
+	case *models.Ellipse:
+		if instance {
+			s.size(o.Size)
+		}
+		s.shape(o.Shape)
+		s.number("smoothness", o.Smoothness)
+	case *models.Polygon:
+		if instance && o.Points != "" && s.allowed("points") {
+			s.add("%s.setTo(%s);", s.v, jsString(o.Points))
+		}
+		s.shape(o.Shape)
+	case *models.Zone:
+		if instance {
+			s.size(o.Size)
+		}
+	case *models.Layer:
+		if o.Visible != nil && !*o.Visible && s.allowed("visible") {
+			s.add("%s.visible = false;", s.v)
+		}
+	}
+}
+
+// textStyle builds the style object literal for a Text object
+func textStyle(obj *models.Text, allowed func(property string) bool) string {
+	var entries []string
+	add := func(key, value string) {
+		if value != "" && allowed(key) {
+			entries = append(entries, fmt.Sprintf("%s: %s", jsString(key), jsString(value)))
+		}
+	}
+	add("align", obj.Align)
+	add("color", obj.Color)
+	add("fontFamily", obj.FontFamily)
+	add("fontSize", obj.FontSize)
+	add("fontStyle", obj.FontStyle)
+	add("stroke", obj.Stroke)
+	if obj.StrokeThickness != nil && allowed("strokeThickness") {
+		entries = append(entries, fmt.Sprintf(`"strokeThickness": %s`, jsNumber(*obj.StrokeThickness)))
+	}
+	if len(entries) == 0 {
+		return ""
+	}
+	return "{ " + strings.Join(entries, ", ") + " }"
+}
+
+// textPadding builds the padding object literal for a Text object
+func textPadding(obj *models.Text) string {
+	var entries []string
+	for _, p := range []struct {
+		key   string
+		value *float64
+	}{
+		{"left", obj.PaddingLeft}, {"top", obj.PaddingTop},
+		{"right", obj.PaddingRight}, {"bottom", obj.PaddingBottom},
+	} {
+		if p.value != nil {
+			entries = append(entries, fmt.Sprintf("%s: %s", jsString(p.key), jsNumber(*p.value)))
+		}
+	}
+	if len(entries) == 0 {
+		return ""
+	}
+	return "{ " + strings.Join(entries, ", ") + " }"
+}
+
+// optionalNumber returns a numeric argument, undefined when it is not set
+func optionalNumber(v *float64) string {
+	if v == nil {
+		return "undefined"
+	}
+	return jsNumber(*v)
+}
+
+func value(v *float64) float64 {
+	if v == nil {
+		return 0
+	}
+	return *v
+}
+
+// jsColor converts an editor color such as "#ff8800" to a number literal.
+// Colors in another notation are passed on as strings.
+func jsColor(color string) string {
+	hex := strings.TrimPrefix(strings.TrimPrefix(color, "#"), "0x")
+	if _, err := strconv.ParseUint(hex, 16, 32); err != nil || len(hex) != 6 {
+		return jsString(color)
+	}
+	return "0x" + strings.ToLower(hex)
+}
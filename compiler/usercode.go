@@ -0,0 +1,65 @@
+package compiler
+
+import (
+	"errors"
+	"os"
+	"regexp"
+	"strings"
+)
+
+const (
+	compiledStart = "/* START OF COMPILED CODE */"
+	compiledEnd   = "/* END OF COMPILED CODE */"
+
+	regionImports  = "USER-IMPORTS"
+	regionCtrCode  = "USER-CTR-CODE"
+	regionUserCode = "USER-CODE"
+)
+
+// ErrNotGenerated is returned when the output file exists but has no
+// compiled code markers, so overwriting it would destroy hand-written code
+var ErrNotGenerated = errors.New("output file was not generated by the scene compiler")
+
+var regionPattern = regexp.MustCompile(`(?s)/\* START-(USER-[A-Z-]+) \*/(.*?)/\* END-(USER-[A-Z-]+) \*/`)
+
+// userCode is the hand-written code found in a previously generated file
+type userCode struct {
+	exists  bool
+	header  string            // everything before the compiled section
+	footer  string            // everything after the compiled section
+	regions map[string]string // region name -> text between its markers
+}
+
+// readUserCode extracts user code from the file at path. A missing file
+// yields empty user code so defaults are generated.
+func readUserCode(path string) (userCode, error) {
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return userCode{regions: map[string]string{}}, nil
+	}
+	if err != nil {
+		return userCode{}, err
+	}
+	return parseUserCode(string(data))
+}
+
+func parseUserCode(source string) (userCode, error) {
+	code := userCode{exists: true, regions: map[string]string{}}
+
+	start := strings.Index(source, compiledStart)
+	end := strings.LastIndex(source, compiledEnd)
+	if start < 0 || end < start {
+		return userCode{}, ErrNotGenerated
+	}
+
+	code.header = source[:start]
+	code.footer = source[end+len(compiledEnd):]
+
+	for _, m := range regionPattern.FindAllStringSubmatch(source[start:end], -1) {
+		if m[1] == m[3] {
+			code.regions[m[1]] = m[2]
+		}
+	}
+
+	return code, nil
+}
@@ -0,0 +1,147 @@
+package compiler
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// codeWriter accumulates indented JavaScript source
+type codeWriter struct {
+	buf    strings.Builder
+	indent string
+	depth  int
+}
+
+func newCodeWriter(indent string) *codeWriter {
+	return &codeWriter{indent: indent}
+}
+
+// line writes one indented line
+func (w *codeWriter) line(format string, args ...any) {
+	w.buf.WriteString(strings.Repeat(w.indent, w.depth))
+	fmt.Fprintf(&w.buf, format, args...)
+	w.buf.WriteByte('\n')
+}
+
+func (w *codeWriter) blank() {
+	w.buf.WriteByte('\n')
+}
+
+// open writes a line ending in a block opener and indents what follows
+func (w *codeWriter) open(format string, args ...any) {
+	w.line(format, args...)
+	w.depth++
+}
+
+// close outdents and writes the block closer
+func (w *codeWriter) close(closer string) {
+	w.depth--
+	w.line("%s", closer)
+}
+
+// raw writes text without indentation
+func (w *codeWriter) raw(text string) {
+	w.buf.WriteString(text)
+}
+
+// region writes a user code region, reusing the content from the previous
+// output when there is one and the default lines otherwise
+func (w *codeWriter) region(name string, previous userCode, defaults ...string) {
+	if content, ok := previous.regions[name]; ok {
+		w.buf.WriteString(strings.Repeat(w.indent, w.depth))
+		w.buf.WriteString("/* START-" + name + " */")
+		w.buf.WriteString(content)
+		w.buf.WriteString("/* END-" + name + " */\n")
+		return
+	}
+
+	w.line("/* START-%s */", name)
+	for _, text := range defaults {
+		if text == "" {
+			w.blank()
+		} else {
+			w.line("%s", text)
+		}
+	}
+	w.line("/* END-%s */", name)
+}
+
+func (w *codeWriter) String() string {
+	return w.buf.String()
+}
+
+// jsString quotes s as a JavaScript string literal
+func jsString(s string) string {
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	enc.SetEscapeHTML(false)
+	enc.Encode(s)
+	return strings.TrimSuffix(buf.String(), "\n")
+}
+
+// jsNumber formats n the way JavaScript prints numbers
+func jsNumber(n float64) string {
+	return strconv.FormatFloat(n, 'f', -1, 64)
+}
+
+// jsValue converts a raw JSON property to a JavaScript literal. Expression
+// properties hold source code and are emitted verbatim.
+func jsValue(raw json.RawMessage, expression bool) string {
+	if expression {
+		var code string
+		if json.Unmarshal(raw, &code) == nil {
+			return code
+		}
+	}
+
+	var compact bytes.Buffer
+	if err := json.Compact(&compact, raw); err != nil {
+		return string(raw)
+	}
+	return compact.String()
+}
+
+// identifier turns an editor label into a valid JavaScript identifier
+func identifier(label string) string {
+	var b strings.Builder
+	upperNext := false
+	for _, r := range label {
+		switch {
+		case r == '_' || r == '$' || (r >= 'a' && r <= 'z') || (r >= 'A' && r <= 'Z') || (r >= '0' && r <= '9'):
+			if upperNext && b.Len() > 0 {
+				b.WriteString(strings.ToUpper(string(r)))
+			} else {
+				b.WriteRune(r)
+			}
+			upperNext = false
+		default:
+			upperNext = true
+		}
+	}
+
+	name := b.String()
+	if name == "" {
+		return "obj"
+	}
+	if name[0] >= '0' && name[0] <= '9' {
+		name = "_" + name
+	}
+	if reservedWords[name] {
+		name += "_"
+	}
+	return name
+}
+
+var reservedWords = map[string]bool{
+	"break": true, "case": true, "catch": true, "class": true, "const": true,
+	"continue": true, "debugger": true, "default": true, "delete": true, "do": true,
+	"else": true, "export": true, "extends": true, "finally": true, "for": true,
+	"function": true, "if": true, "import": true, "in": true, "instanceof": true,
+	"let": true, "new": true, "return": true, "super": true, "switch": true,
+	"this": true, "throw": true, "try": true, "typeof": true, "var": true,
+	"void": true, "while": true, "with": true, "yield": true, "await": true,
+	"enum": true, "null": true, "true": true, "false": true, "scene": true,
+}
@@ -8,9 +8,14 @@ import (
 
 // Config holds the server configuration
 type Config struct {
-	Server  ServerConfig  `json:"server"`
-	Project ProjectConfig `json:"project"`
-	Logging LoggingConfig `json:"logging"`
+	Server     ServerConfig     `json:"server"`
+	Project    ProjectConfig    `json:"project"`
+	Compiler   CompilerConfig   `json:"compiler"`
+	Components ComponentsConfig `json:"components"`
+	History    HistoryConfig    `json:"history"`
+	Index      IndexConfig      `json:"index"`
+	Assets     AssetsConfig     `json:"assets"`
+	Logging    LoggingConfig    `json:"logging"`
 }
 
 // ServerConfig holds server-specific settings
@@ -27,6 +32,34 @@ type ProjectConfig struct {
 	AssetsPath  string `json:"assetsPath"`
 }
 
+// CompilerConfig holds scene compiler settings
+type CompilerConfig struct {
+	ComponentsModule string `json:"componentsModule"`
+}
+
+// ComponentsConfig holds user component definition settings
+type ComponentsConfig struct {
+	Path string `json:"path"` // Relative to yukonPath, searched for .components and .components.json files
+}
+
+// HistoryConfig holds scene revision history settings
+type HistoryConfig struct {
+	Enabled      bool   `json:"enabled"`
+	Path         string `json:"path"`         // Relative to yukonPath
+	MaxRevisions int    `json:"maxRevisions"` // Per scene, 0 for unlimited
+	MaxAgeDays   int    `json:"maxAgeDays"`   // 0 for unlimited
+}
+
+// IndexConfig holds project index settings
+type IndexConfig struct {
+	CachePath string `json:"cachePath"` // Relative to yukonPath, empty to disable the cache file
+}
+
+// AssetsConfig holds asset file endpoint settings
+type AssetsConfig struct {
+	MaxUploadMB int `json:"maxUploadMB"` // Largest upload request, 0 for unlimited
+}
+
 // LoggingConfig holds logging settings
 type LoggingConfig struct {
 	Enabled bool   `json:"enabled"`
@@ -45,6 +78,24 @@ var defaultConfig = Config{
 		ScenesPath: "src/scenes",
 		AssetsPath: "assets",
 	},
+	Compiler: CompilerConfig{
+		ComponentsModule: "@components/components",
+	},
+	Components: ComponentsConfig{
+		Path: "src/components",
+	},
+	History: HistoryConfig{
+		Enabled:      true,
+		Path:         ".tuxedo/history",
+		MaxRevisions: 50,
+		MaxAgeDays:   30,
+	},
+	Index: IndexConfig{
+		CachePath: ".tuxedo/index.json",
+	},
+	Assets: AssetsConfig{
+		MaxUploadMB: 256,
+	},
 	Logging: LoggingConfig{
 		Enabled: true,
 		Level:   "info",
@@ -65,7 +116,8 @@ func Load(configPath string) (*Config, error) {
 	}
 	defer file.Close()
 
-	var cfg Config
+	// Sections missing from the file keep their defaults
+	cfg := defaultConfig
 	decoder := json.NewDecoder(file)
 	if err := decoder.Decode(&cfg); err != nil {
 		return nil, err
@@ -98,6 +150,26 @@ func (c *Config) GetAssetsPath() string {
 	return filepath.Join(c.Project.YukonPath, c.Project.AssetsPath)
 }
 
+// GetHistoryPath returns the full path to the scene revision history
+func (c *Config) GetHistoryPath() string {
+	return filepath.Join(c.Project.YukonPath, c.History.Path)
+}
+
+// GetComponentsPath returns the full path to the folder searched for
+// component definitions
+func (c *Config) GetComponentsPath() string {
+	return filepath.Join(c.Project.YukonPath, c.Components.Path)
+}
+
+// GetIndexCachePath returns the full path to the project index cache file,
+// or an empty string when the cache is disabled
+func (c *Config) GetIndexCachePath() string {
+	if c.Index.CachePath == "" {
+		return ""
+	}
+	return filepath.Join(c.Project.YukonPath, c.Index.CachePath)
+}
+
 // GetScenesPath returns the full path to scenes directory
 func (c *Config) GetScenesPath() string {
 	return filepath.Join(c.Project.YukonPath, c.Project.ScenesPath)
@@ -4,7 +4,9 @@ go 1.23.2
 
 require (
 	github.com/fsnotify/fsnotify v1.9.0
+	github.com/google/uuid v1.6.0
 	github.com/gorilla/mux v1.8.1
+	github.com/gorilla/websocket v1.5.3
 )
 
 require golang.org/x/sys v0.13.0 // indirect
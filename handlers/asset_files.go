@@ -0,0 +1,180 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"net/http"
+	"os"
+	"path"
+	"strings"
+
+	"tuxedo-core/services"
+
+	"github.com/gorilla/mux"
+)
+
+// Uploaded files up to this size are kept in memory while parsing the
+// form; larger ones go to temporary files, which are streamed into place
+const maxUploadMemory = 32 << 20
+
+// assetFilesResponse reports the files and references an upload, move or
+// delete touched
+type assetFilesResponse struct {
+	Status string `json:"status"`
+	From   string `json:"from,omitempty"`
+	To     string `json:"to,omitempty"`
+	*services.AssetChange
+}
+
+// UploadAssets writes the files of a multipart form into a folder under
+// the assets directory. Form fields: file (repeatable), folder, and
+// optionally pack, section and key to add the files to a pack, and
+// overwrite=true to replace existing files and pack entries.
+func (s *Server) UploadAssets(w http.ResponseWriter, r *http.Request) {
+	if limit := s.config.Assets.MaxUploadMB; limit > 0 {
+		r.Body = http.MaxBytesReader(w, r.Body, int64(limit)<<20)
+	}
+	if err := r.ParseMultipartForm(maxUploadMemory); err != nil {
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			http.Error(w, fmt.Sprintf("Upload is larger than %d MB", s.config.Assets.MaxUploadMB), http.StatusRequestEntityTooLarge)
+			return
+		}
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	defer r.MultipartForm.RemoveAll()
+
+	folder := strings.Trim(path.Clean("/"+r.FormValue("folder")), "/")
+	if _, err := resolveInside(s.assetsPath, folder); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	opts := services.UploadOptions{
+		Folder:    folder,
+		Section:   r.FormValue("section"),
+		Key:       r.FormValue("key"),
+		Overwrite: r.FormValue("overwrite") == "true",
+	}
+	if pack := r.FormValue("pack"); pack != "" {
+		rel, ok := s.packAssetPath(pack)
+		if !ok {
+			http.Error(w, "Pack not found: "+pack, http.StatusBadRequest)
+			return
+		}
+		opts.Pack = rel
+	}
+
+	headers := r.MultipartForm.File["file"]
+	if len(headers) == 0 {
+		http.Error(w, "No files uploaded", http.StatusBadRequest)
+		return
+	}
+	var files []services.UploadFile
+	for _, header := range headers {
+		// Browsers may send a folder path with the name; only the name is used
+		name := path.Base(strings.ReplaceAll(header.Filename, "\\", "/"))
+		if name == "" || name == "." || name == "/" || strings.HasPrefix(name, ".") {
+			http.Error(w, "Invalid file name: "+header.Filename, http.StatusBadRequest)
+			return
+		}
+		files = append(files, services.UploadFile{Name: name, Open: func() (io.ReadCloser, error) { return header.Open() }})
+	}
+
+	change, err := s.assets.Upload(files, opts)
+	var pathErr *fs.PathError
+	switch {
+	case errors.Is(err, os.ErrExist) && errors.As(err, &pathErr):
+		http.Error(w, "Asset already exists: "+pathErr.Path, http.StatusConflict)
+		return
+	case errors.Is(err, services.ErrPackKeyExists):
+		http.Error(w, err.Error(), http.StatusConflict)
+		return
+	case errors.Is(err, services.ErrUnregisterable):
+		http.Error(w, err.Error(), http.StatusUnprocessableEntity)
+		return
+	case err != nil:
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	writeJSON(w, http.StatusCreated, assetFilesResponse{Status: "created", AssetChange: change})
+}
+
+// MoveAsset renames an asset file or folder and/or moves it into another
+// folder. Pack entries and preloadPackFiles referring to it follow.
+func (s *Server) MoveAsset(w http.ResponseWriter, r *http.Request) {
+	from := strings.Trim(path.Clean("/"+mux.Vars(r)["path"]), "/")
+
+	var req struct {
+		To string `json:"to"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	to := strings.Trim(path.Clean("/"+req.To), "/")
+
+	if _, err := resolveInside(s.assetsPath, from); err != nil || from == "" {
+		http.Error(w, "Invalid asset path", http.StatusBadRequest)
+		return
+	}
+	if _, err := resolveInside(s.assetsPath, to); err != nil || to == "" {
+		http.Error(w, "Invalid target asset path", http.StatusBadRequest)
+		return
+	}
+	if to == from {
+		http.Error(w, "Asset is already at "+to, http.StatusBadRequest)
+		return
+	}
+	if strings.HasPrefix(to, from+"/") {
+		http.Error(w, "Cannot move a folder into itself", http.StatusBadRequest)
+		return
+	}
+
+	change, err := s.assets.Move(from, to)
+	switch {
+	case errors.Is(err, fs.ErrNotExist):
+		http.Error(w, "Asset not found", http.StatusNotFound)
+		return
+	case errors.Is(err, os.ErrExist):
+		http.Error(w, "Asset already exists: "+to, http.StatusConflict)
+		return
+	case err != nil:
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, assetFilesResponse{Status: "moved", From: from, To: to, AssetChange: change})
+}
+
+// DeleteAsset removes an asset file or folder and the pack entries loading
+// it. Assets scenes still use, through a texture or a preloaded pack, are
+// only deleted with ?force=true.
+func (s *Server) DeleteAsset(w http.ResponseWriter, r *http.Request) {
+	rel := strings.Trim(path.Clean("/"+mux.Vars(r)["path"]), "/")
+	if _, err := resolveInside(s.assetsPath, rel); err != nil || rel == "" {
+		http.Error(w, "Invalid asset path", http.StatusBadRequest)
+		return
+	}
+
+	change, err := s.assets.Delete(rel, r.URL.Query().Get("force") == "true")
+	var inUse *services.AssetInUseError
+	switch {
+	case errors.As(err, &inUse):
+		http.Error(w, "Asset is used by: "+strings.Join(inUse.Scenes, ", "), http.StatusConflict)
+		return
+	case errors.Is(err, fs.ErrNotExist):
+		http.Error(w, "Asset not found", http.StatusNotFound)
+		return
+	case err != nil:
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, assetFilesResponse{Status: "deleted", AssetChange: change})
+}
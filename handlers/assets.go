@@ -3,15 +3,16 @@ package handlers
 import (
 	"encoding/json"
 	"net/http"
-	"os"
+	"path"
 	"path/filepath"
+	"slices"
 	"strings"
 
+	"tuxedo-core/services"
+
 	"github.com/gorilla/mux"
 )
 
-var assetsPath = "../yukon/assets"
-
 // Media subdirectories to search for assets
 var mediaSubdirectories = []string{
 	"games", "rooms", "interface", "artifacts", "clothing",
@@ -34,63 +35,37 @@ type AssetLocation struct {
 	Directory string `json:"directory,omitempty"`
 }
 
-// checkAssetFile checks if a file exists and returns its location info
-func checkAssetFile(fullPath string, absAssetsPath string, isAtlas bool) (AssetLocation, bool) {
-	// Check if file exists
-	if _, err := os.Stat(fullPath); err != nil {
-		return AssetLocation{}, false
-	}
-
-	// Convert to web path - extract path after "assets/"
-	relativePath := strings.TrimPrefix(fullPath, absAssetsPath)
-	relativePath = filepath.ToSlash(relativePath)
-	relativePath = "/assets" + relativePath
-
+// assetLocation describes a pack or atlas file found in the index
+func assetLocation(rel string, isAtlas bool) AssetLocation {
 	location := AssetLocation{
 		Found: true,
 		Type:  "pack",
-		Path:  relativePath,
+		Path:  "/assets/" + rel,
 	}
 
 	if isAtlas {
 		location.Type = "atlas"
 		// For atlases, also return the directory path
-		dirPath := filepath.Dir(fullPath)
-		relativeDirPath := strings.TrimPrefix(dirPath, absAssetsPath)
-		relativeDirPath = filepath.ToSlash(relativeDirPath)
-		location.Directory = "/assets" + relativeDirPath
+		location.Directory = "/assets/" + path.Dir(rel)
 	}
 
-	return location, true
+	return location
 }
 
-func GetAssets(w http.ResponseWriter, r *http.Request) {
+func (s *Server) GetAssets(w http.ResponseWriter, r *http.Request) {
 	assets := []AssetInfo{}
 
-	err := filepath.Walk(assetsPath, func(path string, info os.FileInfo, err error) error {
-		if err != nil {
-			return err
+	for _, asset := range s.index.Assets() {
+		ext := path.Ext(asset.Path)
+		// TODO: add more asset types, webm, gif, svg, etc.
+		if ext == ".png" || ext == ".jpg" || ext == ".json" || ext == ".atlas" {
+			assets = append(assets, AssetInfo{
+				Name: path.Base(asset.Path),
+				Path: filepath.FromSlash(asset.Path),
+				Type: ext[1:],
+				Size: asset.Size,
+			})
 		}
-
-		if !info.IsDir() {
-			ext := filepath.Ext(path)
-			// TODO: add more asset types, webm, gif, svg, etc.
-			if ext == ".png" || ext == ".jpg" || ext == ".json" || ext == ".atlas" {
-				relPath, _ := filepath.Rel(assetsPath, path)
-				assets = append(assets, AssetInfo{
-					Name: info.Name(),
-					Path: relPath,
-					Type: ext[1:],
-					Size: info.Size(),
-				})
-			}
-		}
-		return nil
-	})
-
-	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
-		return
 	}
 
 	w.Header().Set("Content-Type", "application/json")
@@ -98,7 +73,7 @@ func GetAssets(w http.ResponseWriter, r *http.Request) {
 }
 
 // ResolveAssetLocation finds the pack file or atlas for a given texture key
-func ResolveAssetLocation(w http.ResponseWriter, r *http.Request) {
+func (s *Server) ResolveAssetLocation(w http.ResponseWriter, r *http.Request) {
 	vars := mux.Vars(r)
 	key := vars["key"]
 
@@ -107,96 +82,78 @@ func ResolveAssetLocation(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// Convert assetsPath to absolute path for reliable path operations
-	absAssetsPath, err := filepath.Abs(assetsPath)
-	if err != nil {
-		http.Error(w, "Failed to resolve assets path", http.StatusInternalServerError)
-		return
-	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(s.resolveAssetKey(key))
+}
 
-	mediaPath := filepath.Join(absAssetsPath, "media")
+// resolveAssetKey looks up the pack that declares the texture key, or the
+// atlas found outside any pack. Other keys, such as room names, resolve to
+// the pack file or atlas named after them, first in the usual media
+// folders, then anywhere under media.
+func (s *Server) resolveAssetKey(key string) AssetLocation {
+	if texture, ok := s.textures.Textures().Texture(key); ok {
+		if texture.Pack != "" {
+			return assetLocation(texture.Pack, false)
+		}
+		return assetLocation(texture.Atlas, true)
+	}
 
-	// First try direct patterns in known subdirectories
 	searchPatterns := []struct {
 		pattern string
 		isAtlas bool
 	}{
 		// Direct pack files
-		{filepath.Join("{subdir}", key, key+"-pack.json"), false},
+		{"{subdir}/" + key + "/" + key + "-pack.json", false},
 		// Nested game pack files
-		{filepath.Join("{subdir}", "game", key, key+"-pack.json"), false},
+		{"{subdir}/game/" + key + "/" + key + "-pack.json", false},
 		// Direct atlases
-		{filepath.Join("{subdir}", key, key+".json"), true},
+		{"{subdir}/" + key + "/" + key + ".json", true},
 		// Nested game atlases
-		{filepath.Join("{subdir}", "game", key, key+".json"), true},
+		{"{subdir}/game/" + key + "/" + key + ".json", true},
 	}
 
 	// Search through subdirectories with known patterns
 	for _, subdir := range mediaSubdirectories {
 		for _, search := range searchPatterns {
-			pattern := strings.Replace(search.pattern, "{subdir}", subdir, 1)
-			fullPath := filepath.Join(mediaPath, pattern)
-
-			if location, found := checkAssetFile(fullPath, absAssetsPath, search.isAtlas); found {
-				w.Header().Set("Content-Type", "application/json")
-				json.NewEncoder(w).Encode(location)
-				return
+			rel := "media/" + strings.Replace(search.pattern, "{subdir}", subdir, 1)
+			if s.index.HasAsset(rel) {
+				return assetLocation(rel, search.isAtlas)
 			}
 		}
 	}
 
-	// If not found with direct patterns, do a recursive search for pack files
-	// Search for {key}-pack.json or {key}.json recursively
-	packFileName := key + "-pack.json"
-	atlasFileName := key + ".json"
-
-	var foundPath string
-	var isAtlas bool
-
-	filepath.Walk(mediaPath, func(path string, info os.FileInfo, err error) error {
-		if err != nil || foundPath != "" {
-			return nil
-		}
-
-		if info.IsDir() {
-			return nil
-		}
-
-		fileName := filepath.Base(path)
-		if fileName == packFileName {
-			foundPath = path
-			isAtlas = false
-			return filepath.SkipAll
+	// Otherwise take the first {key}-pack.json, or {key}.json inside a
+	// folder named after the key, in walk order
+	var candidates []AssetLocation
+	for _, rel := range s.index.AssetsNamed(key + "-pack.json") {
+		if strings.HasPrefix(rel, "media/") {
+			candidates = append(candidates, assetLocation(rel, false))
 		}
-
-		// Check if it's an atlas (but not a pack file)
-		if fileName == atlasFileName && !strings.HasSuffix(filepath.Dir(path), "/"+key) {
-			// Make sure this is actually an atlas directory structure
-			// (atlas files are in directories with the same name as the key)
-			parentDir := filepath.Base(filepath.Dir(path))
-			if parentDir == key {
-				foundPath = path
-				isAtlas = true
-				return filepath.SkipAll
-			}
+	}
+	for _, rel := range s.index.AssetsNamed(key + ".json") {
+		if strings.HasPrefix(rel, "media/") && path.Base(path.Dir(rel)) == key {
+			candidates = append(candidates, assetLocation(rel, true))
 		}
+	}
+	if len(candidates) == 0 {
+		return AssetLocation{Found: false}
+	}
 
-		return nil
+	// Compare folder by folder, the order the walk visited them in
+	return slices.MinFunc(candidates, func(a, b AssetLocation) int {
+		return services.CompareTreePaths(a.Path, b.Path)
 	})
+}
 
-	if foundPath != "" {
-		if location, found := checkAssetFile(foundPath, absAssetsPath, isAtlas); found {
-			w.Header().Set("Content-Type", "application/json")
-			json.NewEncoder(w).Encode(location)
-			return
-		}
-	}
-
-	// Not found
-	location := AssetLocation{
-		Found: false,
+// GetAssetReport lists the textures, frames and preload packs scenes refer
+// to that do not exist, and the packs, textures and files no scene uses,
+// with the bytes deleting them would free
+func (s *Server) GetAssetReport(w http.ResponseWriter, r *http.Request) {
+	report, err := services.BuildAssetReport(s.scenes, s.index, s.textures.Textures())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
 	}
 
-	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(location)
+	writeJSON(w, http.StatusOK, report)
 }
@@ -0,0 +1,54 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"tuxedo-core/models"
+	"tuxedo-core/services"
+
+	"github.com/gorilla/mux"
+)
+
+// sceneBoundsResponse lists the world transform and bounds of every object
+type sceneBoundsResponse struct {
+	Scene   string                  `json:"scene"`
+	Bounds  *services.Rect          `json:"bounds"` // All visible objects; nil when none has a size
+	Objects []services.ObjectBounds `json:"objects"`
+}
+
+// GetSceneBounds computes the world transform and axis-aligned bounds of
+// each object of a scene, for fit-to-view, selection and hit testing.
+// Prefab instances are expanded first, so their objects are listed with
+// "instance/child" IDs.
+func (s *Server) GetSceneBounds(w http.ResponseWriter, r *http.Request) {
+	name := mux.Vars(r)["name"]
+
+	if _, err := s.scenePath(name); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	data, err := s.scenes.ReadSceneFile(name)
+	if err != nil {
+		http.Error(w, "Scene not found", http.StatusNotFound)
+		return
+	}
+	var scene models.Scene
+	if err := json.Unmarshal(data, &scene); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if err := s.scenes.ExpandPrefabs(name, &scene); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	objects, err := scene.TypedObjects()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	bounds, sceneBounds := services.ComputeBounds(objects, s.textures.Textures())
+	writeJSON(w, http.StatusOK, sceneBoundsResponse{Scene: name, Bounds: sceneBounds, Objects: bounds})
+}
@@ -0,0 +1,40 @@
+package handlers
+
+import (
+	"errors"
+	"io/fs"
+	"net/http"
+
+	"tuxedo-core/compiler"
+
+	"github.com/gorilla/mux"
+)
+
+// CompileScene generates the JavaScript class for a scene next to its
+// .scene file, keeping the user code regions of the previous output
+func (s *Server) CompileScene(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	name := vars["name"]
+
+	if _, err := s.scenePath(name); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	result, err := s.compiler.Compile(name)
+	if err != nil {
+		switch {
+		case errors.Is(err, fs.ErrNotExist):
+			http.Error(w, "Scene not found", http.StatusNotFound)
+		case errors.Is(err, compiler.ErrNotGenerated):
+			http.Error(w, err.Error(), http.StatusConflict)
+		case errors.Is(err, compiler.ErrInvalidScene):
+			http.Error(w, err.Error(), http.StatusUnprocessableEntity)
+		default:
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+		}
+		return
+	}
+
+	writeJSON(w, http.StatusOK, map[string]string{"status": "compiled", "scene": result.Scene, "output": result.Output})
+}
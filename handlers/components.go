@@ -0,0 +1,51 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+
+	"tuxedo-core/models"
+	"tuxedo-core/services"
+
+	"github.com/gorilla/mux"
+)
+
+// componentList is the response of GetComponents
+type componentList struct {
+	Components []models.ComponentDefinition  `json:"components"`
+	Errors     []services.ComponentFileError `json:"errors"` // Component files that could not be used
+}
+
+// GetComponents lists the user component definitions of the project
+func (s *Server) GetComponents(w http.ResponseWriter, r *http.Request) {
+	errs := s.components.Errors()
+	if errs == nil {
+		errs = []services.ComponentFileError{}
+	}
+	writeJSON(w, http.StatusOK, componentList{Components: s.components.Definitions(), Errors: errs})
+}
+
+// GetComponent returns the definition of one user component
+func (s *Server) GetComponent(w http.ResponseWriter, r *http.Request) {
+	definition, ok := s.components.Definition(mux.Vars(r)["name"])
+	if !ok {
+		http.Error(w, "Component not found", http.StatusNotFound)
+		return
+	}
+	writeJSON(w, http.StatusOK, definition)
+}
+
+// writeSceneWriteError answers a failed scene write: 422 listing the
+// problems when the scene would not match its component definitions, 500
+// otherwise
+func writeSceneWriteError(w http.ResponseWriter, err error) {
+	var checkErr *services.ComponentCheckError
+	if !errors.As(err, &checkErr) {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	writeJSON(w, http.StatusUnprocessableEntity, map[string]any{
+		"error":  "scene " + checkErr.Scene + " does not match its component definitions",
+		"errors": checkErr.Errors,
+	})
+}
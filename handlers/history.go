@@ -0,0 +1,104 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+
+	"tuxedo-core/services"
+
+	"github.com/gorilla/mux"
+)
+
+// GetSceneHistory lists the stored revisions of a scene, newest first
+func (s *Server) GetSceneHistory(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	name := vars["name"]
+
+	history, ok := s.sceneHistory(w, name)
+	if !ok {
+		return
+	}
+
+	revisions, err := history.List(name)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, revisions)
+}
+
+// GetSceneRevision returns the scene as it was stored in one revision
+func (s *Server) GetSceneRevision(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	name := vars["name"]
+
+	history, ok := s.sceneHistory(w, name)
+	if !ok {
+		return
+	}
+
+	data, err := history.Read(name, vars["revision"])
+	if errors.Is(err, services.ErrRevisionNotFound) {
+		http.Error(w, "Revision not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("ETag", services.ETag(data))
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(data)
+}
+
+// RestoreSceneRevision makes a revision the current version of the scene.
+// The version being replaced is itself kept in the history.
+func (s *Server) RestoreSceneRevision(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	name := vars["name"]
+	revision := vars["revision"]
+
+	history, ok := s.sceneHistory(w, name)
+	if !ok {
+		return
+	}
+
+	unlock := s.scenes.LockScenes(name)
+	defer unlock()
+
+	data, err := history.Read(name, revision)
+	if errors.Is(err, services.ErrRevisionNotFound) {
+		http.Error(w, "Revision not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	if err := s.scenes.WriteSceneFile(name, data); err != nil {
+		writeSceneWriteError(w, err)
+		return
+	}
+
+	w.Header().Set("ETag", services.ETag(data))
+	writeJSON(w, http.StatusOK, map[string]string{"status": "restored", "revision": revision})
+}
+
+// sceneHistory validates the scene name and returns the revision store,
+// writing the error response when either is unavailable
+func (s *Server) sceneHistory(w http.ResponseWriter, name string) (*services.HistoryStore, bool) {
+	if _, err := s.scenePath(name); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return nil, false
+	}
+
+	history := s.scenes.History()
+	if history == nil {
+		http.Error(w, "Scene history is disabled", http.StatusNotFound)
+		return nil, false
+	}
+	return history, true
+}
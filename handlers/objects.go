@@ -0,0 +1,244 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"os"
+
+	"tuxedo-core/models"
+	"tuxedo-core/services"
+
+	"github.com/google/uuid"
+	"github.com/gorilla/mux"
+)
+
+// objectPlacement is the request body for creating or moving an object.
+// Parent is the ID of a Container or Layer, empty for the display list;
+// a missing or out of range index puts the object on top.
+type objectPlacement struct {
+	Parent string             `json:"parent"`
+	Index  *int               `json:"index"`
+	Object *models.GameObject `json:"object,omitempty"`
+}
+
+func (p objectPlacement) location() models.ObjectLocation {
+	loc := models.ObjectLocation{Parent: p.Parent, Index: -1}
+	if p.Index != nil {
+		loc.Index = *p.Index
+	}
+	return loc
+}
+
+// objectResponse reports an object edit along with where the object ended up
+type objectResponse struct {
+	Status string             `json:"status,omitempty"`
+	ID     string             `json:"id"`
+	Parent string             `json:"parent"`
+	Index  int                `json:"index"`
+	Object *models.GameObject `json:"object,omitempty"`
+}
+
+// GetObject returns one object of a scene, found anywhere in the hierarchy
+func (s *Server) GetObject(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	name, id := vars["name"], vars["id"]
+
+	if _, err := s.scenePath(name); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	data, err := s.scenes.ReadSceneFile(name)
+	if err != nil {
+		http.Error(w, "Scene not found", http.StatusNotFound)
+		return
+	}
+
+	var scene models.Scene
+	if err := json.Unmarshal(data, &scene); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	obj, loc, ok := scene.FindObject(id)
+	if !ok {
+		http.Error(w, "Object not found", http.StatusNotFound)
+		return
+	}
+
+	w.Header().Set("ETag", services.ETag(data))
+	writeJSON(w, http.StatusOK, objectResponse{ID: id, Parent: loc.Parent, Index: loc.Index, Object: obj})
+}
+
+// CreateObject adds an object to a scene under the given parent and index.
+// An object without an ID gets a new UUID.
+func (s *Server) CreateObject(w http.ResponseWriter, r *http.Request) {
+	name := mux.Vars(r)["name"]
+
+	var req objectPlacement
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if req.Object == nil {
+		http.Error(w, "Object is required", http.StatusBadRequest)
+		return
+	}
+	obj := *req.Object
+	if obj.ID == "" {
+		obj.ID = uuid.NewString()
+	}
+
+	s.editScene(w, r, name, http.StatusCreated, func(scene *models.Scene) (*objectResponse, error) {
+		if err := scene.InsertObject(obj, req.location()); err != nil {
+			return nil, err
+		}
+		return placedObject(scene, "created", obj.ID), nil
+	})
+}
+
+// UpdateObject replaces an object in place. Children are kept when the
+// body has no "list".
+func (s *Server) UpdateObject(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	name, id := vars["name"], vars["id"]
+
+	var obj models.GameObject
+	if err := json.NewDecoder(r.Body).Decode(&obj); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if obj.ID != "" && obj.ID != id {
+		http.Error(w, "Object ID cannot be changed", http.StatusBadRequest)
+		return
+	}
+	obj.ID = id
+
+	s.editScene(w, r, name, http.StatusOK, func(scene *models.Scene) (*objectResponse, error) {
+		current, _, ok := scene.FindObject(id)
+		if !ok {
+			return nil, models.ErrObjectNotFound
+		}
+		if obj.List == nil {
+			obj.List = current.List
+		}
+		*current = obj
+
+		ids := map[string]bool{}
+		duplicate := false
+		scene.WalkObjects(func(o *models.GameObject) {
+			duplicate = duplicate || ids[o.ID]
+			ids[o.ID] = true
+		})
+		if duplicate {
+			return nil, models.ErrDuplicateObjectID
+		}
+		return placedObject(scene, "updated", id), nil
+	})
+}
+
+// DeleteObject removes an object and its children, and drops their IDs
+// from the scene's object lists
+func (s *Server) DeleteObject(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	name, id := vars["name"], vars["id"]
+
+	s.editScene(w, r, name, http.StatusOK, func(scene *models.Scene) (*objectResponse, error) {
+		_, loc, _ := scene.FindObject(id)
+		removed, err := scene.RemoveObject(id)
+		if err != nil {
+			return nil, err
+		}
+		return &objectResponse{Status: "deleted", ID: id, Parent: loc.Parent, Index: loc.Index, Object: &removed}, nil
+	})
+}
+
+// MoveObject moves an object to another parent and/or z-index. Its object
+// list memberships are kept.
+func (s *Server) MoveObject(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	name, id := vars["name"], vars["id"]
+
+	var req objectPlacement
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	s.editScene(w, r, name, http.StatusOK, func(scene *models.Scene) (*objectResponse, error) {
+		if err := scene.MoveObject(id, req.location()); err != nil {
+			return nil, err
+		}
+		resp := placedObject(scene, "moved", id)
+		resp.Object = nil
+		return resp, nil
+	})
+}
+
+func placedObject(scene *models.Scene, status, id string) *objectResponse {
+	obj, loc, _ := scene.FindObject(id)
+	return &objectResponse{Status: status, ID: id, Parent: loc.Parent, Index: loc.Index, Object: obj}
+}
+
+// editScene loads a scene under its lock, applies edit and saves the
+// result atomically. If-Match is honoured as for PUT.
+func (s *Server) editScene(w http.ResponseWriter, r *http.Request, name string, status int, edit func(scene *models.Scene) (*objectResponse, error)) {
+	if _, err := s.scenePath(name); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	unlock := s.scenes.LockScenes(name)
+	defer unlock()
+
+	current, err := s.scenes.ReadSceneFile(name)
+	if os.IsNotExist(err) {
+		http.Error(w, "Scene not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	if ifMatch := r.Header.Get("If-Match"); ifMatch != "" && !etagMatches(ifMatch, services.ETag(current), false) {
+		writeCurrentScene(w, http.StatusPreconditionFailed, current)
+		return
+	}
+
+	var scene models.Scene
+	if err := json.Unmarshal(current, &scene); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	resp, err := edit(&scene)
+	if err != nil {
+		switch {
+		case errors.Is(err, models.ErrObjectNotFound):
+			http.Error(w, "Object not found", http.StatusNotFound)
+		case errors.Is(err, models.ErrDuplicateObjectID):
+			http.Error(w, err.Error(), http.StatusConflict)
+		case errors.Is(err, models.ErrInvalidParent):
+			http.Error(w, err.Error(), http.StatusUnprocessableEntity)
+		default:
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+		}
+		return
+	}
+
+	prettyJSON, err := scene.Encode()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	if err := s.scenes.WriteSceneFile(name, prettyJSON); err != nil {
+		writeSceneWriteError(w, err)
+		return
+	}
+
+	w.Header().Set("ETag", services.ETag(prettyJSON))
+	writeJSON(w, status, resp)
+}
@@ -0,0 +1,155 @@
+package handlers
+
+import (
+	"net/http"
+	"path"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"tuxedo-core/models"
+	"tuxedo-core/services"
+
+	"github.com/gorilla/mux"
+)
+
+// PackSummary is a pack file in the pack list
+type PackSummary struct {
+	Path        string   `json:"path"` // Relative to the assets directory
+	URL         string   `json:"url"`  // Relative to the web root, as listed in a scene's preloadPackFiles
+	Files       int      `json:"files"`
+	TextureKeys []string `json:"textureKeys"`
+}
+
+// PackDetail is a parsed pack file
+type PackDetail struct {
+	PackSummary
+	Sections []PackSectionDetail `json:"sections"`
+}
+
+// PackSectionDetail is a section of a pack
+type PackSectionDetail struct {
+	Name    string           `json:"name"`
+	BaseURL string           `json:"baseURL,omitempty"`
+	Path    string           `json:"path,omitempty"`
+	Prefix  string           `json:"prefix,omitempty"`
+	Files   []PackFileDetail `json:"files"`
+}
+
+// PackFileDetail is a file entry of a pack section
+type PackFileDetail struct {
+	Key     string          `json:"key"` // With the section's prefix
+	Type    string          `json:"type"`
+	Texture bool            `json:"texture"` // The entry adds a texture under its key
+	URLs    []string        `json:"urls"`    // As the loader requests them, with the section's base URL and path
+	Entry   models.PackFile `json:"entry"`   // The entry as written in the pack
+}
+
+// GetPacks lists the asset packs of the project with the texture keys each
+// provides. ?key= keeps the packs with a file of that key.
+func (s *Server) GetPacks(w http.ResponseWriter, r *http.Request) {
+	key := r.URL.Query().Get("key")
+
+	packs := []PackSummary{}
+	for _, pack := range s.index.Packs() {
+		if key != "" && !packHasKey(pack.Pack, key) {
+			continue
+		}
+		packs = append(packs, s.packSummary(pack))
+	}
+
+	writeJSON(w, http.StatusOK, packs)
+}
+
+// GetPack returns the parsed sections and entries of a pack. The path is
+// relative to the assets directory, or to the web root as in a scene's
+// preloadPackFiles.
+func (s *Server) GetPack(w http.ResponseWriter, r *http.Request) {
+	rel, ok := s.packAssetPath(mux.Vars(r)["path"])
+	if !ok {
+		http.Error(w, "Pack not found", http.StatusNotFound)
+		return
+	}
+	pack, _ := s.index.Pack(rel)
+
+	detail := PackDetail{
+		PackSummary: s.packSummary(services.PackFile{Path: rel, Pack: pack}),
+		Sections:    []PackSectionDetail{},
+	}
+	for _, section := range pack.Sections {
+		sectionDetail := PackSectionDetail{
+			Name:    section.Name,
+			BaseURL: section.BaseURL,
+			Path:    section.Path,
+			Prefix:  section.Prefix,
+			Files:   []PackFileDetail{},
+		}
+		for _, file := range section.Files {
+			urls := []string{}
+			for _, url := range file.URLs() {
+				urls = append(urls, section.ResolveURL(url))
+			}
+			sectionDetail.Files = append(sectionDetail.Files, PackFileDetail{
+				Key:     section.FileKey(&file),
+				Type:    file.Type,
+				Texture: file.IsTexture(),
+				URLs:    urls,
+				Entry:   file,
+			})
+		}
+		detail.Sections = append(detail.Sections, sectionDetail)
+	}
+
+	writeJSON(w, http.StatusOK, detail)
+}
+
+func (s *Server) packSummary(pack services.PackFile) PackSummary {
+	keys := pack.Pack.TextureKeys()
+	if keys == nil {
+		keys = []string{}
+	}
+	return PackSummary{
+		Path:        pack.Path,
+		URL:         s.assetURL(pack.Path),
+		Files:       len(pack.Pack.Files()),
+		TextureKeys: keys,
+	}
+}
+
+func packHasKey(pack *models.Pack, key string) bool {
+	for _, section := range pack.Sections {
+		if slices.ContainsFunc(section.Files, func(f models.PackFile) bool { return section.FileKey(&f) == key }) {
+			return true
+		}
+	}
+	return false
+}
+
+// packAssetPath finds the pack a path refers to, trying it first as
+// relative to the assets directory, then as relative to the web root
+func (s *Server) packAssetPath(p string) (string, bool) {
+	rel := strings.TrimPrefix(path.Clean("/"+p), "/")
+	if _, ok := s.index.Pack(rel); ok {
+		return rel, true
+	}
+
+	full := filepath.Join(s.config.Project.YukonPath, filepath.FromSlash(rel))
+	fromAssets, err := filepath.Rel(s.assetsPath, full)
+	if err != nil || strings.HasPrefix(fromAssets, "..") {
+		return "", false
+	}
+	rel = filepath.ToSlash(fromAssets)
+	_, ok := s.index.Pack(rel)
+	return rel, ok
+}
+
+// assetURL returns the web root relative URL of an asset, the form pack
+// files and scenes refer to assets by
+func (s *Server) assetURL(rel string) string {
+	full := filepath.Join(s.assetsPath, filepath.FromSlash(rel))
+	url, err := filepath.Rel(s.config.Project.YukonPath, full)
+	if err != nil || strings.HasPrefix(url, "..") {
+		return "assets/" + rel
+	}
+	return filepath.ToSlash(url)
+}
@@ -0,0 +1,177 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"os"
+	"path"
+	"strings"
+
+	"tuxedo-core/models"
+	"tuxedo-core/services"
+
+	"github.com/google/uuid"
+	"github.com/gorilla/mux"
+)
+
+// defaultPrefabFolder is where extracted prefabs go unless a folder is given
+const defaultPrefabFolder = "shared_prefabs"
+
+// extractPrefabRequest is the body of ExtractPrefab
+type extractPrefabRequest struct {
+	Objects []string `json:"objects"` // IDs of the objects to extract, all under the same parent
+	Name    string   `json:"name"`    // Scene key and file name of the new prefab
+	Folder  string   `json:"folder"`  // Folder of the new prefab, defaults to shared_prefabs
+	Label   string   `json:"label"`   // Label of the instance, defaults to the name
+}
+
+// extractPrefabResponse reports an extracted prefab
+type extractPrefabResponse struct {
+	Status   string             `json:"status"`
+	Scene    string             `json:"scene"`
+	Prefab   string             `json:"prefab"`
+	PrefabID string             `json:"prefabId"`
+	Instance *models.GameObject `json:"instance"`
+}
+
+// ExtractPrefab turns objects of a scene into a new prefab with a fresh
+// UUID and replaces them with one instance of it. The prefab takes the
+// scene's border size, preload packs and meta. Both files are saved
+// together; If-Match applies to the scene.
+func (s *Server) ExtractPrefab(w http.ResponseWriter, r *http.Request) {
+	name := mux.Vars(r)["name"]
+
+	var req extractPrefabRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if len(req.Objects) == 0 {
+		http.Error(w, "Objects are required", http.StatusBadRequest)
+		return
+	}
+	if req.Name == "" || strings.Contains(req.Name, "/") {
+		http.Error(w, "A prefab name without slashes is required", http.StatusBadRequest)
+		return
+	}
+	folder := strings.Trim(path.Clean("/"+req.Folder), "/")
+	if req.Folder == "" {
+		folder = defaultPrefabFolder
+	}
+	prefabName := strings.TrimPrefix(folder+"/"+req.Name, "/")
+	label := req.Label
+	if label == "" {
+		label = req.Name
+	}
+
+	if _, err := s.scenePath(name); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	prefabPath, err := s.scenePath(prefabName)
+	if err != nil {
+		http.Error(w, "Invalid prefab name: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	unlock := s.scenes.LockScenes(name, prefabName)
+	defer unlock()
+
+	if _, err := os.Stat(prefabPath); err == nil {
+		http.Error(w, "Scene already exists: "+prefabName, http.StatusConflict)
+		return
+	}
+
+	current, err := s.scenes.ReadSceneFile(name)
+	if os.IsNotExist(err) {
+		http.Error(w, "Scene not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if ifMatch := r.Header.Get("If-Match"); ifMatch != "" && !etagMatches(ifMatch, services.ETag(current), false) {
+		writeCurrentScene(w, http.StatusPreconditionFailed, current)
+		return
+	}
+
+	var scene models.Scene
+	if err := json.Unmarshal(current, &scene); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	prefab := &models.Scene{
+		ID:        uuid.NewString(),
+		SceneType: "PREFAB",
+		Settings: models.SceneSettings{
+			SceneKey:     req.Name,
+			BorderWidth:  scene.Settings.BorderWidth,
+			BorderHeight: scene.Settings.BorderHeight,
+			PreloadPacks: scene.Settings.PreloadPacks,
+		},
+	}
+	instance, err := scene.ExtractPrefab(req.Objects, prefab, models.PrefabExtraction{
+		InstanceID: uuid.NewString(),
+		RootID:     uuid.NewString(),
+		Label:      label,
+	})
+	switch {
+	case errors.Is(err, models.ErrObjectNotFound):
+		http.Error(w, "Object not found", http.StatusNotFound)
+		return
+	case errors.Is(err, models.ErrNotSiblings):
+		http.Error(w, err.Error(), http.StatusUnprocessableEntity)
+		return
+	case err != nil:
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	sceneJSON, err := scene.Encode()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	prefabJSON, err := encodeExtractedPrefab(prefab, &scene)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if err := s.scenes.WriteSceneFiles(map[string][]byte{name: sceneJSON, prefabName: prefabJSON}); err != nil {
+		writeSceneWriteError(w, err)
+		return
+	}
+
+	w.Header().Set("ETag", services.ETag(sceneJSON))
+	writeJSON(w, http.StatusCreated, extractPrefabResponse{
+		Status:   "extracted",
+		Scene:    name,
+		Prefab:   prefabName,
+		PrefabID: prefab.ID,
+		Instance: instance,
+	})
+}
+
+// encodeExtractedPrefab encodes the new prefab with the scene's meta.
+// The prefab is decoded once more so meta follows the modelled fields, as
+// in files Phaser Editor writes.
+func encodeExtractedPrefab(prefab, scene *models.Scene) ([]byte, error) {
+	data, err := prefab.Encode()
+	if err != nil {
+		return nil, err
+	}
+	meta, ok := scene.Extra.Get("meta")
+	if !ok {
+		return data, nil
+	}
+
+	var decoded models.Scene
+	if err := json.Unmarshal(data, &decoded); err != nil {
+		return nil, err
+	}
+	decoded.Extra.SetRaw("meta", meta)
+	return decoded.Encode()
+}
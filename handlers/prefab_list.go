@@ -0,0 +1,103 @@
+package handlers
+
+import (
+	"net/http"
+	"slices"
+	"strings"
+
+	"tuxedo-core/models"
+	"tuxedo-core/services"
+)
+
+// PrefabListEntry describes one prefab in the prefab palette
+type PrefabListEntry struct {
+	ID         string          `json:"id"`
+	Name       string          `json:"name"` // Scene path, e.g. shared_prefabs/Sign
+	Folder     string          `json:"folder"`
+	SceneKey   string          `json:"sceneKey"`
+	Label      string          `json:"label"`               // Label of the root object
+	Type       string          `json:"type"`                // Type of the root object, resolved through variants
+	VariantOf  string          `json:"variantOf,omitempty"` // Prefab a variant is based on
+	Texture    *models.Texture `json:"texture,omitempty"`   // Texture to show as preview
+	Components []string        `json:"components"`
+}
+
+// GetPrefabs lists every prefab, sorted by name. Query parameters:
+// folder (prefix) and q (substring of the name, scene key or root label).
+func (s *Server) GetPrefabs(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	folder := strings.Trim(query.Get("folder"), "/")
+	search := strings.ToLower(query.Get("q"))
+
+	prefabs := []PrefabListEntry{}
+	for _, entry := range s.index.Scenes() {
+		if entry.SceneType != "PREFAB" || entry.Root == nil {
+			continue
+		}
+		if folder != "" && entry.Folder() != folder && !strings.HasPrefix(entry.Folder(), folder+"/") {
+			continue
+		}
+		if search != "" && !matchesPrefabSearch(&entry, search) {
+			continue
+		}
+		prefabs = append(prefabs, s.prefabListEntry(&entry))
+	}
+
+	writeJSON(w, http.StatusOK, prefabs)
+}
+
+func matchesPrefabSearch(entry *services.SceneEntry, search string) bool {
+	for _, field := range []string{entry.Name, entry.SceneKey, entry.Root.Label} {
+		if strings.Contains(strings.ToLower(field), search) {
+			return true
+		}
+	}
+	return false
+}
+
+// prefabListEntry describes a prefab. The type, preview texture and
+// components of a variant are completed from the prefabs it is based on.
+func (s *Server) prefabListEntry(entry *services.SceneEntry) PrefabListEntry {
+	root := entry.Root
+	item := PrefabListEntry{
+		ID:         entry.ID,
+		Name:       entry.Name,
+		Folder:     entry.Folder(),
+		SceneKey:   entry.SceneKey,
+		Label:      root.Label,
+		Type:       root.Type,
+		VariantOf:  root.PrefabID,
+		Texture:    root.Texture,
+		Components: slices.Clone(root.Components),
+	}
+
+	seen := map[string]bool{entry.ID: true}
+	for base := root.PrefabID; base != "" && !seen[base]; {
+		seen[base] = true
+		name, ok := s.index.PrefabScene(base)
+		if !ok {
+			break
+		}
+		baseEntry, ok := s.index.Scene(name)
+		if !ok || baseEntry.Root == nil {
+			break
+		}
+		if item.Type == "" {
+			item.Type = baseEntry.Root.Type
+		}
+		if item.Texture == nil {
+			item.Texture = baseEntry.Root.Texture
+		}
+		for _, component := range baseEntry.Root.Components {
+			if !slices.Contains(item.Components, component) {
+				item.Components = append(item.Components, component)
+			}
+		}
+		base = baseEntry.Root.PrefabID
+	}
+
+	if item.Components == nil {
+		item.Components = []string{}
+	}
+	return item
+}
@@ -0,0 +1,212 @@
+package handlers
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"os"
+	"slices"
+
+	"tuxedo-core/models"
+	"tuxedo-core/services"
+
+	"github.com/gorilla/mux"
+)
+
+// overridesRequest is the optional body of the apply and revert
+// operations. Without properties every unlocked property but the position
+// is used.
+type overridesRequest struct {
+	Properties []string `json:"properties"`
+}
+
+// overridesResponse reports an apply or revert operation
+type overridesResponse struct {
+	Status     string                 `json:"status"`
+	Scene      string                 `json:"scene"`
+	ObjectID   string                 `json:"objectId"`
+	Prefab     string                 `json:"prefab"`
+	PrefabID   string                 `json:"prefabId"`
+	Properties []string               `json:"properties"`
+	Object     *models.GameObject     `json:"object"`             // The instance after the operation
+	Affected   []services.PrefabUsage `json:"affected,omitempty"` // Other instances that now see the applied values
+}
+
+// ApplyOverrides writes an instance's unlocked properties into its prefab
+// and locks them on the instance again. The prefab and the scene are saved
+// together, and the other instances that pick up the new values are
+// reported.
+func (s *Server) ApplyOverrides(w http.ResponseWriter, r *http.Request) {
+	s.editOverrides(w, r, true)
+}
+
+// RevertOverrides resets an instance's unlocked properties to the prefab's
+// values
+func (s *Server) RevertOverrides(w http.ResponseWriter, r *http.Request) {
+	s.editOverrides(w, r, false)
+}
+
+func (s *Server) editOverrides(w http.ResponseWriter, r *http.Request, apply bool) {
+	vars := mux.Vars(r)
+	name, id := vars["name"], vars["id"]
+
+	var req overridesRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil && err != io.EOF {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if _, err := s.scenePath(name); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	// Find the prefab first so both files can be locked together
+	scene, err := s.scenes.LoadScene(name)
+	if os.IsNotExist(err) {
+		http.Error(w, "Scene not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	instance, _, ok := scene.FindObject(id)
+	if !ok {
+		http.Error(w, "Object not found", http.StatusNotFound)
+		return
+	}
+	prefabID := instance.PrefabId
+	if prefabID == "" {
+		http.Error(w, models.ErrNotInstance.Error(), http.StatusUnprocessableEntity)
+		return
+	}
+	prefabName, ok := s.index.PrefabScene(prefabID)
+	if !ok {
+		http.Error(w, "Prefab not found: "+prefabID, http.StatusUnprocessableEntity)
+		return
+	}
+	if apply && prefabName == name {
+		http.Error(w, "Prefab contains an instance of itself", http.StatusUnprocessableEntity)
+		return
+	}
+
+	locked := []string{name}
+	if apply {
+		locked = append(locked, prefabName)
+	}
+	unlock := s.scenes.LockScenes(locked...)
+	defer unlock()
+
+	current, err := s.scenes.ReadSceneFile(name)
+	if err != nil {
+		http.Error(w, "Scene not found", http.StatusNotFound)
+		return
+	}
+	if ifMatch := r.Header.Get("If-Match"); ifMatch != "" && !etagMatches(ifMatch, services.ETag(current), false) {
+		writeCurrentScene(w, http.StatusPreconditionFailed, current)
+		return
+	}
+	scene = &models.Scene{}
+	if err := json.Unmarshal(current, scene); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	instance, _, ok = scene.FindObject(id)
+	if !ok {
+		http.Error(w, "Object not found", http.StatusNotFound)
+		return
+	}
+	if instance.PrefabId != prefabID {
+		http.Error(w, "Object changed while it was being edited, try again", http.StatusConflict)
+		return
+	}
+
+	properties, err := instance.Overrides(req.Properties)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusUnprocessableEntity)
+		return
+	}
+
+	files := map[string][]byte{}
+	if apply {
+		prefab, err := s.scenes.LoadScene(prefabName)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		if len(prefab.DisplayList) == 0 {
+			http.Error(w, "Prefab has no root object", http.StatusUnprocessableEntity)
+			return
+		}
+		if err := models.ApplyOverrides(&prefab.DisplayList[0], instance, properties); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		if files[prefabName], err = prefab.Encode(); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+	}
+	if err := models.RevertOverrides(instance, properties); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if files[name], err = scene.Encode(); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	if err := s.scenes.WriteSceneFiles(files); err != nil {
+		writeSceneWriteError(w, err)
+		return
+	}
+
+	resp := overridesResponse{
+		Status:     "reverted",
+		Scene:      name,
+		ObjectID:   id,
+		Prefab:     prefabName,
+		PrefabID:   prefabID,
+		Properties: properties,
+		Object:     instance,
+	}
+	if apply {
+		resp.Status = "applied"
+		usages, err := s.scenes.PrefabUsages(prefabID)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		resp.Affected = affectedInstances(usages, name, id, properties)
+	}
+
+	w.Header().Set("ETag", services.ETag(files[name]))
+	writeJSON(w, http.StatusOK, resp)
+}
+
+// affectedInstances keeps the instances that see the applied properties:
+// all but the instance the values came from and direct instances that
+// override every one of them themselves
+func affectedInstances(usages []services.PrefabUsage, scene, objectID string, properties []string) []services.PrefabUsage {
+	affected := []services.PrefabUsage{}
+	for _, usage := range usages {
+		usage.Instances = slices.DeleteFunc(usage.Instances, func(instance services.PrefabInstance) bool {
+			if usage.Scene == scene && instance.ObjectID == objectID {
+				return true
+			}
+			if len(instance.Via) > 0 {
+				return false
+			}
+			for _, property := range properties {
+				if !slices.Contains(instance.Unlock, property) {
+					return false
+				}
+			}
+			return true
+		})
+		if len(usage.Instances) > 0 {
+			affected = append(affected, usage)
+		}
+	}
+	return affected
+}
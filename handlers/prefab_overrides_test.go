@@ -0,0 +1,97 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+
+	"tuxedo-core/config"
+	"tuxedo-core/models"
+)
+
+const signPrefab = `{"id": "p-sign", "sceneType": "PREFAB", "settings": {"sceneKey": "Sign"}, "displayList": [
+	{"type": "Image", "id": "s1", "label": "sign", "texture": {"key": "town", "frame": "sign"}}
+]}`
+
+func readTestScene(t *testing.T, cfg *config.Config, name string) *models.Scene {
+	t.Helper()
+	data, err := os.ReadFile(filepath.Join(cfg.GetScenesPath(), filepath.FromSlash(name)+".scene"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var scene models.Scene
+	if err := json.Unmarshal(data, &scene); err != nil {
+		t.Fatal(err)
+	}
+	return &scene
+}
+
+// postOverrides sends an apply or revert request without a body
+func postOverrides(t *testing.T, url string) overridesResponse {
+	t.Helper()
+	resp, err := http.Post(url, "application/json", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("POST %s: %s", url, resp.Status)
+	}
+	var result overridesResponse
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		t.Fatal(err)
+	}
+	return result
+}
+
+func TestApplyAllOverridesKeepsThePosition(t *testing.T) {
+	ts, cfg := newProjectServer(t, map[string]string{
+		"prefabs/Sign": signPrefab,
+		"rooms/Town": `{"id": "s-town", "sceneType": "SCENE", "settings": {"sceneKey": "Town"}, "displayList": [
+			{"prefabId": "p-sign", "id": "i1", "label": "sign", "unlock": ["x", "y", "alpha"], "x": 300, "y": 400, "alpha": 0.5}
+		]}`,
+	})
+
+	result := postOverrides(t, ts.URL+"/api/scenes/rooms/Town/objects/i1/apply")
+	if !slices.Equal(result.Properties, []string{"alpha"}) {
+		t.Errorf("applied properties = %v, want [alpha]", result.Properties)
+	}
+
+	root := readTestScene(t, cfg, "prefabs/Sign").DisplayList[0]
+	if root.X != 0 || root.Y != 0 {
+		t.Errorf("prefab root moved to %v, %v", root.X, root.Y)
+	}
+	if alpha, ok := root.Properties.Get("alpha"); !ok || string(alpha) != "0.5" {
+		t.Errorf("prefab root alpha = %s, want 0.5", alpha)
+	}
+
+	instance := readTestScene(t, cfg, "rooms/Town").DisplayList[0]
+	if instance.X != 300 || instance.Y != 400 || !slices.Equal(instance.Unlock, []string{"x", "y"}) {
+		t.Errorf("instance at %v, %v unlocking %v, want 300, 400 unlocking [x y]", instance.X, instance.Y, instance.Unlock)
+	}
+}
+
+func TestRevertAllOverridesKeepsThePosition(t *testing.T) {
+	ts, cfg := newProjectServer(t, map[string]string{
+		"prefabs/Sign": signPrefab,
+		"rooms/Town": `{"id": "s-town", "sceneType": "SCENE", "settings": {"sceneKey": "Town"}, "displayList": [
+			{"prefabId": "p-sign", "id": "i1", "label": "sign", "unlock": ["x", "angle", "y"], "x": 300, "y": 400, "angle": 30}
+		]}`,
+	})
+
+	result := postOverrides(t, ts.URL+"/api/scenes/rooms/Town/objects/i1/revert")
+	if !slices.Equal(result.Properties, []string{"angle"}) {
+		t.Errorf("reverted properties = %v, want [angle]", result.Properties)
+	}
+
+	instance := readTestScene(t, cfg, "rooms/Town").DisplayList[0]
+	if instance.X != 300 || instance.Y != 400 || instance.Angle != nil {
+		t.Errorf("instance at %v, %v with angle %v, want 300, 400 without angle", instance.X, instance.Y, instance.Angle)
+	}
+	if !slices.Equal(instance.Unlock, []string{"x", "y"}) {
+		t.Errorf("instance unlocks %v, want [x y]", instance.Unlock)
+	}
+}
@@ -4,8 +4,6 @@ import (
 	"encoding/json"
 	"net/http"
 	"os"
-	"path/filepath"
-	"strings"
 
 	"tuxedo-core/models"
 
@@ -14,7 +12,7 @@ import (
 
 // GetPrefab retrieves a prefab scene by its ID
 // Prefabs are stored in shared_prefabs directory and identified by the "id" field in the scene file
-func GetPrefab(w http.ResponseWriter, r *http.Request) {
+func (s *Server) GetPrefab(w http.ResponseWriter, r *http.Request) {
 	vars := mux.Vars(r)
 	prefabId := vars["id"]
 
@@ -24,7 +22,7 @@ func GetPrefab(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// Search for the prefab file by ID
-	prefabPath, err := findPrefabById(prefabId)
+	prefabPath, err := s.findPrefabById(prefabId)
 	if err != nil {
 		http.Error(w, "Prefab not found: "+err.Error(), http.StatusNotFound)
 		return
@@ -54,52 +52,31 @@ func GetPrefab(w http.ResponseWriter, r *http.Request) {
 	w.Write(data)
 }
 
-// findPrefabById searches for a prefab file with the given ID
-// It searches in all scene directories, not just shared_prefabs
-// TODO: better logging and error handling for file access issues
-// TODO: consider caching prefab paths for faster lookups
-func findPrefabById(prefabId string) (string, error) {
-	// Start from the root scenes directory to search everywhere
-	scenesPath := projectPath
-	var foundPath string
+// GetPrefabUsages lists the scenes and prefabs that instantiate a prefab,
+// directly or through nested prefabs, with the instances in each
+func (s *Server) GetPrefabUsages(w http.ResponseWriter, r *http.Request) {
+	prefabId := mux.Vars(r)["id"]
 
-	err := filepath.Walk(scenesPath, func(path string, info os.FileInfo, err error) error {
-		if err != nil {
-			return err
-		}
-
-		// Skip if not a .scene file
-		if !strings.HasSuffix(path, ".scene") {
-			return nil
-		}
-
-		// Read and check if ID matches
-		data, err := os.ReadFile(path)
-		if err != nil {
-			return nil // Skip files we can't read
-		}
-
-		var scene models.Scene
-		if err := json.Unmarshal(data, &scene); err != nil {
-			return nil // Skip invalid JSON files
-		}
-
-		// Check if this is the prefab we're looking for
-		if scene.ID == prefabId && scene.SceneType == "PREFAB" {
-			foundPath = path
-			return filepath.SkipAll // Stop walking once found
-		}
-
-		return nil
-	})
+	if _, ok := s.index.PrefabScene(prefabId); !ok {
+		http.Error(w, "Prefab not found", http.StatusNotFound)
+		return
+	}
 
+	usages, err := s.scenes.PrefabUsages(prefabId)
 	if err != nil {
-		return "", err
+		http.Error(w, "Error finding prefab usages: "+err.Error(), http.StatusInternalServerError)
+		return
 	}
 
-	if foundPath == "" {
+	writeJSON(w, http.StatusOK, usages)
+}
+
+// findPrefabById returns the path of the prefab file with the given ID
+// from the project index
+func (s *Server) findPrefabById(prefabId string) (string, error) {
+	name, ok := s.index.PrefabScene(prefabId)
+	if !ok {
 		return "", os.ErrNotExist
 	}
-
-	return foundPath, nil
+	return s.scenePath(name)
 }
@@ -3,7 +3,6 @@ package handlers
 import (
 	"encoding/json"
 	"net/http"
-	"os"
 	"path/filepath"
 )
 
@@ -14,37 +13,17 @@ type ProjectInfo struct {
 	Folders    []string `json:"folders"`
 }
 
-func GetProjectInfo(w http.ResponseWriter, r *http.Request) {
+func (s *Server) GetProjectInfo(w http.ResponseWriter, r *http.Request) {
 	info := ProjectInfo{
 		Name:    "Club Penguin",
-		Path:    projectPath,
+		Path:    s.scenesPath,
 		Folders: []string{},
 	}
 
-	// Count scenes
-	sceneCount := 0
-	err := filepath.Walk(projectPath, func(path string, fileInfo os.FileInfo, err error) error {
-		if err != nil {
-			return err
-		}
-
-		if fileInfo.IsDir() && path != projectPath {
-			relPath, _ := filepath.Rel(projectPath, path)
-			info.Folders = append(info.Folders, relPath)
-		}
-
-		if filepath.Ext(path) == ".scene" {
-			sceneCount++
-		}
-		return nil
-	})
-
-	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
-		return
+	for _, folder := range s.index.SceneFolders() {
+		info.Folders = append(info.Folders, filepath.FromSlash(folder))
 	}
-
-	info.SceneCount = sceneCount
+	info.SceneCount = len(s.index.SceneNames())
 
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(info)
@@ -0,0 +1,205 @@
+package handlers
+
+import (
+	"cmp"
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"tuxedo-core/services"
+)
+
+const (
+	defaultSceneListLimit = 100
+	maxSceneListLimit     = 1000
+)
+
+// SceneListEntry describes one scene in the scene browser
+type SceneListEntry struct {
+	Name         string    `json:"name"`
+	Folder       string    `json:"folder"`
+	ID           string    `json:"id"`
+	SceneType    string    `json:"sceneType"`
+	SceneKey     string    `json:"sceneKey"`
+	ObjectCount  int       `json:"objectCount"`
+	PreloadPacks []string  `json:"preloadPacks"`
+	Size         int64     `json:"size"`
+	ModTime      time.Time `json:"modTime"`
+	Error        string    `json:"error,omitempty"`
+}
+
+// SceneList is one page of the scene listing
+type SceneList struct {
+	Scenes     []SceneListEntry `json:"scenes"`
+	Total      int              `json:"total"` // Scenes matching the filters, across all pages
+	NextCursor string           `json:"nextCursor,omitempty"`
+}
+
+// sceneListCursor marks the last scene of a page. It carries the sort
+// values so the next page starts after it even if scenes were added or
+// removed in between.
+type sceneListCursor struct {
+	Sort        string    `json:"s"`
+	Order       string    `json:"o"`
+	Name        string    `json:"n"`
+	SceneType   string    `json:"t,omitempty"`
+	ObjectCount int       `json:"c,omitempty"`
+	Size        int64     `json:"z,omitempty"`
+	ModTime     time.Time `json:"m"`
+}
+
+// sceneSorts compares scenes by each supported sort key. Ties are broken
+// by name so the order, and therefore pagination, is stable.
+var sceneSorts = map[string]func(a, b *services.SceneEntry) int{
+	"name":        func(a, b *services.SceneEntry) int { return 0 },
+	"type":        func(a, b *services.SceneEntry) int { return strings.Compare(a.SceneType, b.SceneType) },
+	"objectCount": func(a, b *services.SceneEntry) int { return cmp.Compare(a.ObjectCount, b.ObjectCount) },
+	"size":        func(a, b *services.SceneEntry) int { return cmp.Compare(a.Size, b.Size) },
+	"modTime":     func(a, b *services.SceneEntry) int { return a.ModTime.Compare(b.ModTime) },
+}
+
+// sceneListParams are the query parameters that ask GetScenes for the
+// paginated listing with metadata
+var sceneListParams = []string{"type", "folder", "q", "sort", "order", "limit", "cursor"}
+
+// GetScenes lists scenes. Without query parameters it returns every scene
+// name as a plain array, as it always has. With any of type (SCENE or
+// PREFAB), folder (prefix), q (name substring), sort (name, type,
+// objectCount, size, modTime), order (asc or desc), limit or cursor
+// (nextCursor of the previous page) it returns a page of scenes with
+// their metadata.
+func (s *Server) GetScenes(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+
+	if !slices.ContainsFunc(sceneListParams, query.Has) {
+		names := s.index.SceneNames()
+		slices.SortFunc(names, services.CompareTreePaths)
+		writeJSON(w, http.StatusOK, names)
+		return
+	}
+
+	sortKey := cmp.Or(query.Get("sort"), "name")
+	compareKey, ok := sceneSorts[sortKey]
+	if !ok {
+		http.Error(w, "Unknown sort: "+sortKey, http.StatusBadRequest)
+		return
+	}
+	order := cmp.Or(query.Get("order"), "asc")
+	if order != "asc" && order != "desc" {
+		http.Error(w, "Order must be asc or desc", http.StatusBadRequest)
+		return
+	}
+	compare := func(a, b *services.SceneEntry) int {
+		c := cmp.Or(compareKey(a, b), strings.Compare(a.Name, b.Name))
+		if order == "desc" {
+			return -c
+		}
+		return c
+	}
+
+	limit := defaultSceneListLimit
+	if value := query.Get("limit"); value != "" {
+		n, err := strconv.Atoi(value)
+		if err != nil || n < 1 {
+			http.Error(w, "Invalid limit", http.StatusBadRequest)
+			return
+		}
+		limit = min(n, maxSceneListLimit)
+	}
+
+	var after *services.SceneEntry
+	if value := query.Get("cursor"); value != "" {
+		cursor, err := decodeSceneListCursor(value)
+		if err != nil || cursor.Sort != sortKey || cursor.Order != order {
+			http.Error(w, "Invalid cursor", http.StatusBadRequest)
+			return
+		}
+		after = &services.SceneEntry{
+			Name:        cursor.Name,
+			SceneType:   cursor.SceneType,
+			ObjectCount: cursor.ObjectCount,
+			Size:        cursor.Size,
+			ModTime:     cursor.ModTime,
+		}
+	}
+
+	sceneType := strings.ToUpper(query.Get("type"))
+	folder := strings.Trim(query.Get("folder"), "/")
+	search := strings.ToLower(query.Get("q"))
+
+	var matches []*services.SceneEntry
+	for _, entry := range s.index.Scenes() {
+		if sceneType != "" && entry.SceneType != sceneType {
+			continue
+		}
+		if folder != "" && entry.Folder() != folder && !strings.HasPrefix(entry.Folder(), folder+"/") {
+			continue
+		}
+		if search != "" && !strings.Contains(strings.ToLower(entry.Name), search) {
+			continue
+		}
+		matches = append(matches, &entry)
+	}
+	slices.SortFunc(matches, compare)
+
+	list := SceneList{Scenes: []SceneListEntry{}, Total: len(matches)}
+
+	start := 0
+	if after != nil {
+		start, _ = slices.BinarySearchFunc(matches, after, func(entry, target *services.SceneEntry) int {
+			if compare(entry, target) <= 0 {
+				return -1
+			}
+			return 1
+		})
+	}
+	end := min(start+limit, len(matches))
+
+	for _, entry := range matches[start:end] {
+		list.Scenes = append(list.Scenes, SceneListEntry{
+			Name:         entry.Name,
+			Folder:       entry.Folder(),
+			ID:           entry.ID,
+			SceneType:    entry.SceneType,
+			SceneKey:     entry.SceneKey,
+			ObjectCount:  entry.ObjectCount,
+			PreloadPacks: entry.PreloadPacks,
+			Size:         entry.Size,
+			ModTime:      entry.ModTime,
+			Error:        entry.Error,
+		})
+	}
+	if end < len(matches) {
+		last := matches[end-1]
+		list.NextCursor = encodeSceneListCursor(sceneListCursor{
+			Sort:        sortKey,
+			Order:       order,
+			Name:        last.Name,
+			SceneType:   last.SceneType,
+			ObjectCount: last.ObjectCount,
+			Size:        last.Size,
+			ModTime:     last.ModTime,
+		})
+	}
+
+	writeJSON(w, http.StatusOK, list)
+}
+
+func encodeSceneListCursor(cursor sceneListCursor) string {
+	data, _ := json.Marshal(cursor)
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+func decodeSceneListCursor(value string) (sceneListCursor, error) {
+	var cursor sceneListCursor
+	data, err := base64.RawURLEncoding.DecodeString(value)
+	if err != nil {
+		return cursor, err
+	}
+	err = json.Unmarshal(data, &cursor)
+	return cursor, err
+}
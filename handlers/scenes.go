@@ -2,140 +2,460 @@ package handlers
 
 import (
 	"encoding/json"
+	"errors"
+	"fmt"
 	"io"
+	"mime"
 	"net/http"
 	"os"
+	"path"
 	"path/filepath"
+	"slices"
+	"strings"
 
+	"tuxedo-core/jsonpatch"
 	"tuxedo-core/models"
+	"tuxedo-core/services"
 
 	"github.com/gorilla/mux"
 )
 
-var projectPath = "../yukon/src/scenes/"
-
-func GetScenes(w http.ResponseWriter, r *http.Request) {
-    scenes := []string{}
-    
-    err := filepath.Walk(projectPath, func(path string, info os.FileInfo, err error) error {
-        if filepath.Ext(path) == ".scene" {
-            // Get relative path from project root
-            relPath, _ := filepath.Rel(projectPath, path)
-            // Remove .scene extension and normalize to forward slashes
-            sceneName := filepath.ToSlash(relPath[:len(relPath)-6])
-            scenes = append(scenes, sceneName)
-        }
-        return nil
-    })
-    
-    if err != nil {
-        http.Error(w, err.Error(), http.StatusInternalServerError)
-        return
-    }
-    
-    w.Header().Set("Content-Type", "application/json")
-    json.NewEncoder(w).Encode(scenes)
+func (s *Server) GetScene(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	name := vars["name"]
+
+	if _, err := s.scenePath(name); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	expand := r.URL.Query().Get("expand")
+	if expand != "" && expand != "prefabs" {
+		http.Error(w, "Unknown expand value: "+expand, http.StatusBadRequest)
+		return
+	}
+
+	data, err := s.scenes.ReadSceneFile(name)
+	if err != nil {
+		http.Error(w, "Scene not found", http.StatusNotFound)
+		return
+	}
+
+	if expand == "prefabs" {
+		s.writeExpandedScene(w, name, data)
+		return
+	}
+
+	etag := services.ETag(data)
+	w.Header().Set("ETag", etag)
+	if etagMatches(r.Header.Get("If-None-Match"), etag, true) {
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+
+	var scene models.Scene
+	if err := json.Unmarshal(data, &scene); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, scene)
 }
 
-func GetScene(w http.ResponseWriter, r *http.Request) {
-    vars := mux.Vars(r)
-    name := vars["name"]
-    
-    scenePath := filepath.Join(projectPath, name+".scene")
-    
-    // Check if file exists
-    if _, err := os.Stat(scenePath); os.IsNotExist(err) {
-        http.Error(w, "Scene not found", http.StatusNotFound)
-        return
-    }
-    
-    data, err := os.ReadFile(scenePath)
-    if err != nil {
-        http.Error(w, "Scene not found", http.StatusNotFound)
-        return
-    }
-    
-    var scene models.Scene
-    if err := json.Unmarshal(data, &scene); err != nil {
-        http.Error(w, err.Error(), http.StatusInternalServerError)
-        return
-    }
-    
-    w.Header().Set("Content-Type", "application/json")
-    json.NewEncoder(w).Encode(scene)
+// writeExpandedScene answers with the scene after replacing its prefab
+// instances by the prefab objects. The result is a view, not the file, so
+// it carries no ETag.
+func (s *Server) writeExpandedScene(w http.ResponseWriter, name string, data []byte) {
+	var scene models.Scene
+	if err := json.Unmarshal(data, &scene); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	if err := s.scenes.ExpandPrefabs(name, &scene); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, scene)
 }
 
-func UpdateScene(w http.ResponseWriter, r *http.Request) {
-    vars := mux.Vars(r)
-    name := vars["name"]
-    
-    body, err := io.ReadAll(r.Body)
-    if err != nil {
-        http.Error(w, err.Error(), http.StatusBadRequest)
-        return
-    }
-    
-    var scene models.Scene
-    if err := json.Unmarshal(body, &scene); err != nil {
-        http.Error(w, err.Error(), http.StatusBadRequest)
-        return
-    }
-    
-    scenePath := filepath.Join(projectPath, name+".scene")
-    
-    // Pretty print JSON
-    prettyJSON, _ := json.MarshalIndent(scene, "", "    ")
-    
-    if err := os.WriteFile(scenePath, prettyJSON, 0644); err != nil {
-        http.Error(w, err.Error(), http.StatusInternalServerError)
-        return
-    }
-    
-    w.WriteHeader(http.StatusOK)
-    json.NewEncoder(w).Encode(map[string]string{"status": "success"})
+// UpdateScene replaces a scene. When the request carries If-Match, the
+// write only happens if the file still has that ETag; otherwise the
+// current version is returned with 412 so the editor can merge.
+func (s *Server) UpdateScene(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	name := vars["name"]
+
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	var scene models.Scene
+	if err := json.Unmarshal(body, &scene); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if _, err := s.scenePath(name); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	unlock := s.scenes.LockScenes(name)
+	defer unlock()
+
+	if ifMatch := r.Header.Get("If-Match"); ifMatch != "" {
+		current, err := s.scenes.ReadSceneFile(name)
+		if os.IsNotExist(err) {
+			http.Error(w, "Scene not found", http.StatusPreconditionFailed)
+			return
+		}
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		if etag := services.ETag(current); !etagMatches(ifMatch, etag, false) {
+			writeCurrentScene(w, http.StatusPreconditionFailed, current)
+			return
+		}
+	}
+
+	prettyJSON, err := scene.Encode()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	if err := s.scenes.WriteSceneFile(name, prettyJSON); err != nil {
+		writeSceneWriteError(w, err)
+		return
+	}
+
+	w.Header().Set("ETag", services.ETag(prettyJSON))
+	writeJSON(w, http.StatusOK, map[string]string{"status": "success"})
 }
 
-func CreateScene(w http.ResponseWriter, r *http.Request) {
-    body, err := io.ReadAll(r.Body)
-    if err != nil {
-        http.Error(w, err.Error(), http.StatusBadRequest)
-        return
-    }
-    
-    var scene models.Scene
-    if err := json.Unmarshal(body, &scene); err != nil {
-        http.Error(w, err.Error(), http.StatusBadRequest)
-        return
-    }
-    
-    if scene.Settings.SceneKey == "" {
-        http.Error(w, "Scene key is required", http.StatusBadRequest)
-        return
-    }
-    
-    scenePath := filepath.Join(projectPath, scene.Settings.SceneKey+".scene")
-    
-    // Check if scene already exists
-    if _, err := os.Stat(scenePath); err == nil {
-        http.Error(w, "Scene already exists", http.StatusConflict)
-        return
-    }
-    
-    // Pretty print JSON
-    prettyJSON, _ := json.MarshalIndent(scene, "", "    ")
-    
-    if err := os.WriteFile(scenePath, prettyJSON, 0644); err != nil {
-        http.Error(w, err.Error(), http.StatusInternalServerError)
-        return
-    }
-    
-    w.WriteHeader(http.StatusCreated)
-    json.NewEncoder(w).Encode(map[string]string{"status": "created", "path": scenePath})
+// PatchScene applies an RFC 6902 JSON Patch document to a scene. The
+// result must still be a valid scene; If-Match is honoured as for PUT and a
+// failing test operation is answered with 409.
+func (s *Server) PatchScene(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	name := vars["name"]
+
+	if contentType := r.Header.Get("Content-Type"); contentType != "" {
+		if mediaType, _, _ := mime.ParseMediaType(contentType); mediaType != "application/json-patch+json" && mediaType != "application/json" {
+			http.Error(w, "Content-Type must be application/json-patch+json", http.StatusUnsupportedMediaType)
+			return
+		}
+	}
+
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	patch, err := jsonpatch.Parse(body)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if _, err := s.scenePath(name); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	unlock := s.scenes.LockScenes(name)
+	defer unlock()
+
+	current, err := s.scenes.ReadSceneFile(name)
+	if os.IsNotExist(err) {
+		http.Error(w, "Scene not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	etag := services.ETag(current)
+	if ifMatch := r.Header.Get("If-Match"); ifMatch != "" && !etagMatches(ifMatch, etag, false) {
+		writeCurrentScene(w, http.StatusPreconditionFailed, current)
+		return
+	}
+
+	patched, err := patch.Apply(current)
+	if err != nil {
+		w.Header().Set("ETag", etag)
+		switch {
+		case errors.Is(err, jsonpatch.ErrTestFailed):
+			http.Error(w, err.Error(), http.StatusConflict)
+		case errors.Is(err, jsonpatch.ErrPathNotFound), errors.Is(err, jsonpatch.ErrInvalidPatch):
+			http.Error(w, err.Error(), http.StatusUnprocessableEntity)
+		default:
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+		}
+		return
+	}
+
+	var scene models.Scene
+	if err := json.Unmarshal(patched, &scene); err != nil {
+		http.Error(w, "Patched scene is invalid: "+err.Error(), http.StatusUnprocessableEntity)
+		return
+	}
+
+	prettyJSON, err := scene.Encode()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	if err := s.scenes.WriteSceneFile(name, prettyJSON); err != nil {
+		writeSceneWriteError(w, err)
+		return
+	}
+
+	w.Header().Set("ETag", services.ETag(prettyJSON))
+	writeJSON(w, http.StatusOK, map[string]string{"status": "success"})
 }
 
-func WebSocketHandler(w http.ResponseWriter, r *http.Request) {
-    // WebSocket implementation for hot reload
-    // This will be implemented when needed for file watching
-    w.WriteHeader(http.StatusNotImplemented)
-    json.NewEncoder(w).Encode(map[string]string{"status": "not implemented yet"})
-}
\ No newline at end of file
+// writeCurrentScene answers with the scene as stored on disk and its ETag
+func writeCurrentScene(w http.ResponseWriter, status int, data []byte) {
+	w.Header().Set("ETag", services.ETag(data))
+
+	var scene models.Scene
+	if err := json.Unmarshal(data, &scene); err != nil {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(status)
+		w.Write(data)
+		return
+	}
+	writeJSON(w, status, scene)
+}
+
+// etagMatches reports whether an If-Match or If-None-Match header lists
+// etag. If-None-Match uses weak comparison, If-Match strong comparison.
+func etagMatches(header, etag string, weak bool) bool {
+	if header == "" {
+		return false
+	}
+	if strings.TrimSpace(header) == "*" {
+		return true
+	}
+	for _, candidate := range strings.Split(header, ",") {
+		candidate = strings.TrimSpace(candidate)
+		if weak {
+			candidate = strings.TrimPrefix(candidate, "W/")
+		}
+		if candidate == etag {
+			return true
+		}
+	}
+	return false
+}
+
+func (s *Server) CreateScene(w http.ResponseWriter, r *http.Request) {
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	var scene models.Scene
+	if err := json.Unmarshal(body, &scene); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if scene.Settings.SceneKey == "" {
+		http.Error(w, "Scene key is required", http.StatusBadRequest)
+		return
+	}
+
+	scenePath, err := s.scenePath(scene.Settings.SceneKey)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	unlock := s.scenes.LockScenes(scene.Settings.SceneKey)
+	defer unlock()
+
+	// Check if scene already exists
+	if _, err := os.Stat(scenePath); err == nil {
+		http.Error(w, "Scene already exists", http.StatusConflict)
+		return
+	}
+
+	prettyJSON, err := scene.Encode()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	if err := s.scenes.WriteSceneFile(scene.Settings.SceneKey, prettyJSON); err != nil {
+		writeSceneWriteError(w, err)
+		return
+	}
+
+	w.Header().Set("ETag", services.ETag(prettyJSON))
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(map[string]string{"status": "created", "path": scenePath})
+}
+
+// sceneFilesResponse reports the files a delete or move touched, relative
+// to the scenes directory
+type sceneFilesResponse struct {
+	Status   string   `json:"status"`
+	From     string   `json:"from,omitempty"`
+	To       string   `json:"to,omitempty"`
+	Files    []string `json:"files"`
+	Warnings []string `json:"warnings,omitempty"`
+}
+
+// DeleteScene removes a scene. Prefabs still instantiated by other scenes
+// are only deleted with ?force=true.
+func (s *Server) DeleteScene(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	name := vars["name"]
+
+	if _, err := s.scenePath(name); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	unlock := s.scenes.LockScenes(name)
+	defer unlock()
+
+	scene, err := s.scenes.LoadScene(name)
+	if os.IsNotExist(err) {
+		http.Error(w, "Scene not found", http.StatusNotFound)
+		return
+	}
+
+	if err == nil && scene.SceneType == "PREFAB" && r.URL.Query().Get("force") != "true" {
+		users, err := s.scenes.ScenesUsingPrefab(scene.ID)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		if users = slices.DeleteFunc(users, func(user string) bool { return user == name }); len(users) > 0 {
+			http.Error(w, "Prefab is used by: "+strings.Join(users, ", "), http.StatusConflict)
+			return
+		}
+	}
+
+	files, err := s.scenes.DeleteScene(name)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, sceneFilesResponse{Status: "deleted", Files: files})
+}
+
+// MoveScene renames a scene and/or moves it into another folder. The scene
+// keeps its ID, so prefab instances elsewhere stay valid; compiled output
+// importing the moved prefab is regenerated with the new path.
+func (s *Server) MoveScene(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	from := vars["name"]
+
+	var req struct {
+		To string `json:"to"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	to := strings.Trim(path.Clean("/"+req.To), "/")
+
+	if _, err := s.scenePath(from); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if to == "" {
+		http.Error(w, "Invalid target scene name", http.StatusBadRequest)
+		return
+	}
+	if _, err := s.scenePath(to); err != nil {
+		http.Error(w, "Invalid target scene name: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	if to == from {
+		http.Error(w, "Scene is already at "+to, http.StatusBadRequest)
+		return
+	}
+
+	unlock := s.scenes.LockScenes(from, to)
+	defer unlock()
+
+	scene, err := s.scenes.LoadScene(from)
+	if os.IsNotExist(err) {
+		http.Error(w, "Scene not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	moved, err := s.scenes.MoveScene(from, to)
+	if err != nil && len(moved) == 0 {
+		if errors.Is(err, os.ErrExist) {
+			http.Error(w, "Scene already exists: "+to, http.StatusConflict)
+		} else {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+		}
+		return
+	}
+
+	resp := sceneFilesResponse{Status: "moved", From: from, To: to, Files: []string{from + ".scene"}}
+	resp.Files = append(resp.Files, moved...)
+	if err != nil {
+		// The scene moved but its compiled output or history could not follow
+		resp.Warnings = append(resp.Warnings, "not all scene files were moved: "+err.Error())
+	}
+
+	// Keep the scene key in step with the file name when they matched
+	if scene.Settings.SceneKey == path.Base(from) {
+		scene.Settings.SceneKey = path.Base(to)
+		if err := s.scenes.SaveScene(to, scene); err != nil {
+			resp.Warnings = append(resp.Warnings, "scene key not updated: "+err.Error())
+		}
+	}
+
+	// Regenerate compiled code whose relative imports changed
+	recompile := []string{}
+	if slices.Contains(moved, to+".js") {
+		recompile = append(recompile, to)
+	}
+	if scene.SceneType == "PREFAB" {
+		users, err := s.scenes.ScenesUsingPrefab(scene.ID)
+		if err != nil {
+			resp.Warnings = append(resp.Warnings, err.Error())
+		}
+		for _, user := range users {
+			if _, err := os.Stat(filepath.Join(s.scenesPath, filepath.FromSlash(user)+".js")); err == nil && user != to {
+				recompile = append(recompile, user)
+			}
+		}
+	}
+	for _, name := range recompile {
+		result, err := s.compiler.Compile(name)
+		if err != nil {
+			resp.Warnings = append(resp.Warnings, fmt.Sprintf("%s not recompiled: %v", name, err))
+			continue
+		}
+		resp.Files = append(resp.Files, result.Output)
+	}
+
+	slices.Sort(resp.Files)
+	resp.Files = slices.Compact(resp.Files)
+
+	writeJSON(w, http.StatusOK, resp)
+}
@@ -0,0 +1,80 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestDeleteSceneRemovesCompiledOutput(t *testing.T) {
+	ts, cfg := newTestServer(t, "rooms/Town")
+	compiled := filepath.Join(cfg.GetScenesPath(), "rooms", "Town.js")
+	if err := os.WriteFile(compiled, []byte("/* START OF COMPILED CODE */"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	req, err := http.NewRequest(http.MethodDelete, ts.URL+"/api/scenes/rooms/Town", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("DELETE: %s", resp.Status)
+	}
+
+	var result sceneFilesResponse
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"rooms/Town.scene", "rooms/Town.js"}; !slices.Equal(result.Files, want) {
+		t.Errorf("files = %v, want %v", result.Files, want)
+	}
+	for _, file := range []string{"Town.scene", "Town.js"} {
+		if _, err := os.Stat(filepath.Join(cfg.GetScenesPath(), "rooms", file)); !os.IsNotExist(err) {
+			t.Errorf("%s still exists: %v", file, err)
+		}
+	}
+}
+
+func TestSceneNamesCannotClashWithSceneRoutes(t *testing.T) {
+	ts, _ := newTestServer(t, "rooms/Town")
+
+	for _, name := range []string{"rooms/objects/Chair", "history/Town", "rooms/validate", "rooms/move"} {
+		body := `{"id": "x", "sceneType": "SCENE", "settings": {"sceneKey": "` + name + `"}, "displayList": []}`
+		resp, err := http.Post(ts.URL+"/api/scenes", "application/json", strings.NewReader(body))
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusBadRequest {
+			t.Errorf("create %s: %s, want 400", name, resp.Status)
+		}
+
+		resp, err = http.Post(ts.URL+"/api/scenes/rooms/Town/move", "application/json", strings.NewReader(`{"to": "`+name+`"}`))
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusBadRequest {
+			t.Errorf("move to %s: %s, want 400", name, resp.Status)
+		}
+	}
+
+	// Sub-resource names are fine as part of other names
+	resp, err := http.Post(ts.URL+"/api/scenes/rooms/Town/move", "application/json", strings.NewReader(`{"to": "move/TownObjects"}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Errorf("move to move/TownObjects: %s, want 200", resp.Status)
+	}
+}
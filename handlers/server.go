@@ -0,0 +1,190 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+
+	"tuxedo-core/compiler"
+	"tuxedo-core/config"
+	"tuxedo-core/middleware"
+	"tuxedo-core/services"
+
+	"github.com/gorilla/mux"
+)
+
+var errOutsideProject = errors.New("path escapes the project directory")
+
+// Scene names are matched by the greedy {name:.+} of the scene routes, so
+// a name must not look like one of the scene's sub-resources. No folder or
+// file may be named like a sub-resource that is followed by an ID, and the
+// file name may not be one of the actions.
+var (
+	sceneCollectionRoutes = []string{"objects", "history"}
+	sceneActionRoutes     = []string{"bounds", "compile", "extract-prefab", "move", "validate"}
+)
+
+// Server holds the configured project paths and the services shared by all
+// HTTP handlers
+type Server struct {
+	config      *config.Config
+	scenesPath  string
+	assetsPath  string
+	scenes      *services.SceneService
+	index       *services.ProjectIndex
+	textures    *services.TextureIndex
+	frameImages *services.FrameImages
+	assets      *services.AssetService
+	components  *services.ComponentRegistry
+	compiler    *compiler.Compiler
+	liveReload  *services.LiveReloadHub
+}
+
+// NewServer creates a server for the project described by cfg
+func NewServer(cfg *config.Config) *Server {
+	server := &Server{
+		config:     cfg,
+		scenesPath: cfg.GetScenesPath(),
+		assetsPath: cfg.GetAssetsPath(),
+		scenes:     services.NewSceneService(cfg.GetScenesPath()),
+	}
+
+	server.index = services.NewProjectIndex(server.scenesPath, server.assetsPath, cfg.GetIndexCachePath())
+	server.scenes.UseIndex(server.index)
+	server.compiler = compiler.New(server.scenes, compiler.Options{
+		ComponentsModule: cfg.Compiler.ComponentsModule,
+	})
+	server.textures = services.NewTextureIndex(server.index, cfg.Project.YukonPath)
+	server.frameImages = services.NewFrameImages(server.textures)
+	server.assets = services.NewAssetService(server.assetsPath, cfg.Project.YukonPath, server.index, server.scenes, server.textures)
+	server.components = services.NewComponentRegistry(cfg.GetComponentsPath())
+	server.scenes.UseComponents(server.components)
+
+	if cfg.History.Enabled {
+		server.scenes.EnableHistory(services.NewHistoryStore(
+			cfg.GetHistoryPath(),
+			cfg.History.MaxRevisions,
+			time.Duration(cfg.History.MaxAgeDays)*24*time.Hour,
+		))
+	}
+
+	return server
+}
+
+// StartIndex builds the project index and loads the component
+// definitions, and keeps both current with the files on disk. Listing
+// endpoints answer from the index. When the project cannot be indexed the
+// error is returned and the index stays empty.
+func (s *Server) StartIndex() error {
+	indexErr := s.index.Start()
+	if err := s.components.Start(s.index); err != nil {
+		log.Printf("Component registry: %v", err)
+	}
+	return indexErr
+}
+
+// StartLiveReload sends the index's file changes to the websocket endpoint
+func (s *Server) StartLiveReload() {
+	hub := services.NewLiveReloadHub(s.index)
+	hub.Start()
+	s.liveReload = hub
+}
+
+// Router returns a router with the asset file server and all API routes
+func (s *Server) Router() *mux.Router {
+	r := mux.NewRouter()
+
+	// Serve yukon assets FIRST (for loading textures in editor)
+	// This must come before the catch-all static file handler
+	assetsFileServer := http.StripPrefix("/assets/", http.FileServer(http.Dir(s.assetsPath)))
+	r.PathPrefix("/assets/").Handler(middleware.CORS(assetsFileServer))
+
+	// API routes with better pattern matching
+	api := r.PathPrefix("/api").Subrouter()
+	api.HandleFunc("/scenes/{name:.+}/objects", s.CreateObject).Methods("POST")
+	api.HandleFunc("/scenes/{name:.+}/objects/{id}/move", s.MoveObject).Methods("POST")
+	api.HandleFunc("/scenes/{name:.+}/objects/{id}/apply", s.ApplyOverrides).Methods("POST")
+	api.HandleFunc("/scenes/{name:.+}/objects/{id}/revert", s.RevertOverrides).Methods("POST")
+	api.HandleFunc("/scenes/{name:.+}/objects/{id}", s.GetObject).Methods("GET")
+	api.HandleFunc("/scenes/{name:.+}/objects/{id}", s.UpdateObject).Methods("PUT")
+	api.HandleFunc("/scenes/{name:.+}/objects/{id}", s.DeleteObject).Methods("DELETE")
+	api.HandleFunc("/scenes/{name:.+}/extract-prefab", s.ExtractPrefab).Methods("POST")
+	api.HandleFunc("/scenes/{name:.+}/compile", s.CompileScene).Methods("POST")
+	api.HandleFunc("/scenes/{name:.+}/move", s.MoveScene).Methods("POST")
+	api.HandleFunc("/scenes/{name:.+}/validate", s.ValidateScene).Methods("GET")
+	api.HandleFunc("/scenes/{name:.+}/bounds", s.GetSceneBounds).Methods("GET")
+	api.HandleFunc("/scenes/{name:.+}/history", s.GetSceneHistory).Methods("GET")
+	api.HandleFunc("/scenes/{name:.+}/history/{revision}", s.GetSceneRevision).Methods("GET")
+	api.HandleFunc("/scenes/{name:.+}/history/{revision}/restore", s.RestoreSceneRevision).Methods("POST")
+	api.HandleFunc("/scenes", s.GetScenes).Methods("GET")
+	api.HandleFunc("/scenes/{name:.+}", s.GetScene).Methods("GET")
+	api.HandleFunc("/scenes/{name:.+}", s.UpdateScene).Methods("PUT")
+	api.HandleFunc("/scenes/{name:.+}", s.PatchScene).Methods("PATCH")
+	api.HandleFunc("/scenes/{name:.+}", s.DeleteScene).Methods("DELETE")
+	api.HandleFunc("/scenes", s.CreateScene).Methods("POST")
+	api.HandleFunc("/assets", s.GetAssets).Methods("GET")
+	api.HandleFunc("/assets/report", s.GetAssetReport).Methods("GET")
+	api.HandleFunc("/assets/resolve/{key}", s.ResolveAssetLocation).Methods("GET")
+	api.HandleFunc("/assets", s.UploadAssets).Methods("POST")
+	api.HandleFunc("/assets/{path:.+}/move", s.MoveAsset).Methods("POST")
+	api.HandleFunc("/assets/{path:.+}", s.DeleteAsset).Methods("DELETE")
+	api.HandleFunc("/packs", s.GetPacks).Methods("GET")
+	api.HandleFunc("/packs/{path:.+}", s.GetPack).Methods("GET")
+	api.HandleFunc("/textures/{key}/frames/{frame:.+}.png", s.GetTextureFrame).Methods("GET")
+	api.HandleFunc("/textures/{key}", s.GetTexture).Methods("GET")
+	api.HandleFunc("/project", s.GetProjectInfo).Methods("GET")
+	api.HandleFunc("/validate", s.ValidateProject).Methods("GET")
+	api.HandleFunc("/components", s.GetComponents).Methods("GET")
+	api.HandleFunc("/components/{name}", s.GetComponent).Methods("GET")
+	api.HandleFunc("/prefab/{id}", s.GetPrefab).Methods("GET")
+	api.HandleFunc("/prefabs/{id}/usages", s.GetPrefabUsages).Methods("GET")
+	api.HandleFunc("/prefabs", s.GetPrefabs).Methods("GET")
+
+	// File watching endpoint for hot reload
+	api.HandleFunc("/ws", s.WebSocketHandler)
+
+	return r
+}
+
+// scenePath returns the file path for a scene name such as rooms/town/Town,
+// refusing names that would resolve outside the scenes directory or that
+// clash with the scene routes
+func (s *Server) scenePath(name string) (string, error) {
+	segments := strings.Split(name, "/")
+	for _, segment := range segments {
+		if slices.Contains(sceneCollectionRoutes, segment) {
+			return "", fmt.Errorf("scene names cannot contain a folder or file named %q", segment)
+		}
+	}
+	if last := segments[len(segments)-1]; slices.Contains(sceneActionRoutes, last) {
+		return "", fmt.Errorf("scene names cannot end in %q", last)
+	}
+	return resolveInside(s.scenesPath, name+".scene")
+}
+
+// resolveInside joins rel onto root and checks the result stays under root
+func resolveInside(root, rel string) (string, error) {
+	path := filepath.Join(root, filepath.FromSlash(rel))
+	relPath, err := filepath.Rel(root, path)
+	if err != nil || relPath == ".." || strings.HasPrefix(relPath, ".."+string(filepath.Separator)) {
+		return "", errOutsideProject
+	}
+	return path, nil
+}
+
+// writeJSON writes v as the JSON response. HTML escaping is disabled so
+// callbacks such as "() => this.onClick()" reach the editor as written.
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+
+	enc := json.NewEncoder(w)
+	enc.SetEscapeHTML(false)
+	enc.Encode(v)
+}
@@ -0,0 +1,151 @@
+package handlers
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+
+	"tuxedo-core/config"
+	"tuxedo-core/models"
+)
+
+// newTestServer serves a project in a temporary folder holding one scene
+// per name, each with its name as scene key
+func newTestServer(t *testing.T, names ...string) (*httptest.Server, *config.Config) {
+	t.Helper()
+	scenes := map[string]string{}
+	for _, name := range names {
+		scenes[name] = `{"id": "` + name + `", "sceneType": "SCENE", "settings": {"sceneKey": "` + name + `"}, "displayList": []}`
+	}
+	return newProjectServer(t, scenes)
+}
+
+// newProjectServer serves a project in a temporary folder holding the
+// scenes, keyed by name
+func newProjectServer(t *testing.T, scenes map[string]string) (*httptest.Server, *config.Config) {
+	t.Helper()
+
+	defaults, err := config.Load(filepath.Join(t.TempDir(), "missing.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	cfg := *defaults
+	cfg.Project.YukonPath = t.TempDir()
+	cfg.Index.CachePath = ""
+
+	for name, scene := range scenes {
+		path := filepath.Join(cfg.GetScenesPath(), filepath.FromSlash(name)+".scene")
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(scene), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := os.MkdirAll(cfg.GetAssetsPath(), 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	server := NewServer(&cfg)
+	if err := server.StartIndex(); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(server.index.Close)
+
+	ts := httptest.NewServer(server.Router())
+	t.Cleanup(ts.Close)
+	return ts, &cfg
+}
+
+func getJSON(t *testing.T, url string, v any) *http.Response {
+	t.Helper()
+	resp, err := http.Get(url)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(resp.Body)
+		t.Fatalf("GET %s: %s: %s", url, resp.Status, body)
+	}
+	if err := json.NewDecoder(resp.Body).Decode(v); err != nil {
+		t.Fatal(err)
+	}
+	return resp
+}
+
+func TestServersServeTheirOwnProject(t *testing.T) {
+	town, _ := newTestServer(t, "rooms/Town", "rooms/Beach")
+	dojo, _ := newTestServer(t, "rooms/Dojo")
+
+	var names []string
+	getJSON(t, town.URL+"/api/scenes", &names)
+	if !slices.Equal(names, []string{"rooms/Beach", "rooms/Town"}) {
+		t.Errorf("town scenes = %v", names)
+	}
+	getJSON(t, dojo.URL+"/api/scenes", &names)
+	if !slices.Equal(names, []string{"rooms/Dojo"}) {
+		t.Errorf("dojo scenes = %v", names)
+	}
+
+	resp, err := http.Get(dojo.URL + "/api/scenes/rooms/Town")
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusNotFound {
+		t.Errorf("GET Town from the dojo project: %s, want 404", resp.Status)
+	}
+}
+
+func TestUpdateSceneWritesIntoTheConfiguredProject(t *testing.T) {
+	ts, cfg := newTestServer(t, "rooms/Town")
+
+	var scene models.Scene
+	resp := getJSON(t, ts.URL+"/api/scenes/rooms/Town", &scene)
+	etag := resp.Header.Get("ETag")
+	if scene.Settings.SceneKey != "rooms/Town" || etag == "" {
+		t.Fatalf("scene key %q, ETag %q", scene.Settings.SceneKey, etag)
+	}
+
+	scene.Settings.SceneKey = "Town"
+	body, err := scene.Encode()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	put := func(ifMatch string) int {
+		req, err := http.NewRequest(http.MethodPut, ts.URL+"/api/scenes/rooms/Town", strings.NewReader(string(body)))
+		if err != nil {
+			t.Fatal(err)
+		}
+		req.Header.Set("If-Match", ifMatch)
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		return resp.StatusCode
+	}
+
+	if status := put(`"stale"`); status != http.StatusPreconditionFailed {
+		t.Errorf("PUT with a stale ETag: %d, want %d", status, http.StatusPreconditionFailed)
+	}
+	if status := put(etag); status != http.StatusOK {
+		t.Fatalf("PUT: %d, want %d", status, http.StatusOK)
+	}
+
+	written, err := os.ReadFile(filepath.Join(cfg.GetScenesPath(), "rooms", "Town.scene"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(written) != string(body) {
+		t.Errorf("scene file =\n%s\nwant\n%s", written, body)
+	}
+}
@@ -0,0 +1,71 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"tuxedo-core/services"
+
+	"github.com/gorilla/mux"
+)
+
+// textureNotFound answers a request for an unknown texture key
+type textureNotFound struct {
+	Error       string              `json:"error"`
+	Suggestions []string            `json:"suggestions"`       // Similar texture keys, best first
+	FrameOf     []services.FrameRef `json:"frameOf,omitempty"` // Textures with a frame of that name
+}
+
+// GetTexture returns where a texture key is loaded from and its frames:
+// the pack entry declaring it, the atlas and image files and, for atlases
+// and spritesheets, every frame. Unknown keys get a 404 with suggestions.
+func (s *Server) GetTexture(w http.ResponseWriter, r *http.Request) {
+	key := mux.Vars(r)["key"]
+
+	textures := s.textures.Textures()
+	source, ok := textures.Texture(key)
+	if !ok {
+		suggestions, frames := textures.Suggest(key)
+		writeJSON(w, http.StatusNotFound, textureNotFound{
+			Error:       "Texture not found: " + key,
+			Suggestions: suggestions,
+			FrameOf:     frames,
+		})
+		return
+	}
+
+	writeJSON(w, http.StatusOK, source)
+}
+
+// GetTextureFrame returns one frame of a texture as a PNG image of the
+// frame's source size, for thumbnails and previews. Rotated frames are
+// turned upright and trimmed frames padded back with transparency.
+func (s *Server) GetTextureFrame(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+
+	frame, err := s.frameImages.Render(vars["key"], vars["frame"])
+	switch {
+	case errors.Is(err, services.ErrTextureNotFound), errors.Is(err, services.ErrFrameNotFound):
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	case errors.Is(err, services.ErrImageUnreadable), errors.Is(err, services.ErrEmptyFrame):
+		http.Error(w, err.Error(), http.StatusUnprocessableEntity)
+		return
+	case err != nil:
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("ETag", frame.ETag)
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Last-Modified", frame.ModTime.UTC().Format(http.TimeFormat))
+	if etagMatches(r.Header.Get("If-None-Match"), frame.ETag, true) {
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+
+	w.Header().Set("Content-Type", "image/png")
+	w.Header().Set("Content-Length", strconv.Itoa(len(frame.PNG)))
+	w.Write(frame.PNG)
+}
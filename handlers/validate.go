@@ -0,0 +1,61 @@
+package handlers
+
+import (
+	"errors"
+	"io/fs"
+	"net/http"
+
+	"tuxedo-core/validation"
+
+	"github.com/gorilla/mux"
+)
+
+// ValidateScene reports broken references in a single scene
+func (s *Server) ValidateScene(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	name := vars["name"]
+
+	if _, err := s.scenePath(name); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	project, err := s.loadValidationProject()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	report, err := validation.ValidateScene(s.scenes, project, name)
+	if errors.Is(err, fs.ErrNotExist) {
+		http.Error(w, "Scene not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, report)
+}
+
+// ValidateProject reports broken references in every scene of the project
+func (s *Server) ValidateProject(w http.ResponseWriter, r *http.Request) {
+	project, err := s.loadValidationProject()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	report, err := validation.ValidateProject(s.scenes, project)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, report)
+}
+
+func (s *Server) loadValidationProject() (*validation.Project, error) {
+	return validation.LoadProject(s.scenes, s.config.Project.YukonPath, s.textures.Textures(), s.components)
+}
@@ -0,0 +1,49 @@
+package handlers
+
+import (
+	"log"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/gorilla/websocket"
+)
+
+// WebSocketHandler upgrades the connection and streams live reload messages
+// (scene-created, scene-changed, scene-deleted, asset-changed) to the client
+func (s *Server) WebSocketHandler(w http.ResponseWriter, r *http.Request) {
+	if s.liveReload == nil {
+		http.Error(w, "Live reload is not available", http.StatusServiceUnavailable)
+		return
+	}
+
+	upgrader := websocket.Upgrader{CheckOrigin: s.checkOrigin}
+	conn, err := upgrader.Upgrade(w, r, nil)
+	if err != nil {
+		log.Printf("WebSocket upgrade failed: %v", err)
+		return
+	}
+
+	s.liveReload.Serve(conn)
+}
+
+// checkOrigin accepts connections without an Origin header (not from a
+// browser), from the server's own host, and from the origins listed in
+// server.allowOrigins, a comma separated list where * allows any origin.
+// The editor is served from a different origin during development.
+func (s *Server) checkOrigin(r *http.Request) bool {
+	origin := r.Header.Get("Origin")
+	if origin == "" {
+		return true
+	}
+	if u, err := url.Parse(origin); err == nil && strings.EqualFold(u.Host, r.Host) {
+		return true
+	}
+	for _, allowed := range strings.Split(s.config.Server.AllowOrigins, ",") {
+		allowed = strings.TrimSuffix(strings.TrimSpace(allowed), "/")
+		if allowed == "*" || strings.EqualFold(allowed, origin) {
+			return true
+		}
+	}
+	return false
+}
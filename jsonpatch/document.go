@@ -0,0 +1,204 @@
+package jsonpatch
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+)
+
+// object is a JSON object that remembers the order of its keys, so a
+// patched scene is written back with its members where they were
+type object struct {
+	keys   []string
+	values map[string]any
+}
+
+func (o *object) get(key string) (any, bool) {
+	v, ok := o.values[key]
+	return v, ok
+}
+
+func (o *object) set(key string, value any) {
+	if _, ok := o.values[key]; !ok {
+		o.keys = append(o.keys, key)
+	}
+	o.values[key] = value
+}
+
+func (o *object) remove(key string) {
+	delete(o.values, key)
+	for i, k := range o.keys {
+		if k == key {
+			o.keys = append(o.keys[:i], o.keys[i+1:]...)
+			return
+		}
+	}
+}
+
+// array is a JSON array, held by pointer so operations can grow it in place
+type array struct {
+	items []any
+}
+
+// decode parses a JSON document into objects, arrays, json.Number, string,
+// bool and nil values
+func decode(data []byte) (any, error) {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+
+	value, err := decodeValue(dec)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := dec.Token(); err != io.EOF {
+		return nil, fmt.Errorf("unexpected data after JSON value")
+	}
+	return value, nil
+}
+
+func decodeValue(dec *json.Decoder) (any, error) {
+	token, err := dec.Token()
+	if err != nil {
+		return nil, err
+	}
+
+	switch token {
+	case json.Delim('{'):
+		obj := &object{values: map[string]any{}}
+		for dec.More() {
+			keyToken, err := dec.Token()
+			if err != nil {
+				return nil, err
+			}
+			value, err := decodeValue(dec)
+			if err != nil {
+				return nil, err
+			}
+			obj.set(keyToken.(string), value)
+		}
+		_, err := dec.Token()
+		return obj, err
+	case json.Delim('['):
+		list := &array{items: []any{}}
+		for dec.More() {
+			value, err := decodeValue(dec)
+			if err != nil {
+				return nil, err
+			}
+			list.items = append(list.items, value)
+		}
+		_, err := dec.Token()
+		return list, err
+	default:
+		return token, nil
+	}
+}
+
+// encode writes a value produced by decode back to JSON
+func encode(buf *bytes.Buffer, value any) error {
+	switch v := value.(type) {
+	case *object:
+		buf.WriteByte('{')
+		for i, key := range v.keys {
+			if i > 0 {
+				buf.WriteByte(',')
+			}
+			if err := encodeScalar(buf, key); err != nil {
+				return err
+			}
+			buf.WriteByte(':')
+			if err := encode(buf, v.values[key]); err != nil {
+				return err
+			}
+		}
+		buf.WriteByte('}')
+	case *array:
+		buf.WriteByte('[')
+		for i, item := range v.items {
+			if i > 0 {
+				buf.WriteByte(',')
+			}
+			if err := encode(buf, item); err != nil {
+				return err
+			}
+		}
+		buf.WriteByte(']')
+	default:
+		return encodeScalar(buf, v)
+	}
+	return nil
+}
+
+func encodeScalar(buf *bytes.Buffer, value any) error {
+	enc := json.NewEncoder(buf)
+	enc.SetEscapeHTML(false)
+	if err := enc.Encode(value); err != nil {
+		return err
+	}
+	buf.Truncate(buf.Len() - 1) // Encode appends a newline
+	return nil
+}
+
+// deepCopy returns a copy of value that shares no objects or arrays with it
+func deepCopy(value any) any {
+	switch v := value.(type) {
+	case *object:
+		c := &object{keys: append([]string(nil), v.keys...), values: make(map[string]any, len(v.values))}
+		for key, item := range v.values {
+			c.values[key] = deepCopy(item)
+		}
+		return c
+	case *array:
+		c := &array{items: make([]any, len(v.items))}
+		for i, item := range v.items {
+			c.items[i] = deepCopy(item)
+		}
+		return c
+	default:
+		return v
+	}
+}
+
+// equal compares two values as JSON: objects ignore key order and numbers
+// compare by value
+func equal(a, b any) bool {
+	switch av := a.(type) {
+	case *object:
+		bv, ok := b.(*object)
+		if !ok || len(av.values) != len(bv.values) {
+			return false
+		}
+		for key, item := range av.values {
+			other, ok := bv.values[key]
+			if !ok || !equal(item, other) {
+				return false
+			}
+		}
+		return true
+	case *array:
+		bv, ok := b.(*array)
+		if !ok || len(av.items) != len(bv.items) {
+			return false
+		}
+		for i := range av.items {
+			if !equal(av.items[i], bv.items[i]) {
+				return false
+			}
+		}
+		return true
+	case json.Number:
+		bv, ok := b.(json.Number)
+		if !ok {
+			return false
+		}
+		af, aErr := av.Float64()
+		bf, bErr := bv.Float64()
+		if aErr != nil || bErr != nil {
+			return av == bv
+		}
+		return af == bf
+	default:
+		return a == b
+	}
+}
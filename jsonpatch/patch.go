@@ -0,0 +1,310 @@
+// Package jsonpatch applies RFC 6902 JSON Patch documents while keeping the
+// key order of the patched document, so a patched scene file only differs
+// from the original where the patch changed it.
+package jsonpatch
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+var (
+	// ErrInvalidPatch is wrapped by errors for malformed patch documents
+	ErrInvalidPatch = errors.New("invalid patch")
+	// ErrTestFailed is wrapped when a test operation does not match
+	ErrTestFailed = errors.New("test operation failed")
+	// ErrPathNotFound is wrapped when an operation refers to a location
+	// that does not exist in the document
+	ErrPathNotFound = errors.New("path not found")
+)
+
+// Operation is one entry of a patch document
+type Operation struct {
+	Op    string          `json:"op"`
+	Path  string          `json:"path"`
+	From  string          `json:"from,omitempty"`
+	Value json.RawMessage `json:"value,omitempty"`
+}
+
+// Patch is an RFC 6902 patch document
+type Patch []Operation
+
+// Parse decodes a patch document and checks every operation is well formed
+func Parse(data []byte) (Patch, error) {
+	var patch Patch
+	if err := json.Unmarshal(data, &patch); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidPatch, err)
+	}
+
+	for i, op := range patch {
+		switch op.Op {
+		case "add", "replace", "test":
+			if op.Value == nil {
+				return nil, fmt.Errorf("%w: operation %d (%s) has no value", ErrInvalidPatch, i, op.Op)
+			}
+		case "move", "copy":
+			if _, err := parsePointer(op.From); err != nil {
+				return nil, fmt.Errorf("%w: operation %d: %v", ErrInvalidPatch, i, err)
+			}
+		case "remove":
+		default:
+			return nil, fmt.Errorf("%w: operation %d has unknown op %q", ErrInvalidPatch, i, op.Op)
+		}
+		if _, err := parsePointer(op.Path); err != nil {
+			return nil, fmt.Errorf("%w: operation %d: %v", ErrInvalidPatch, i, err)
+		}
+	}
+	return patch, nil
+}
+
+// Apply applies the patch to a JSON document and returns the result. The
+// operations are applied in order and the document is left untouched if
+// any of them fails.
+func (p Patch) Apply(document []byte) ([]byte, error) {
+	doc, err := decode(document)
+	if err != nil {
+		return nil, err
+	}
+
+	for i, op := range p {
+		if doc, err = op.apply(doc); err != nil {
+			return nil, fmt.Errorf("operation %d (%s %s): %w", i, op.Op, op.Path, err)
+		}
+	}
+
+	var buf bytes.Buffer
+	if err := encode(&buf, doc); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// apply runs one operation and returns the new document root
+func (op Operation) apply(doc any) (any, error) {
+	path, _ := parsePointer(op.Path)
+
+	switch op.Op {
+	case "add":
+		value, err := decode(op.Value)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrInvalidPatch, err)
+		}
+		return add(doc, path, value)
+	case "remove":
+		_, doc, err := remove(doc, path)
+		return doc, err
+	case "replace":
+		value, err := decode(op.Value)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrInvalidPatch, err)
+		}
+		return replace(doc, path, value)
+	case "move":
+		from, _ := parsePointer(op.From)
+		if isPrefix(from, path) && len(from) < len(path) {
+			return nil, fmt.Errorf("%w: cannot move %s into itself", ErrInvalidPatch, op.From)
+		}
+		value, doc, err := remove(doc, from)
+		if err != nil {
+			return nil, err
+		}
+		return add(doc, path, value)
+	case "copy":
+		from, _ := parsePointer(op.From)
+		value, err := get(doc, from)
+		if err != nil {
+			return nil, err
+		}
+		return add(doc, path, deepCopy(value))
+	case "test":
+		expected, err := decode(op.Value)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrInvalidPatch, err)
+		}
+		actual, err := get(doc, path)
+		if errors.Is(err, ErrPathNotFound) {
+			return nil, fmt.Errorf("%w: %v", ErrTestFailed, err)
+		}
+		if err != nil {
+			return nil, err
+		}
+		if !equal(actual, expected) {
+			return nil, ErrTestFailed
+		}
+		return doc, nil
+	}
+	return nil, fmt.Errorf("%w: unknown op %q", ErrInvalidPatch, op.Op)
+}
+
+// parsePointer splits an RFC 6901 JSON pointer into unescaped reference
+// tokens. The empty pointer refers to the whole document.
+func parsePointer(pointer string) ([]string, error) {
+	if pointer == "" {
+		return nil, nil
+	}
+	if !strings.HasPrefix(pointer, "/") {
+		return nil, fmt.Errorf("pointer %q must start with /", pointer)
+	}
+
+	tokens := strings.Split(pointer[1:], "/")
+	for i, token := range tokens {
+		tokens[i] = strings.ReplaceAll(strings.ReplaceAll(token, "~1", "/"), "~0", "~")
+	}
+	return tokens, nil
+}
+
+func isPrefix(prefix, path []string) bool {
+	if len(prefix) > len(path) {
+		return false
+	}
+	for i := range prefix {
+		if prefix[i] != path[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// get returns the value at path
+func get(doc any, path []string) (any, error) {
+	current := doc
+	for i, token := range path {
+		switch container := current.(type) {
+		case *object:
+			value, ok := container.get(token)
+			if !ok {
+				return nil, notFound(path[:i+1])
+			}
+			current = value
+		case *array:
+			index, err := arrayIndex(token, len(container.items)-1)
+			if err != nil {
+				return nil, notFound(path[:i+1])
+			}
+			current = container.items[index]
+		default:
+			return nil, notFound(path[:i+1])
+		}
+	}
+	return current, nil
+}
+
+// add inserts value at path, replacing an existing object member or
+// shifting array elements up
+func add(doc any, path []string, value any) (any, error) {
+	if len(path) == 0 {
+		return value, nil
+	}
+
+	parent, err := get(doc, path[:len(path)-1])
+	if err != nil {
+		return nil, err
+	}
+	token := path[len(path)-1]
+
+	switch container := parent.(type) {
+	case *object:
+		container.set(token, value)
+	case *array:
+		index := len(container.items)
+		if token != "-" {
+			if index, err = arrayIndex(token, len(container.items)); err != nil {
+				return nil, notFound(path)
+			}
+		}
+		container.items = append(container.items, nil)
+		copy(container.items[index+1:], container.items[index:])
+		container.items[index] = value
+	default:
+		return nil, notFound(path)
+	}
+	return doc, nil
+}
+
+// replace sets the existing value at path, keeping its position among the
+// object's members
+func replace(doc any, path []string, value any) (any, error) {
+	if len(path) == 0 {
+		return value, nil
+	}
+
+	parent, err := get(doc, path[:len(path)-1])
+	if err != nil {
+		return nil, err
+	}
+	token := path[len(path)-1]
+
+	switch container := parent.(type) {
+	case *object:
+		if _, ok := container.get(token); !ok {
+			return nil, notFound(path)
+		}
+		container.set(token, value)
+	case *array:
+		index, err := arrayIndex(token, len(container.items)-1)
+		if err != nil {
+			return nil, notFound(path)
+		}
+		container.items[index] = value
+	default:
+		return nil, notFound(path)
+	}
+	return doc, nil
+}
+
+// remove deletes the value at path and returns it along with the new root
+func remove(doc any, path []string) (any, any, error) {
+	if len(path) == 0 {
+		return doc, nil, nil
+	}
+
+	parent, err := get(doc, path[:len(path)-1])
+	if err != nil {
+		return nil, nil, err
+	}
+	token := path[len(path)-1]
+
+	switch container := parent.(type) {
+	case *object:
+		value, ok := container.get(token)
+		if !ok {
+			return nil, nil, notFound(path)
+		}
+		container.remove(token)
+		return value, doc, nil
+	case *array:
+		index, err := arrayIndex(token, len(container.items)-1)
+		if err != nil {
+			return nil, nil, notFound(path)
+		}
+		value := container.items[index]
+		container.items = append(container.items[:index], container.items[index+1:]...)
+		return value, doc, nil
+	}
+	return nil, nil, notFound(path)
+}
+
+// arrayIndex parses an array index token, which must be a decimal number
+// without leading zeros no greater than max
+func arrayIndex(token string, max int) (int, error) {
+	if token == "" || (len(token) > 1 && token[0] == '0') {
+		return 0, strconv.ErrSyntax
+	}
+	index, err := strconv.Atoi(token)
+	if err != nil || index < 0 || index > max {
+		return 0, strconv.ErrRange
+	}
+	return index, nil
+}
+
+func notFound(path []string) error {
+	escaped := make([]string, len(path))
+	for i, token := range path {
+		escaped[i] = strings.ReplaceAll(strings.ReplaceAll(token, "~", "~0"), "/", "~1")
+	}
+	return fmt.Errorf("%w: /%s", ErrPathNotFound, strings.Join(escaped, "/"))
+}
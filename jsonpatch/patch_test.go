@@ -0,0 +1,157 @@
+package jsonpatch
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"testing"
+)
+
+// The examples of RFC 6902 appendix A. A.13, a patch with two "op"
+// members, is left out: encoding/json keeps the last member rather than
+// rejecting the operation.
+var rfcExamples = []struct {
+	name     string
+	document string
+	patch    string
+	want     string // Expected document, in order; empty when err is set
+	err      error
+}{
+	{
+		name:     "A.1 adding an object member",
+		document: `{"foo": "bar"}`,
+		patch:    `[{"op": "add", "path": "/baz", "value": "qux"}]`,
+		want:     `{"foo": "bar", "baz": "qux"}`,
+	},
+	{
+		name:     "A.2 adding an array element",
+		document: `{"foo": ["bar", "baz"]}`,
+		patch:    `[{"op": "add", "path": "/foo/1", "value": "qux"}]`,
+		want:     `{"foo": ["bar", "qux", "baz"]}`,
+	},
+	{
+		name:     "A.3 removing an object member",
+		document: `{"baz": "qux", "foo": "bar"}`,
+		patch:    `[{"op": "remove", "path": "/baz"}]`,
+		want:     `{"foo": "bar"}`,
+	},
+	{
+		name:     "A.4 removing an array element",
+		document: `{"foo": ["bar", "qux", "baz"]}`,
+		patch:    `[{"op": "remove", "path": "/foo/1"}]`,
+		want:     `{"foo": ["bar", "baz"]}`,
+	},
+	{
+		name:     "A.5 replacing a value",
+		document: `{"baz": "qux", "foo": "bar"}`,
+		patch:    `[{"op": "replace", "path": "/baz", "value": "boo"}]`,
+		want:     `{"baz": "boo", "foo": "bar"}`,
+	},
+	{
+		name:     "A.6 moving a value",
+		document: `{"foo": {"bar": "baz", "waldo": "fred"}, "qux": {"corge": "grault"}}`,
+		patch:    `[{"op": "move", "from": "/foo/waldo", "path": "/qux/thud"}]`,
+		want:     `{"foo": {"bar": "baz"}, "qux": {"corge": "grault", "thud": "fred"}}`,
+	},
+	{
+		name:     "A.7 moving an array element",
+		document: `{"foo": ["all", "grass", "cows", "eat"]}`,
+		patch:    `[{"op": "move", "from": "/foo/1", "path": "/foo/3"}]`,
+		want:     `{"foo": ["all", "cows", "eat", "grass"]}`,
+	},
+	{
+		name:     "A.8 testing a value: success",
+		document: `{"baz": "qux", "foo": ["a", 2, "c"]}`,
+		patch: `[
+			{"op": "test", "path": "/baz", "value": "qux"},
+			{"op": "test", "path": "/foo/1", "value": 2}
+		]`,
+		want: `{"baz": "qux", "foo": ["a", 2, "c"]}`,
+	},
+	{
+		name:     "A.9 testing a value: error",
+		document: `{"baz": "qux"}`,
+		patch:    `[{"op": "test", "path": "/baz", "value": "bar"}]`,
+		err:      ErrTestFailed,
+	},
+	{
+		name:     "A.10 adding a nested member object",
+		document: `{"foo": "bar"}`,
+		patch:    `[{"op": "add", "path": "/child", "value": {"grandchild": {}}}]`,
+		want:     `{"foo": "bar", "child": {"grandchild": {}}}`,
+	},
+	{
+		name:     "A.11 ignoring unrecognized elements",
+		document: `{"foo": "bar"}`,
+		patch:    `[{"op": "add", "path": "/baz", "value": "qux", "xyz": 123}]`,
+		want:     `{"foo": "bar", "baz": "qux"}`,
+	},
+	{
+		name:     "A.12 adding to a nonexistent target",
+		document: `{"foo": "bar"}`,
+		patch:    `[{"op": "add", "path": "/baz/bat", "value": "qux"}]`,
+		err:      ErrPathNotFound,
+	},
+	{
+		name:     "A.14 ~ escape ordering",
+		document: `{"/": 9, "~1": 10}`,
+		patch:    `[{"op": "test", "path": "/~01", "value": 10}]`,
+		want:     `{"/": 9, "~1": 10}`,
+	},
+	{
+		name:     "A.15 comparing strings and numbers",
+		document: `{"/": 9, "~1": 10}`,
+		patch:    `[{"op": "test", "path": "/~01", "value": "10"}]`,
+		err:      ErrTestFailed,
+	},
+	{
+		name:     "A.16 adding an array value",
+		document: `{"foo": ["bar"]}`,
+		patch:    `[{"op": "add", "path": "/foo/-", "value": ["abc", "def"]}]`,
+		want:     `{"foo": ["bar", ["abc", "def"]]}`,
+	},
+}
+
+func TestApplyRFCExamples(t *testing.T) {
+	for _, tc := range rfcExamples {
+		t.Run(tc.name, func(t *testing.T) {
+			patch, err := Parse([]byte(tc.patch))
+			if err != nil {
+				t.Fatalf("Parse: %v", err)
+			}
+
+			got, err := patch.Apply([]byte(tc.document))
+			if tc.err != nil {
+				if !errors.Is(err, tc.err) {
+					t.Fatalf("Apply error = %v, want %v", err, tc.err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Apply: %v", err)
+			}
+
+			var want bytes.Buffer
+			if err := json.Compact(&want, []byte(tc.want)); err != nil {
+				t.Fatal(err)
+			}
+			if !bytes.Equal(got, want.Bytes()) {
+				t.Errorf("Apply = %s, want %s", got, want.Bytes())
+			}
+		})
+	}
+}
+
+func TestParseRejectsMalformedOperations(t *testing.T) {
+	for _, patch := range []string{
+		`{"op": "add", "path": "/a", "value": 1}`,
+		`[{"op": "frobnicate", "path": "/a"}]`,
+		`[{"op": "add", "path": "/a"}]`,
+		`[{"op": "move", "from": "a", "path": "/b"}]`,
+		`[{"op": "remove", "path": "a"}]`,
+	} {
+		if _, err := Parse([]byte(patch)); !errors.Is(err, ErrInvalidPatch) {
+			t.Errorf("Parse(%s) error = %v, want %v", patch, err, ErrInvalidPatch)
+		}
+	}
+}
@@ -0,0 +1,153 @@
+package models
+
+import (
+	"encoding/json"
+	"errors"
+)
+
+// ErrNotAtlas is returned for JSON documents that are not a texture atlas
+var ErrNotAtlas = errors.New("not a texture atlas")
+
+// Atlas is a TexturePacker JSON atlas. Hash and array atlases have a single
+// texture; multiatlases have one per image.
+type Atlas struct {
+	Textures []AtlasTexture `json:"textures"`
+}
+
+// AtlasTexture is one image of an atlas and the frames cut from it
+type AtlasTexture struct {
+	Image  string       `json:"image"` // Relative to the atlas file
+	Size   FrameSize    `json:"size"`
+	Frames []AtlasFrame `json:"frames"`
+}
+
+// AtlasFrame is a frame of an atlas. Trimmed frames are smaller than the
+// sprite they were cut from: SpriteSourceSize is where the kept pixels sit
+// within SourceSize, the sprite's original size. Rotated frames are stored
+// turned 90 degrees clockwise in the image.
+type AtlasFrame struct {
+	Name             string    `json:"filename"`
+	Frame            FrameRect `json:"frame"` // Area of the image holding the frame
+	Rotated          bool      `json:"rotated"`
+	Trimmed          bool      `json:"trimmed"`
+	SpriteSourceSize FrameRect `json:"spriteSourceSize"`
+	SourceSize       FrameSize `json:"sourceSize"`
+}
+
+// FrameRect is a rectangle in pixels
+type FrameRect struct {
+	X int `json:"x"`
+	Y int `json:"y"`
+	W int `json:"w"`
+	H int `json:"h"`
+}
+
+// FrameSize is a size in pixels
+type FrameSize struct {
+	W int `json:"w"`
+	H int `json:"h"`
+}
+
+// atlasFile is the layout of all three formats: frames is an object keyed
+// by frame name (hash) or a list (array), textures lists the images of a
+// multiatlas
+type atlasFile struct {
+	Frames   json.RawMessage `json:"frames"`
+	Meta     atlasMeta       `json:"meta"`
+	Textures []struct {
+		Image  string       `json:"image"`
+		Size   FrameSize    `json:"size"`
+		Frames []AtlasFrame `json:"frames"`
+	} `json:"textures"`
+}
+
+type atlasMeta struct {
+	Image string    `json:"image"`
+	Size  FrameSize `json:"size"`
+}
+
+// ParseAtlas reads a TexturePacker JSON atlas in hash, array or multiatlas
+// form. Frames keep the order of the file, and frames that leave out the
+// trim information get the values of an untrimmed frame.
+func ParseAtlas(data []byte) (*Atlas, error) {
+	var file atlasFile
+	if err := json.Unmarshal(data, &file); err != nil {
+		return nil, err
+	}
+
+	atlas := &Atlas{}
+	switch {
+	case len(file.Textures) > 0:
+		for _, t := range file.Textures {
+			atlas.Textures = append(atlas.Textures, AtlasTexture{Image: t.Image, Size: t.Size, Frames: t.Frames})
+		}
+	case len(file.Frames) > 0 && file.Frames[0] == '{':
+		keys, members, err := decodeObject(file.Frames)
+		if err != nil {
+			return nil, err
+		}
+		frames := make([]AtlasFrame, 0, len(keys))
+		for _, key := range keys {
+			var frame AtlasFrame
+			if err := json.Unmarshal(members[key], &frame); err != nil {
+				return nil, err
+			}
+			frame.Name = key
+			frames = append(frames, frame)
+		}
+		atlas.Textures = []AtlasTexture{{Image: file.Meta.Image, Size: file.Meta.Size, Frames: frames}}
+	case len(file.Frames) > 0 && file.Frames[0] == '[':
+		var frames []AtlasFrame
+		if err := json.Unmarshal(file.Frames, &frames); err != nil {
+			return nil, err
+		}
+		atlas.Textures = []AtlasTexture{{Image: file.Meta.Image, Size: file.Meta.Size, Frames: frames}}
+	default:
+		return nil, ErrNotAtlas
+	}
+
+	for i := range atlas.Textures {
+		for j := range atlas.Textures[i].Frames {
+			atlas.Textures[i].Frames[j].fillTrim()
+		}
+	}
+	return atlas, nil
+}
+
+// fillTrim sets the trim information of a frame that has none
+func (f *AtlasFrame) fillTrim() {
+	w, h := f.Frame.W, f.Frame.H
+	if f.Rotated {
+		w, h = h, w
+	}
+	if f.SpriteSourceSize.W == 0 && f.SpriteSourceSize.H == 0 {
+		f.SpriteSourceSize = FrameRect{W: w, H: h}
+	}
+	if f.SourceSize.W == 0 && f.SourceSize.H == 0 {
+		f.SourceSize = FrameSize{W: f.SpriteSourceSize.W, H: f.SpriteSourceSize.H}
+	}
+}
+
+// Frame returns the frame with the name and the texture it belongs to
+func (a *Atlas) Frame(name string) (*AtlasFrame, *AtlasTexture, bool) {
+	for i := range a.Textures {
+		texture := &a.Textures[i]
+		for j := range texture.Frames {
+			if texture.Frames[j].Name == name {
+				return &texture.Frames[j], texture, true
+			}
+		}
+	}
+	return nil, nil, false
+}
+
+// FrameNames returns the names of every frame, in file order
+func (a *Atlas) FrameNames() []string {
+	var names []string
+	for _, texture := range a.Textures {
+		for _, frame := range texture.Frames {
+			names = append(names, frame.Name)
+		}
+	}
+	return names
+}
@@ -0,0 +1,156 @@
+package models
+
+import (
+	"encoding/json"
+	"fmt"
+	"slices"
+	"strings"
+)
+
+// Component property types with a checked value. Other types, such as
+// Phaser Editor's asset and object keys, accept any value.
+const (
+	PropertyNumber     = "number"
+	PropertyString     = "string"
+	PropertyBoolean    = "boolean"
+	PropertyExpression = "expression"
+	PropertyColor      = "color"
+	PropertyKeyword    = "keyword"
+	PropertyOption     = "option"
+)
+
+// ComponentDefinition describes a user component: the properties it has
+// and the game object types it can be added to
+type ComponentDefinition struct {
+	Name            string              `json:"name"`
+	DisplayName     string              `json:"displayName,omitempty"`
+	GameObjectTypes []string            `json:"gameObjectTypes"` // Empty when the component fits any object
+	Properties      []ComponentProperty `json:"properties"`
+	Source          string              `json:"source"` // File the definition was read from
+}
+
+// ComponentProperty is one typed property of a component
+type ComponentProperty struct {
+	Name    string          `json:"name"`
+	Label   string          `json:"label,omitempty"`
+	Type    string          `json:"type"`
+	Options []string        `json:"options,omitempty"` // Values an option property can take
+	Default json.RawMessage `json:"default,omitempty"`
+}
+
+// Property returns the property with the name
+func (d *ComponentDefinition) Property(name string) (*ComponentProperty, bool) {
+	for i := range d.Properties {
+		if d.Properties[i].Name == name {
+			return &d.Properties[i], true
+		}
+	}
+	return nil, false
+}
+
+// AppliesTo reports whether the component can be added to objects of the
+// type. Prefab instances carry no type and are not checked.
+func (d *ComponentDefinition) AppliesTo(objectType string) bool {
+	return len(d.GameObjectTypes) == 0 || objectType == "" || slices.Contains(d.GameObjectTypes, objectType)
+}
+
+// CheckValue returns why value is not valid for the property, or "" if it is
+func (p *ComponentProperty) CheckValue(value json.RawMessage) string {
+	var v any
+	if err := json.Unmarshal(value, &v); err != nil {
+		return err.Error()
+	}
+
+	switch p.Type {
+	case PropertyNumber:
+		if _, ok := v.(float64); !ok {
+			return "must be a number"
+		}
+	case PropertyBoolean:
+		if _, ok := v.(bool); !ok {
+			return "must be true or false"
+		}
+	case PropertyString, PropertyExpression, PropertyColor, PropertyKeyword:
+		if _, ok := v.(string); !ok {
+			return "must be a string"
+		}
+	case PropertyOption:
+		s, ok := v.(string)
+		if !ok {
+			return "must be a string"
+		}
+		if len(p.Options) > 0 && !slices.Contains(p.Options, s) {
+			return "must be one of " + strings.Join(p.Options, ", ")
+		}
+	}
+	return ""
+}
+
+// ComponentError describes a component use that does not match the
+// component's definition
+type ComponentError struct {
+	ObjectID  string `json:"objectId"`
+	Component string `json:"component"`
+	Property  string `json:"property,omitempty"`
+	Message   string `json:"message"`
+}
+
+func (e ComponentError) Error() string {
+	if e.Property != "" {
+		return fmt.Sprintf("object %s: %s.%s %s", e.ObjectID, e.Component, e.Property, e.Message)
+	}
+	return fmt.Sprintf("object %s: %s %s", e.ObjectID, e.Component, e.Message)
+}
+
+// CheckComponents checks the components of obj and its children against
+// the definitions: every component added must be defined and fit the
+// object's type, and every "Component.property" value must be a defined
+// property of the right type. Properties of components an instance gets
+// from its prefab are checked when the component is defined.
+func CheckComponents(obj *GameObject, definitions map[string]ComponentDefinition) []ComponentError {
+	var errs []ComponentError
+	walkObjects([]GameObject{*obj}, func(o *GameObject) {
+		errs = append(errs, checkObjectComponents(o, definitions)...)
+	})
+	return errs
+}
+
+func checkObjectComponents(obj *GameObject, definitions map[string]ComponentDefinition) []ComponentError {
+	var errs []ComponentError
+	for _, name := range obj.Components {
+		definition, ok := definitions[name]
+		switch {
+		case !ok:
+			errs = append(errs, ComponentError{ObjectID: obj.ID, Component: name, Message: "is not defined"})
+		case !definition.AppliesTo(obj.Type):
+			errs = append(errs, ComponentError{
+				ObjectID:  obj.ID,
+				Component: name,
+				Message:   fmt.Sprintf("cannot be added to a %s, only to %s", obj.Type, strings.Join(definition.GameObjectTypes, ", ")),
+			})
+		}
+	}
+
+	for _, key := range obj.Properties.Keys() {
+		name, property, ok := strings.Cut(key, ".")
+		if !ok {
+			continue
+		}
+		definition, defined := definitions[name]
+		if !defined {
+			// Unknown components are reported above; other dotted keys,
+			// like shadow.color, are object properties
+			continue
+		}
+		propertyDefinition, ok := definition.Property(property)
+		if !ok {
+			errs = append(errs, ComponentError{ObjectID: obj.ID, Component: name, Property: property, Message: "is not a property of the component"})
+			continue
+		}
+		value, _ := obj.Properties.Get(key)
+		if problem := propertyDefinition.CheckValue(value); problem != "" {
+			errs = append(errs, ComponentError{ObjectID: obj.ID, Component: name, Property: property, Message: problem})
+		}
+	}
+	return errs
+}
@@ -0,0 +1,112 @@
+package models
+
+import (
+	"errors"
+	"slices"
+)
+
+// ErrNotSiblings is returned when objects to extract into a prefab do not
+// share the same parent
+var ErrNotSiblings = errors.New("objects must share the same parent")
+
+// PrefabExtraction names the objects ExtractPrefab creates
+type PrefabExtraction struct {
+	InstanceID string // ID of the instance replacing the objects
+	RootID     string // ID of the Container wrapping several objects
+	Label      string // Label of the instance and of the wrapping Container
+}
+
+// ExtractPrefab moves the objects with the IDs, which must be siblings,
+// into prefab and puts a single instance of it where the first of them
+// was. One object becomes the prefab's root; several are wrapped in a
+// Container. Positions are made relative to the top-left-most object
+// position, which the instance is placed at, so nothing moves on screen.
+// Object list members that were extracted are replaced by the instance in
+// the scene's lists and copied to matching lists in the prefab.
+func (s *Scene) ExtractPrefab(ids []string, prefab *Scene, names PrefabExtraction) (*GameObject, error) {
+	if len(ids) == 0 {
+		return nil, ErrObjectNotFound
+	}
+
+	var parent string
+	indexes := make([]int, 0, len(ids))
+	for i, id := range ids {
+		_, loc, ok := s.FindObject(id)
+		if !ok {
+			return nil, ErrObjectNotFound
+		}
+		if i > 0 && loc.Parent != parent {
+			return nil, ErrNotSiblings
+		}
+		parent = loc.Parent
+		if !slices.Contains(indexes, loc.Index) {
+			indexes = append(indexes, loc.Index)
+		}
+	}
+	slices.Sort(indexes)
+
+	children := &s.DisplayList
+	if parent != "" {
+		obj, _, _ := s.FindObject(parent)
+		children = &obj.List
+	}
+
+	objects := make([]GameObject, 0, len(indexes))
+	for _, index := range indexes {
+		objects = append(objects, (*children)[index])
+	}
+
+	x, y := objects[0].X, objects[0].Y
+	for _, obj := range objects[1:] {
+		x, y = min(x, obj.X), min(y, obj.Y)
+	}
+	for i := range objects {
+		objects[i].X -= x
+		objects[i].Y -= y
+	}
+
+	root := objects[0]
+	if len(objects) > 1 {
+		root = GameObject{Type: "Container", ID: names.RootID, Label: names.Label, List: objects}
+	}
+	prefab.DisplayList = []GameObject{root}
+
+	extracted := map[string]bool{}
+	walkObjects(objects, func(obj *GameObject) { extracted[obj.ID] = true })
+
+	instance := GameObject{
+		ID:       names.InstanceID,
+		Label:    names.Label,
+		X:        x,
+		Y:        y,
+		PrefabId: prefab.ID,
+		Unlock:   []string{"x", "y"},
+	}
+
+	prefab.Lists = nil
+	for i, list := range s.Lists {
+		inPrefab, inScene := []string{}, []string{}
+		for _, id := range list.ObjectIDs {
+			if !extracted[id] {
+				inScene = append(inScene, id)
+				continue
+			}
+			inPrefab = append(inPrefab, id)
+			if !slices.Contains(inScene, instance.ID) {
+				inScene = append(inScene, instance.ID)
+			}
+		}
+		if len(inPrefab) == 0 {
+			continue
+		}
+		prefab.Lists = append(prefab.Lists, ObjectList{ID: list.ID, Label: list.Label, ObjectIDs: inPrefab})
+		s.Lists[i].ObjectIDs = inScene
+	}
+
+	for i := len(indexes) - 1; i >= 0; i-- {
+		*children = slices.Delete(*children, indexes[i], indexes[i]+1)
+	}
+	*children = slices.Insert(*children, indexes[0], instance)
+
+	return &(*children)[indexes[0]], nil
+}
@@ -0,0 +1,313 @@
+package models
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"slices"
+	"strings"
+	"sync"
+)
+
+// RawFields keeps the JSON members a model does not declare, together with
+// the order members appeared in the source document. Models hold one so a
+// decode followed by an encode writes back everything that was read.
+type RawFields struct {
+	order  []string                   // member names in document order
+	values map[string]json.RawMessage // members not declared on the model
+	zeros  map[string]json.RawMessage // declared members whose empty value omitempty would drop
+}
+
+// Get returns the raw JSON of an undeclared member
+func (f *RawFields) Get(key string) (json.RawMessage, bool) {
+	raw, ok := f.values[key]
+	return raw, ok
+}
+
+// Decode unmarshals an undeclared member into v
+func (f *RawFields) Decode(key string, v any) (bool, error) {
+	raw, ok := f.values[key]
+	if !ok {
+		return false, nil
+	}
+	return true, json.Unmarshal(raw, v)
+}
+
+// Set stores value as an undeclared member, keeping its position if it
+// already exists and appending it otherwise
+func (f *RawFields) Set(key string, value any) error {
+	raw, err := marshalNoEscape(value)
+	if err != nil {
+		return err
+	}
+	f.SetRaw(key, raw)
+	return nil
+}
+
+// SetRaw stores raw JSON as an undeclared member
+func (f *RawFields) SetRaw(key string, raw json.RawMessage) {
+	if f.values == nil {
+		f.values = make(map[string]json.RawMessage)
+	}
+	if _, ok := f.values[key]; !ok && !f.hasOrder(key) {
+		f.order = append(f.order, key)
+	}
+	f.values[key] = raw
+}
+
+// Delete removes an undeclared member
+func (f *RawFields) Delete(key string) {
+	delete(f.values, key)
+}
+
+// Keys returns the undeclared member names in document order
+func (f *RawFields) Keys() []string {
+	keys := make([]string, 0, len(f.values))
+	for _, key := range f.order {
+		if _, ok := f.values[key]; ok {
+			keys = append(keys, key)
+		}
+	}
+	return keys
+}
+
+// Len returns the number of undeclared members
+func (f *RawFields) Len() int {
+	return len(f.values)
+}
+
+// Clone returns a deep copy of the fields
+func (f RawFields) Clone() RawFields {
+	clone := RawFields{order: append([]string(nil), f.order...)}
+	if f.values != nil {
+		clone.values = make(map[string]json.RawMessage, len(f.values))
+		for k, v := range f.values {
+			clone.values[k] = append(json.RawMessage(nil), v...)
+		}
+	}
+	if f.zeros != nil {
+		clone.zeros = make(map[string]json.RawMessage, len(f.zeros))
+		for k, v := range f.zeros {
+			clone.zeros[k] = v
+		}
+	}
+	return clone
+}
+
+func (f *RawFields) hasOrder(key string) bool {
+	for _, k := range f.order {
+		if k == key {
+			return true
+		}
+	}
+	return false
+}
+
+// unmarshalWithFields decodes data into alias, a pointer to a struct type
+// without custom JSON methods, and records everything else in fields.
+// Members named in raw are recorded in fields even though alias declares
+// them, for members of another JSON type than the alias field. A JSON null
+// leaves the value unchanged, as encoding/json does.
+func unmarshalWithFields(data []byte, alias any, fields *RawFields, raw ...string) error {
+	if string(bytes.TrimSpace(data)) == "null" {
+		return nil
+	}
+
+	keys, members, err := decodeObject(data)
+	if err != nil {
+		return err
+	}
+
+	decoded := data
+	if len(raw) > 0 {
+		decoded = encodeMembers(slices.DeleteFunc(slices.Clone(keys), func(key string) bool {
+			return slices.Contains(raw, key)
+		}), members)
+	}
+	if err := json.Unmarshal(decoded, alias); err != nil {
+		return err
+	}
+
+	declared := declaredMembers(reflect.TypeOf(alias).Elem())
+	*fields = RawFields{order: keys}
+	for _, key := range keys {
+		member, ok := declared[key]
+		if !ok || slices.Contains(raw, key) {
+			if fields.values == nil {
+				fields.values = make(map[string]json.RawMessage)
+			}
+			fields.values[key] = members[key]
+			continue
+		}
+		if member.omitEmpty && !member.pointer && isEmptyLiteral(members[key]) {
+			if fields.zeros == nil {
+				fields.zeros = make(map[string]json.RawMessage)
+			}
+			fields.zeros[key] = members[key]
+		}
+	}
+
+	return nil
+}
+
+// marshalWithFields encodes alias and merges in the preserved members,
+// writing members in their original order followed by any new ones
+func marshalWithFields(alias any, fields *RawFields) ([]byte, error) {
+	data, err := marshalNoEscape(alias)
+	if err != nil {
+		return nil, err
+	}
+	if len(fields.order) == 0 && len(fields.values) == 0 {
+		return data, nil
+	}
+
+	keys, members, err := decodeObject(data)
+	if err != nil {
+		return nil, err
+	}
+
+	var buf bytes.Buffer
+	written := make(map[string]bool, len(keys)+len(fields.values))
+	write := func(key string, raw json.RawMessage) error {
+		if written[key] {
+			return nil
+		}
+		if len(written) > 0 {
+			buf.WriteByte(',')
+		}
+		name, err := marshalNoEscape(key)
+		if err != nil {
+			return err
+		}
+		buf.Write(name)
+		buf.WriteByte(':')
+		buf.Write(raw)
+		written[key] = true
+		return nil
+	}
+
+	buf.WriteByte('{')
+	for _, key := range fields.order {
+		raw, ok := members[key]
+		if !ok {
+			raw, ok = fields.values[key]
+		}
+		if !ok {
+			raw, ok = fields.zeros[key]
+		}
+		if ok {
+			if err := write(key, raw); err != nil {
+				return nil, err
+			}
+		}
+	}
+	for _, key := range keys {
+		if err := write(key, members[key]); err != nil {
+			return nil, err
+		}
+	}
+	buf.WriteByte('}')
+
+	return buf.Bytes(), nil
+}
+
+// decodeObject reads the members of a JSON object in document order
+func decodeObject(data []byte) ([]string, map[string]json.RawMessage, error) {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	tok, err := dec.Token()
+	if err != nil {
+		return nil, nil, err
+	}
+	if delim, ok := tok.(json.Delim); !ok || delim != '{' {
+		return nil, nil, fmt.Errorf("expected JSON object, got %v", tok)
+	}
+
+	var keys []string
+	members := make(map[string]json.RawMessage)
+	for dec.More() {
+		tok, err := dec.Token()
+		if err != nil {
+			return nil, nil, err
+		}
+		key, ok := tok.(string)
+		if !ok {
+			return nil, nil, fmt.Errorf("expected object key, got %v", tok)
+		}
+		var raw json.RawMessage
+		if err := dec.Decode(&raw); err != nil {
+			return nil, nil, err
+		}
+		if _, dup := members[key]; !dup {
+			keys = append(keys, key)
+		}
+		members[key] = raw
+	}
+
+	return keys, members, nil
+}
+
+// marshalNoEscape encodes v without escaping <, > and &, which appear in
+// code snippets and callbacks stored in scene files
+func marshalNoEscape(v any) ([]byte, error) {
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	enc.SetEscapeHTML(false)
+	if err := enc.Encode(v); err != nil {
+		return nil, err
+	}
+	return bytes.TrimSuffix(buf.Bytes(), []byte("\n")), nil
+}
+
+// isEmptyLiteral reports whether raw is a value omitempty would drop
+func isEmptyLiteral(raw json.RawMessage) bool {
+	switch string(bytes.TrimSpace(raw)) {
+	case "0", "-0", `""`, "false", "null", "[]", "{}":
+		return true
+	}
+	var f float64
+	return json.Unmarshal(raw, &f) == nil && f == 0
+}
+
+type declaredMember struct {
+	omitEmpty bool
+	pointer   bool
+}
+
+var declaredCache sync.Map // reflect.Type -> map[string]declaredMember
+
+// declaredMembers lists the JSON member names a struct type decodes
+func declaredMembers(t reflect.Type) map[string]declaredMember {
+	if cached, ok := declaredCache.Load(t); ok {
+		return cached.(map[string]declaredMember)
+	}
+
+	members := make(map[string]declaredMember)
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if !field.IsExported() {
+			continue
+		}
+		tag := field.Tag.Get("json")
+		if tag == "-" {
+			continue
+		}
+		name, opts, _ := strings.Cut(tag, ",")
+		if field.Anonymous && name == "" && field.Type.Kind() == reflect.Struct {
+			for k, v := range declaredMembers(field.Type) {
+				members[k] = v
+			}
+			continue
+		}
+		if name == "" {
+			name = field.Name
+		}
+		members[name] = declaredMember{
+			omitEmpty: strings.Contains(opts, "omitempty"),
+			pointer:   field.Type.Kind() == reflect.Pointer,
+		}
+	}
+
+	declaredCache.Store(t, members)
+	return members
+}
@@ -1,12 +1,15 @@
 package models
 
-import "slices"
+import (
+	"reflect"
+	"slices"
+)
 
 // Component property keys for GameObject
 const (
-	ComponentButton     = "Button"
-	ComponentMoveTo     = "MoveTo"
-	ComponentAnimation  = "Animation"
+	ComponentButton       = "Button"
+	ComponentMoveTo       = "MoveTo"
+	ComponentAnimation    = "Animation"
 	ComponentSimpleButton = "SimpleButton"
 )
 
@@ -16,16 +19,44 @@ func (g *GameObject) HasComponent(componentType string) bool {
 }
 
 func (g *GameObject) SetVisible(visible bool) {
-	if g.Properties == nil {
-		g.Properties = make(map[string]interface{})
-	}
-	g.Properties["visible"] = visible
+	g.Visible = &visible
 }
 
 func (g *GameObject) SetOrigin(originX, originY float64) {
-	if g.Properties == nil {
-		g.Properties = make(map[string]interface{})
+	g.OriginX = &originX
+	g.OriginY = &originY
+}
+
+// ComponentProperty decodes a component property such as "Button.callback"
+// into v, reporting whether the object sets it
+func (g *GameObject) ComponentProperty(component, property string, v any) (bool, error) {
+	return g.Properties.Decode(component+"."+property, v)
+}
+
+// SetComponentProperty stores a component property on the object
+func (g *GameObject) SetComponentProperty(component, property string, value any) error {
+	return g.Properties.Set(component+"."+property, value)
+}
+
+// editorProperties are object properties Phaser Editor writes that have no
+// typed field and are kept in Properties
+var editorProperties = []string{
+	"alpha", "alphaTopLeft", "alphaTopRight", "alphaBottomLeft", "alphaBottomRight",
+	"flipX", "flipY", "tintFill", "tintTopLeft", "tintTopRight", "tintBottomLeft", "tintBottomRight",
+	"scope", "depth", "scrollFactorX", "scrollFactorY", "blendMode",
+	"fixedWidth", "fixedHeight", "maxLines", "lineSpacing", "letterSpacing", "wordWrapWidth",
+	"wordWrapUseAdvanced", "shadow.offsetX", "shadow.offsetY", "shadow.color", "shadow.blur",
+	"shadow.stroke", "shadow.fill", "backgroundColor", "resolution", "rtl", "baseline",
+	"animationKey", "animationPlayMethod",
+}
+
+// IsObjectProperty reports whether name is a property a game object can
+// carry, either as a field of GameObject or of one of the typed objects,
+// or as a Phaser Editor property. Component
+// properties ("Button.callback") are not included.
+func IsObjectProperty(name string) bool {
+	if _, ok := declaredMembers(reflect.TypeOf(gameObjectAlias{}))[name]; ok {
+		return true
 	}
-	g.Properties["originX"] = originX
-	g.Properties["originY"] = originY
+	return isTypedProperty(name) || slices.Contains(editorProperties, name)
 }
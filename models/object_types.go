@@ -0,0 +1,553 @@
+package models
+
+import (
+	"bytes"
+	"encoding/json"
+	"reflect"
+)
+
+// Game object types with a typed model. Objects of any other type decode
+// into Generic.
+const (
+	TypeImage      = "Image"
+	TypeSprite     = "Sprite"
+	TypeTileSprite = "TileSprite"
+	TypeNineSlice  = "NineSlice"
+	TypeText       = "Text"
+	TypeBitmapText = "BitmapText"
+	TypeRectangle  = "Rectangle"
+	TypeEllipse    = "Ellipse"
+	TypePolygon    = "Polygon"
+	TypeZone       = "Zone"
+	TypeContainer  = "Container"
+	TypeLayer      = "Layer"
+)
+
+// Object is a game object decoded into the struct for its type. Switch on
+// the concrete type (*Image, *Text, *Container, ...) to reach the fields
+// of that type; the shared fields are on Base.
+//
+// Encoding an Object writes back every member that was decoded, in the
+// original order, so typed objects can be edited and saved without loss.
+type Object interface {
+	Base() *ObjectBase
+}
+
+// ObjectBase holds the fields every game object has
+type ObjectBase struct {
+	Type       string   `json:"type,omitempty"`
+	ID         string   `json:"id"`
+	Label      string   `json:"label"`
+	PrefabId   string   `json:"prefabId,omitempty"`
+	Unlock     []string `json:"unlock,omitempty"`
+	Components []string `json:"components,omitempty"`
+	Mask       *Mask    `json:"mask,omitempty"`
+
+	Properties RawFields `json:"-"` // Component properties and other members the type does not model
+}
+
+// Base returns the shared fields of the object
+func (b *ObjectBase) Base() *ObjectBase { return b }
+
+// Mask makes another object of the scene the mask of an object. A geometry
+// mask uses the shape of a Rectangle, Ellipse or Polygon, a bitmap mask
+// the alpha of an Image or Sprite.
+type Mask struct {
+	Type     string `json:"type"`     // "geometry" or "bitmap"
+	ObjectID string `json:"objectId"` // Object used as the mask
+	Invert   bool   `json:"invert,omitempty"`
+}
+
+// Transform holds the position, scale, rotation and visibility of an object
+type Transform struct {
+	X       float64  `json:"x,omitempty"`
+	Y       float64  `json:"y,omitempty"`
+	ScaleX  *float64 `json:"scaleX,omitempty"`
+	ScaleY  *float64 `json:"scaleY,omitempty"`
+	Angle   *float64 `json:"angle,omitempty"` // Degrees
+	Visible *bool    `json:"visible,omitempty"`
+}
+
+// Origin is the point of an object its position refers to, as a fraction
+// of its size. Phaser's default is the center.
+type Origin struct {
+	OriginX *float64 `json:"originX,omitempty"`
+	OriginY *float64 `json:"originY,omitempty"`
+}
+
+// Size is the explicit size of objects that do not take it from a texture
+type Size struct {
+	Width  *float64 `json:"width,omitempty"`
+	Height *float64 `json:"height,omitempty"`
+}
+
+// Shape holds the fill and stroke of the shape objects
+type Shape struct {
+	IsFilled    *bool    `json:"isFilled,omitempty"`
+	FillColor   string   `json:"fillColor,omitempty"`
+	FillAlpha   *float64 `json:"fillAlpha,omitempty"`
+	IsStroked   *bool    `json:"isStroked,omitempty"`
+	StrokeColor string   `json:"strokeColor,omitempty"`
+	StrokeAlpha *float64 `json:"strokeAlpha,omitempty"`
+	LineWidth   *float64 `json:"lineWidth,omitempty"`
+}
+
+// Image is a textured object
+type Image struct {
+	ObjectBase
+	Transform
+	Origin
+	Texture *Texture `json:"texture,omitempty"`
+}
+
+// Sprite is an image that can play animations
+type Sprite struct {
+	ObjectBase
+	Transform
+	Origin
+	Texture             *Texture `json:"texture,omitempty"`
+	AnimationKey        string   `json:"animationKey,omitempty"`
+	AnimationPlayMethod *int     `json:"animationPlayMethod,omitempty"`
+}
+
+// TileSprite repeats a texture over its size
+type TileSprite struct {
+	ObjectBase
+	Transform
+	Origin
+	Size
+	Texture       *Texture `json:"texture,omitempty"`
+	TilePositionX *float64 `json:"tilePositionX,omitempty"`
+	TilePositionY *float64 `json:"tilePositionY,omitempty"`
+	TileScaleX    *float64 `json:"tileScaleX,omitempty"`
+	TileScaleY    *float64 `json:"tileScaleY,omitempty"`
+}
+
+// NineSlice stretches a texture over its size keeping the corners intact
+type NineSlice struct {
+	ObjectBase
+	Transform
+	Origin
+	Size
+	Texture      *Texture `json:"texture,omitempty"`
+	LeftWidth    *float64 `json:"leftWidth,omitempty"`
+	RightWidth   *float64 `json:"rightWidth,omitempty"`
+	TopHeight    *float64 `json:"topHeight,omitempty"`
+	BottomHeight *float64 `json:"bottomHeight,omitempty"`
+}
+
+// Text is text rendered with a web font. Width and height, when set, are
+// the size of the text box.
+type Text struct {
+	ObjectBase
+	Transform
+	Origin
+	Size
+	Text            string   `json:"text,omitempty"`
+	FontFamily      string   `json:"fontFamily,omitempty"`
+	FontSize        string   `json:"fontSize,omitempty"` // CSS size, e.g. "24px"
+	FontStyle       string   `json:"fontStyle,omitempty"`
+	Color           string   `json:"color,omitempty"`
+	Stroke          string   `json:"stroke,omitempty"`
+	StrokeThickness *float64 `json:"strokeThickness,omitempty"`
+	Align           string   `json:"align,omitempty"`
+	FixedWidth      *float64 `json:"fixedWidth,omitempty"`
+	FixedHeight     *float64 `json:"fixedHeight,omitempty"`
+	PaddingLeft     *float64 `json:"paddingLeft,omitempty"`
+	PaddingTop      *float64 `json:"paddingTop,omitempty"`
+	PaddingRight    *float64 `json:"paddingRight,omitempty"`
+	PaddingBottom   *float64 `json:"paddingBottom,omitempty"`
+}
+
+// BitmapText is text rendered with a bitmap font
+type BitmapText struct {
+	ObjectBase
+	Transform
+	Origin
+	Font          string   `json:"font,omitempty"` // Bitmap font key
+	Text          string   `json:"text,omitempty"`
+	FontSize      *float64 `json:"fontSize,omitempty"`
+	Align         *int     `json:"align,omitempty"`
+	LetterSpacing *float64 `json:"letterSpacing,omitempty"`
+	MaxWidth      *float64 `json:"maxWidth,omitempty"`
+}
+
+// Rectangle is a filled and/or stroked rectangle
+type Rectangle struct {
+	ObjectBase
+	Transform
+	Origin
+	Size
+	Shape
+}
+
+// Ellipse is a filled and/or stroked ellipse
+type Ellipse struct {
+	ObjectBase
+	Transform
+	Origin
+	Size
+	Shape
+	Smoothness *float64 `json:"smoothness,omitempty"`
+}
+
+// Polygon is a filled and/or stroked polygon
+type Polygon struct {
+	ObjectBase
+	Transform
+	Origin
+	Shape
+	Points string `json:"points,omitempty"` // Space separated x y pairs
+}
+
+// Zone is an invisible rectangular area, e.g. for input
+type Zone struct {
+	ObjectBase
+	Transform
+	Origin
+	Size
+}
+
+// Container groups objects under one transform
+type Container struct {
+	ObjectBase
+	Transform
+	List Objects `json:"list,omitempty"`
+}
+
+// Layer groups objects for rendering. It has no position of its own.
+type Layer struct {
+	ObjectBase
+	Visible *bool   `json:"visible,omitempty"`
+	List    Objects `json:"list,omitempty"`
+}
+
+// Instance is an instance of a prefab. Only its unlocked properties are
+// its own; its type and everything else come from the prefab.
+type Instance struct {
+	ObjectBase
+	Transform
+	List Objects `json:"list,omitempty"` // Objects added to the instance
+}
+
+// Generic is an object of a type without a typed model. All members but
+// the shared ones and the transform are kept in Properties.
+type Generic struct {
+	ObjectBase
+	Transform
+	List Objects `json:"list,omitempty"`
+}
+
+// Objects is a list of typed game objects, such as a display list
+type Objects []Object
+
+// DecodeObject decodes a game object into the struct for its "type".
+// Prefab instances decode into *Instance, unknown types into *Generic.
+func DecodeObject(data []byte) (Object, error) {
+	var head struct {
+		Type     string `json:"type"`
+		PrefabId string `json:"prefabId"`
+	}
+	if err := json.Unmarshal(data, &head); err != nil {
+		return nil, err
+	}
+
+	var obj Object
+	if head.PrefabId != "" {
+		obj = &Instance{}
+	} else {
+		obj = newObject(head.Type)
+	}
+	if err := json.Unmarshal(data, obj); err != nil {
+		return nil, err
+	}
+	return obj, nil
+}
+
+func newObject(objectType string) Object {
+	switch objectType {
+	case TypeImage:
+		return &Image{}
+	case TypeSprite:
+		return &Sprite{}
+	case TypeTileSprite:
+		return &TileSprite{}
+	case TypeNineSlice:
+		return &NineSlice{}
+	case TypeText:
+		return &Text{}
+	case TypeBitmapText:
+		return &BitmapText{}
+	case TypeRectangle:
+		return &Rectangle{}
+	case TypeEllipse:
+		return &Ellipse{}
+	case TypePolygon:
+		return &Polygon{}
+	case TypeZone:
+		return &Zone{}
+	case TypeContainer:
+		return &Container{}
+	case TypeLayer:
+		return &Layer{}
+	}
+	return &Generic{}
+}
+
+// typedTypes lists the types with a typed model
+var typedTypes = []string{
+	TypeImage, TypeSprite, TypeTileSprite, TypeNineSlice, TypeText, TypeBitmapText,
+	TypeRectangle, TypeEllipse, TypePolygon, TypeZone, TypeContainer, TypeLayer,
+}
+
+// isTypedProperty reports whether any typed object declares the member
+func isTypedProperty(name string) bool {
+	for _, objectType := range typedTypes {
+		if _, ok := declaredMembers(reflect.TypeOf(newObject(objectType)).Elem())[name]; ok {
+			return true
+		}
+	}
+	return false
+}
+
+// As returns the instance decoded into the struct for objectType, the type
+// of its prefab's root, so that its own properties can be read from typed
+// fields. The children of the instance are only kept in its List.
+func (o *Instance) As(objectType string) (Object, error) {
+	view := *o
+	view.List = nil
+	data, err := marshalNoEscape(view)
+	if err != nil {
+		return nil, err
+	}
+	obj := newObject(objectType)
+	if err := json.Unmarshal(data, obj); err != nil {
+		return nil, err
+	}
+	return obj, nil
+}
+
+// Typed decodes the object into the struct for its type
+func (g *GameObject) Typed() (Object, error) {
+	data, err := marshalNoEscape(g)
+	if err != nil {
+		return nil, err
+	}
+	return DecodeObject(data)
+}
+
+// ToGameObject converts a typed object back to the flat GameObject
+func ToGameObject(obj Object) (GameObject, error) {
+	var g GameObject
+	data, err := marshalNoEscape(obj)
+	if err != nil {
+		return g, err
+	}
+	err = json.Unmarshal(data, &g)
+	return g, err
+}
+
+// TypedObjects decodes the display list into typed objects
+func (s *Scene) TypedObjects() (Objects, error) {
+	data, err := marshalNoEscape(s.DisplayList)
+	if err != nil {
+		return nil, err
+	}
+	var objects Objects
+	err = json.Unmarshal(data, &objects)
+	return objects, err
+}
+
+// Children returns the child objects of containers, layers, instances and
+// generic objects, and nil for every other object
+func Children(obj Object) Objects {
+	switch o := obj.(type) {
+	case *Container:
+		return o.List
+	case *Layer:
+		return o.List
+	case *Instance:
+		return o.List
+	case *Generic:
+		return o.List
+	}
+	return nil
+}
+
+// WalkTyped calls fn for every object, parents before their children
+func WalkTyped(objects Objects, fn func(obj Object)) {
+	for _, obj := range objects {
+		fn(obj)
+		WalkTyped(Children(obj), fn)
+	}
+}
+
+func (o *Objects) UnmarshalJSON(data []byte) error {
+	var raw []json.RawMessage
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return err
+	}
+	if raw == nil {
+		*o = nil
+		return nil
+	}
+	objects := make(Objects, 0, len(raw))
+	for _, item := range raw {
+		obj, err := DecodeObject(item)
+		if err != nil {
+			return err
+		}
+		objects = append(objects, obj)
+	}
+	*o = objects
+	return nil
+}
+
+func (o Objects) MarshalJSON() ([]byte, error) {
+	if o == nil {
+		return []byte("null"), nil
+	}
+	var buf bytes.Buffer
+	buf.WriteByte('[')
+	for i, obj := range o {
+		if i > 0 {
+			buf.WriteByte(',')
+		}
+		data, err := marshalNoEscape(obj)
+		if err != nil {
+			return nil, err
+		}
+		buf.Write(data)
+	}
+	buf.WriteByte(']')
+	return buf.Bytes(), nil
+}
+
+type (
+	imageAlias      Image
+	spriteAlias     Sprite
+	tileSpriteAlias TileSprite
+	nineSliceAlias  NineSlice
+	textAlias       Text
+	bitmapTextAlias BitmapText
+	rectangleAlias  Rectangle
+	ellipseAlias    Ellipse
+	polygonAlias    Polygon
+	zoneAlias       Zone
+	containerAlias  Container
+	layerAlias      Layer
+	instanceAlias   Instance
+	genericAlias    Generic
+)
+
+func (o *Image) UnmarshalJSON(data []byte) error {
+	return unmarshalWithFields(data, (*imageAlias)(o), &o.Properties)
+}
+
+func (o Image) MarshalJSON() ([]byte, error) {
+	return marshalWithFields((*imageAlias)(&o), &o.Properties)
+}
+
+func (o *Sprite) UnmarshalJSON(data []byte) error {
+	return unmarshalWithFields(data, (*spriteAlias)(o), &o.Properties)
+}
+
+func (o Sprite) MarshalJSON() ([]byte, error) {
+	return marshalWithFields((*spriteAlias)(&o), &o.Properties)
+}
+
+func (o *TileSprite) UnmarshalJSON(data []byte) error {
+	return unmarshalWithFields(data, (*tileSpriteAlias)(o), &o.Properties)
+}
+
+func (o TileSprite) MarshalJSON() ([]byte, error) {
+	return marshalWithFields((*tileSpriteAlias)(&o), &o.Properties)
+}
+
+func (o *NineSlice) UnmarshalJSON(data []byte) error {
+	return unmarshalWithFields(data, (*nineSliceAlias)(o), &o.Properties)
+}
+
+func (o NineSlice) MarshalJSON() ([]byte, error) {
+	return marshalWithFields((*nineSliceAlias)(&o), &o.Properties)
+}
+
+func (o *Text) UnmarshalJSON(data []byte) error {
+	return unmarshalWithFields(data, (*textAlias)(o), &o.Properties)
+}
+
+func (o Text) MarshalJSON() ([]byte, error) {
+	return marshalWithFields((*textAlias)(&o), &o.Properties)
+}
+
+func (o *BitmapText) UnmarshalJSON(data []byte) error {
+	return unmarshalWithFields(data, (*bitmapTextAlias)(o), &o.Properties)
+}
+
+func (o BitmapText) MarshalJSON() ([]byte, error) {
+	return marshalWithFields((*bitmapTextAlias)(&o), &o.Properties)
+}
+
+func (o *Rectangle) UnmarshalJSON(data []byte) error {
+	return unmarshalWithFields(data, (*rectangleAlias)(o), &o.Properties)
+}
+
+func (o Rectangle) MarshalJSON() ([]byte, error) {
+	return marshalWithFields((*rectangleAlias)(&o), &o.Properties)
+}
+
+func (o *Ellipse) UnmarshalJSON(data []byte) error {
+	return unmarshalWithFields(data, (*ellipseAlias)(o), &o.Properties)
+}
+
+func (o Ellipse) MarshalJSON() ([]byte, error) {
+	return marshalWithFields((*ellipseAlias)(&o), &o.Properties)
+}
+
+func (o *Polygon) UnmarshalJSON(data []byte) error {
+	return unmarshalWithFields(data, (*polygonAlias)(o), &o.Properties)
+}
+
+func (o Polygon) MarshalJSON() ([]byte, error) {
+	return marshalWithFields((*polygonAlias)(&o), &o.Properties)
+}
+
+func (o *Zone) UnmarshalJSON(data []byte) error {
+	return unmarshalWithFields(data, (*zoneAlias)(o), &o.Properties)
+}
+
+func (o Zone) MarshalJSON() ([]byte, error) {
+	return marshalWithFields((*zoneAlias)(&o), &o.Properties)
+}
+
+func (o *Container) UnmarshalJSON(data []byte) error {
+	return unmarshalWithFields(data, (*containerAlias)(o), &o.Properties)
+}
+
+func (o Container) MarshalJSON() ([]byte, error) {
+	return marshalWithFields((*containerAlias)(&o), &o.Properties)
+}
+
+func (o *Layer) UnmarshalJSON(data []byte) error {
+	return unmarshalWithFields(data, (*layerAlias)(o), &o.Properties)
+}
+
+func (o Layer) MarshalJSON() ([]byte, error) {
+	return marshalWithFields((*layerAlias)(&o), &o.Properties)
+}
+
+func (o *Instance) UnmarshalJSON(data []byte) error {
+	return unmarshalWithFields(data, (*instanceAlias)(o), &o.Properties)
+}
+
+func (o Instance) MarshalJSON() ([]byte, error) {
+	return marshalWithFields((*instanceAlias)(&o), &o.Properties)
+}
+
+func (o *Generic) UnmarshalJSON(data []byte) error {
+	return unmarshalWithFields(data, (*genericAlias)(o), &o.Properties)
+}
+
+func (o Generic) MarshalJSON() ([]byte, error) {
+	return marshalWithFields((*genericAlias)(&o), &o.Properties)
+}
@@ -0,0 +1,152 @@
+package models
+
+import (
+	"errors"
+	"slices"
+)
+
+var (
+	// ErrObjectNotFound is returned when no object in the scene has the ID
+	ErrObjectNotFound = errors.New("object not found")
+	// ErrDuplicateObjectID is returned when an object ID is already used
+	ErrDuplicateObjectID = errors.New("object ID already exists")
+	// ErrInvalidParent is returned when an object cannot be placed under
+	// the requested parent
+	ErrInvalidParent = errors.New("invalid parent")
+)
+
+// ObjectLocation is where an object sits in the display list hierarchy
+type ObjectLocation struct {
+	Parent string // ID of the parent container, empty for the display list
+	Index  int    // Position among the parent's children, i.e. its z-index
+}
+
+// FindObject returns the object with the ID anywhere in the hierarchy
+func (s *Scene) FindObject(id string) (*GameObject, ObjectLocation, bool) {
+	return findObject(s.DisplayList, "", id)
+}
+
+func findObject(objects []GameObject, parent, id string) (*GameObject, ObjectLocation, bool) {
+	for i := range objects {
+		if objects[i].ID == id {
+			return &objects[i], ObjectLocation{Parent: parent, Index: i}, true
+		}
+		if obj, loc, ok := findObject(objects[i].List, objects[i].ID, id); ok {
+			return obj, loc, true
+		}
+	}
+	return nil, ObjectLocation{}, false
+}
+
+// CanHaveChildren reports whether other objects can be placed inside the
+// object
+func (g *GameObject) CanHaveChildren() bool {
+	return g.Type == "Container" || g.Type == "Layer"
+}
+
+// InsertObject places obj under the parent at loc. An index outside the
+// children, such as -1, appends it on top. The object and its children
+// must not reuse IDs already in the scene.
+func (s *Scene) InsertObject(obj GameObject, loc ObjectLocation) error {
+	ids := map[string]bool{}
+	s.WalkObjects(func(existing *GameObject) { ids[existing.ID] = true })
+
+	var err error
+	walkObjects([]GameObject{obj}, func(child *GameObject) {
+		if ids[child.ID] {
+			err = ErrDuplicateObjectID
+		}
+		ids[child.ID] = true
+	})
+	if err != nil {
+		return err
+	}
+
+	children, err := s.children(loc.Parent)
+	if err != nil {
+		return err
+	}
+
+	index := loc.Index
+	if index < 0 || index > len(*children) {
+		index = len(*children)
+	}
+	*children = slices.Insert(*children, index, obj)
+	return nil
+}
+
+// RemoveObject takes the object out of the hierarchy and returns it with
+// its children. The IDs of the removed objects are dropped from every
+// object list.
+func (s *Scene) RemoveObject(id string) (GameObject, error) {
+	_, loc, ok := s.FindObject(id)
+	if !ok {
+		return GameObject{}, ErrObjectNotFound
+	}
+
+	children := &s.DisplayList
+	if loc.Parent != "" {
+		parent, _, _ := s.FindObject(loc.Parent)
+		children = &parent.List
+	}
+	removed := (*children)[loc.Index]
+	*children = slices.Delete(*children, loc.Index, loc.Index+1)
+
+	ids := map[string]bool{}
+	walkObjects([]GameObject{removed}, func(obj *GameObject) { ids[obj.ID] = true })
+	for i := range s.Lists {
+		s.Lists[i].ObjectIDs = slices.DeleteFunc(s.Lists[i].ObjectIDs, func(id string) bool { return ids[id] })
+	}
+
+	return removed, nil
+}
+
+// MoveObject moves the object to another parent and/or index. The index
+// is the position the object should end up at, among the other children
+// of the new parent.
+func (s *Scene) MoveObject(id string, to ObjectLocation) error {
+	obj, _, ok := s.FindObject(id)
+	if !ok {
+		return ErrObjectNotFound
+	}
+	if to.Parent != "" {
+		if to.Parent == id {
+			return ErrInvalidParent
+		}
+		if _, _, inside := findObject(obj.List, id, to.Parent); inside {
+			return ErrInvalidParent
+		}
+		if _, err := s.children(to.Parent); err != nil {
+			return err
+		}
+	}
+
+	// Keep list membership: removal drops the IDs, so remember the lists
+	lists := slices.Clone(s.Lists)
+	for i := range lists {
+		lists[i].ObjectIDs = slices.Clone(lists[i].ObjectIDs)
+	}
+
+	removed, err := s.RemoveObject(id)
+	if err != nil {
+		return err
+	}
+	s.Lists = lists
+	return s.InsertObject(removed, to)
+}
+
+// children returns the child slice of a parent objects can be added to,
+// or the display list for an empty parent ID
+func (s *Scene) children(parent string) (*[]GameObject, error) {
+	if parent == "" {
+		return &s.DisplayList, nil
+	}
+	obj, _, ok := s.FindObject(parent)
+	if !ok {
+		return nil, ErrInvalidParent
+	}
+	if !obj.CanHaveChildren() {
+		return nil, ErrInvalidParent
+	}
+	return &obj.List, nil
+}
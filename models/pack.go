@@ -0,0 +1,316 @@
+package models
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"maps"
+	"regexp"
+	"slices"
+)
+
+// ErrNotPack is returned for JSON documents that are not an asset pack
+var ErrNotPack = errors.New("not an asset pack")
+
+// Pack file entry types, as the Phaser loader names them
+const (
+	PackImage       = "image"
+	PackSVG         = "svg"
+	PackAtlas       = "atlas"
+	PackAtlasXML    = "atlasXML"
+	PackUnityAtlas  = "unityAtlas"
+	PackMultiAtlas  = "multiatlas"
+	PackAseprite    = "aseprite"
+	PackSpritesheet = "spritesheet"
+	PackBitmapFont  = "bitmapFont"
+	PackAudio       = "audio"
+	PackAudioSprite = "audioSprite"
+	PackJSON        = "json"
+	PackAnimation   = "animation"
+)
+
+// textureTypes are the entry types that add a texture under their key
+var textureTypes = []string{
+	PackImage, PackSVG, PackAtlas, PackAtlasXML, PackUnityAtlas, PackMultiAtlas,
+	PackAseprite, PackSpritesheet, PackBitmapFont,
+}
+
+// Pack is a Phaser loader asset pack: named sections, each listing files
+// to load. Sections keep the order of the file, and members tuxedo does not
+// model are kept in Extra, so a parsed pack encodes back without loss.
+type Pack struct {
+	Sections []PackSection
+	Extra    RawFields // Members other than sections, such as meta
+}
+
+// PackSection is one section of a pack. BaseURL and Path are prepended to
+// the URLs of its files and Prefix to their keys, as the loader does.
+type PackSection struct {
+	Name    string     `json:"-"`
+	BaseURL string     `json:"baseURL,omitempty"`
+	Path    string     `json:"path,omitempty"`
+	Prefix  string     `json:"prefix,omitempty"`
+	Files   []PackFile `json:"files"`
+
+	Extra RawFields `json:"-"`
+}
+
+// PackFile is an entry of a section's files. Which URL members are set
+// depends on the type: url for most types, textureURL and atlasURL for
+// atlases, textureURL and fontDataURL for bitmap fonts, jsonURL and
+// audioURL for audio sprites.
+type PackFile struct {
+	Type        string           `json:"type"`
+	Key         string           `json:"key"`
+	URL         any              `json:"url,omitempty"` // A URL or a list of alternatives, e.g. audio formats
+	TextureURL  string           `json:"textureURL,omitempty"`
+	AtlasURL    string           `json:"atlasURL,omitempty"`
+	NormalMap   string           `json:"normalMap,omitempty"`
+	FontDataURL string           `json:"fontDataURL,omitempty"`
+	JSONURL     string           `json:"jsonURL,omitempty"`
+	AudioURL    any              `json:"audioURL,omitempty"`
+	Path        string           `json:"path,omitempty"` // Folder of a multiatlas' images
+	DataKey     string           `json:"dataKey,omitempty"`
+	FrameConfig *PackFrameConfig `json:"frameConfig,omitempty"`
+
+	Extra RawFields `json:"-"`
+}
+
+// PackFrameConfig is how a spritesheet is cut into frames
+type PackFrameConfig struct {
+	FrameWidth  int  `json:"frameWidth"`
+	FrameHeight int  `json:"frameHeight"`
+	StartFrame  *int `json:"startFrame,omitempty"`
+	EndFrame    *int `json:"endFrame,omitempty"`
+	Margin      *int `json:"margin,omitempty"`
+	Spacing     *int `json:"spacing,omitempty"`
+}
+
+type (
+	packSectionAlias PackSection
+	packFileAlias    PackFile
+)
+
+func (s *PackSection) UnmarshalJSON(data []byte) error {
+	return unmarshalWithFields(data, (*packSectionAlias)(s), &s.Extra)
+}
+
+func (s PackSection) MarshalJSON() ([]byte, error) {
+	return marshalWithFields((*packSectionAlias)(&s), &s.Extra)
+}
+
+func (f *PackFile) UnmarshalJSON(data []byte) error {
+	return unmarshalWithFields(data, (*packFileAlias)(f), &f.Extra)
+}
+
+func (f PackFile) MarshalJSON() ([]byte, error) {
+	return marshalWithFields((*packFileAlias)(&f), &f.Extra)
+}
+
+// ParsePack reads a pack file. Members holding an object with a files
+// list are sections; any other member is kept in Extra.
+func ParsePack(data []byte) (*Pack, error) {
+	keys, members, err := decodeObject(data)
+	if err != nil {
+		return nil, err
+	}
+
+	pack := &Pack{}
+	for _, key := range keys {
+		var probe struct {
+			Files json.RawMessage `json:"files"`
+		}
+		raw := members[key]
+		if key != "meta" && json.Unmarshal(raw, &probe) == nil && len(probe.Files) > 0 && probe.Files[0] == '[' {
+			var section PackSection
+			if err := json.Unmarshal(raw, &section); err != nil {
+				return nil, err
+			}
+			section.Name = key
+			pack.Sections = append(pack.Sections, section)
+			// Sections are written back in place of their name
+			pack.Extra.order = append(pack.Extra.order, key)
+			continue
+		}
+		pack.Extra.SetRaw(key, raw)
+	}
+	if len(pack.Sections) == 0 {
+		return nil, ErrNotPack
+	}
+	return pack, nil
+}
+
+// Encode writes the pack in the layout of a pack file, indented as Phaser
+// Editor writes it. New sections follow the existing members.
+func (p *Pack) Encode() ([]byte, error) {
+	sections := make(map[string]json.RawMessage, len(p.Sections))
+	names := make([]string, 0, len(p.Sections))
+	for _, section := range p.Sections {
+		raw, err := marshalNoEscape(section)
+		if err != nil {
+			return nil, err
+		}
+		sections[section.Name] = raw
+		names = append(names, section.Name)
+	}
+
+	var keys []string
+	for _, key := range slices.Concat(p.Extra.order, names) {
+		_, isSection := sections[key]
+		_, isExtra := p.Extra.Get(key)
+		if (isSection || isExtra) && !slices.Contains(keys, key) {
+			keys = append(keys, key)
+		}
+	}
+
+	members := make(map[string]json.RawMessage, len(keys))
+	for _, key := range keys {
+		if raw, ok := sections[key]; ok {
+			members[key] = raw
+		} else {
+			members[key], _ = p.Extra.Get(key)
+		}
+	}
+	data := encodeMembers(keys, members)
+
+	var buf bytes.Buffer
+	if err := json.Indent(&buf, data, "", "    "); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// Files returns the entries of every section, in file order
+func (p *Pack) Files() []PackFile {
+	var files []PackFile
+	for _, section := range p.Sections {
+		files = append(files, section.Files...)
+	}
+	return files
+}
+
+// TextureKeys returns the texture keys the pack provides, in file order
+func (p *Pack) TextureKeys() []string {
+	var keys []string
+	for _, section := range p.Sections {
+		for _, file := range section.Files {
+			key := section.FileKey(&file)
+			if file.IsTexture() && !slices.Contains(keys, key) {
+				keys = append(keys, key)
+			}
+		}
+	}
+	return keys
+}
+
+// FileKey returns the key the loader gives an entry of the section
+func (s *PackSection) FileKey(f *PackFile) string {
+	return s.Prefix + f.Key
+}
+
+// absoluteURL matches URLs the loader uses as they are, without the base
+// URL and path
+var absoluteURL = regexp.MustCompile(`^(?:blob:|data:|capacitor://|https?://|//)`)
+
+// ResolveURL returns the URL the loader requests for a URL of one of the
+// section's files
+func (s *PackSection) ResolveURL(url string) string {
+	if url == "" || absoluteURL.MatchString(url) {
+		return url
+	}
+	return s.BaseURL + s.Path + url
+}
+
+// IsTexture reports whether the entry adds a texture under its key
+func (f *PackFile) IsTexture() bool {
+	return slices.Contains(textureTypes, f.Type)
+}
+
+// URLs returns every URL of the entry, as written in the pack
+func (f *PackFile) URLs() []string {
+	var urls []string
+	for _, url := range [][]string{
+		urlList(f.URL),
+		{f.TextureURL, f.AtlasURL, f.NormalMap, f.FontDataURL, f.JSONURL},
+		urlList(f.AudioURL),
+	} {
+		for _, u := range url {
+			if u != "" && !slices.Contains(urls, u) {
+				urls = append(urls, u)
+			}
+		}
+	}
+	return urls
+}
+
+// MapURLs replaces every URL of the entry, and the folder of a
+// multiatlas' images, with fn's result. Lists of alternatives keep their
+// form.
+func (f *PackFile) MapURLs(fn func(url string) string) {
+	f.URL = mapURLList(f.URL, fn)
+	for _, u := range []*string{&f.TextureURL, &f.AtlasURL, &f.NormalMap, &f.FontDataURL, &f.JSONURL, &f.Path} {
+		if *u != "" {
+			*u = fn(*u)
+		}
+	}
+	f.AudioURL = mapURLList(f.AudioURL, fn)
+}
+
+// FirstURL returns the url member, or its first alternative
+func (f *PackFile) FirstURL() string {
+	if urls := urlList(f.URL); len(urls) > 0 {
+		return urls[0]
+	}
+	return ""
+}
+
+// urlList reads a URL member written as a string, a list of strings, or a
+// list of {"url": ...} objects
+func urlList(v any) []string {
+	switch u := v.(type) {
+	case string:
+		return []string{u}
+	case []any:
+		var urls []string
+		for _, item := range u {
+			switch item := item.(type) {
+			case string:
+				urls = append(urls, item)
+			case map[string]any:
+				if s, ok := item["url"].(string); ok {
+					urls = append(urls, s)
+				}
+			}
+		}
+		return urls
+	}
+	return nil
+}
+
+// mapURLList applies fn to a URL member in any of the forms urlList reads
+func mapURLList(v any, fn func(url string) string) any {
+	switch u := v.(type) {
+	case string:
+		if u != "" {
+			return fn(u)
+		}
+	case []any:
+		mapped := make([]any, len(u))
+		for i, item := range u {
+			switch item := item.(type) {
+			case string:
+				mapped[i] = fn(item)
+			case map[string]any:
+				entry := maps.Clone(item)
+				if s, ok := item["url"].(string); ok {
+					entry["url"] = fn(s)
+				}
+				mapped[i] = entry
+			default:
+				mapped[i] = item
+			}
+		}
+		return mapped
+	}
+	return v
+}
@@ -0,0 +1,233 @@
+package models
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+)
+
+var (
+	// ErrNotInstance is returned when an object is not a prefab instance
+	ErrNotInstance = errors.New("object is not a prefab instance")
+	// ErrNotUnlocked is wrapped when a property is not overridden by an
+	// instance
+	ErrNotUnlocked = errors.New("property is not unlocked")
+)
+
+// UsesOwnProperty reports whether the value of property comes from the
+// object itself. For prefab instances that is only the case for unlocked
+// properties and for the properties of components added on the instance;
+// everything else comes from the prefab.
+func (g *GameObject) UsesOwnProperty(property string) bool {
+	return usesOwnProperty(g.PrefabId, g.Unlock, g.Components, property)
+}
+
+// UsesOwnProperty reports whether the value of property comes from the
+// object itself, as for GameObject.UsesOwnProperty
+func (b *ObjectBase) UsesOwnProperty(property string) bool {
+	return usesOwnProperty(b.PrefabId, b.Unlock, b.Components, property)
+}
+
+func usesOwnProperty(prefabID string, unlock, components []string, property string) bool {
+	if prefabID == "" || slices.Contains(unlock, property) {
+		return true
+	}
+	component, _, ok := strings.Cut(property, ".")
+	return ok && slices.Contains(components, component)
+}
+
+// instanceMembers are always taken from the instance rather than the prefab
+var instanceMembers = []string{"id", "label"}
+
+// ApplyInstance returns the object a prefab instance stands for: a copy of
+// root, the prefab's resolved root object, with the instance's ID, label,
+// own properties and components applied. Children of the instance are
+// added after the prefab's. The result is no longer an instance.
+func ApplyInstance(root, instance *GameObject) (GameObject, error) {
+	rootData, err := marshalNoEscape(root)
+	if err != nil {
+		return GameObject{}, err
+	}
+	instanceData, err := marshalNoEscape(instance)
+	if err != nil {
+		return GameObject{}, err
+	}
+	keys, members, err := decodeObject(rootData)
+	if err != nil {
+		return GameObject{}, err
+	}
+	instanceKeys, instanceValues, err := decodeObject(instanceData)
+	if err != nil {
+		return GameObject{}, err
+	}
+
+	set := func(key string, raw json.RawMessage) {
+		if _, ok := members[key]; !ok {
+			keys = append(keys, key)
+		}
+		members[key] = raw
+	}
+
+	for _, key := range instanceKeys {
+		switch key {
+		case "prefabId", "unlock", "components", "list":
+			continue
+		}
+		if slices.Contains(instanceMembers, key) || instance.UsesOwnProperty(key) {
+			set(key, instanceValues[key])
+		}
+	}
+	// An unlocked property the instance does not store has its default value
+	for _, property := range instance.Unlock {
+		if _, ok := instanceValues[property]; !ok {
+			delete(members, property)
+		}
+	}
+
+	var result GameObject
+	if err := unmarshalWithFields(encodeMembers(keys, members), (*gameObjectAlias)(&result), &result.Properties); err != nil {
+		return GameObject{}, err
+	}
+	result.PrefabId = ""
+	result.Unlock = nil
+	for _, component := range instance.Components {
+		if !slices.Contains(result.Components, component) {
+			result.Components = append(result.Components, component)
+		}
+	}
+	result.List = append(result.List, instance.List...)
+	return result, nil
+}
+
+// encodeMembers writes the members that are still present as a JSON object
+func encodeMembers(keys []string, members map[string]json.RawMessage) []byte {
+	buf := []byte{'{'}
+	first := true
+	for _, key := range keys {
+		raw, ok := members[key]
+		if !ok {
+			continue
+		}
+		if !first {
+			buf = append(buf, ',')
+		}
+		first = false
+		name, _ := marshalNoEscape(key)
+		buf = append(buf, name...)
+		buf = append(buf, ':')
+		buf = append(buf, raw...)
+	}
+	return append(buf, '}')
+}
+
+// positionProperties are unlocked on every instance Phaser Editor creates.
+// They place the instance in its scene rather than override the prefab, so
+// they are only applied or reverted when asked for by name.
+var positionProperties = []string{"x", "y"}
+
+// Overrides returns the properties of an instance to apply or revert:
+// all unlocked properties but the position when properties is empty,
+// otherwise properties after checking the instance unlocks each of them
+func (g *GameObject) Overrides(properties []string) ([]string, error) {
+	if g.PrefabId == "" {
+		return nil, ErrNotInstance
+	}
+	if len(properties) == 0 {
+		return slices.DeleteFunc(slices.Clone(g.Unlock), func(property string) bool {
+			return slices.Contains(positionProperties, property)
+		}), nil
+	}
+	for _, property := range properties {
+		if !slices.Contains(g.Unlock, property) {
+			return nil, fmt.Errorf("%w: %s", ErrNotUnlocked, property)
+		}
+	}
+	return properties, nil
+}
+
+// ApplyOverrides copies the given unlocked properties of an instance onto
+// root, the root object of its prefab. A property the instance does not
+// store is removed from root so it takes its default there too. When root
+// is itself a prefab variant the properties are unlocked on it.
+func ApplyOverrides(root, instance *GameObject, properties []string) error {
+	instanceData, err := marshalNoEscape(instance)
+	if err != nil {
+		return err
+	}
+	_, instanceValues, err := decodeObject(instanceData)
+	if err != nil {
+		return err
+	}
+
+	err = editMembers(root, func(keys []string, members map[string]json.RawMessage) []string {
+		for _, property := range properties {
+			raw, ok := instanceValues[property]
+			if !ok {
+				delete(members, property)
+				continue
+			}
+			if _, exists := members[property]; !exists {
+				keys = append(keys, property)
+			}
+			members[property] = raw
+		}
+		return keys
+	})
+	if err != nil {
+		return err
+	}
+
+	if root.PrefabId != "" {
+		for _, property := range properties {
+			if !slices.Contains(root.Unlock, property) {
+				root.Unlock = append(root.Unlock, property)
+			}
+		}
+	}
+	return nil
+}
+
+// RevertOverrides locks the given properties of an instance again and
+// drops its values for them, so they come from the prefab
+func RevertOverrides(instance *GameObject, properties []string) error {
+	err := editMembers(instance, func(keys []string, members map[string]json.RawMessage) []string {
+		for _, property := range properties {
+			delete(members, property)
+		}
+		return keys
+	})
+	if err != nil {
+		return err
+	}
+
+	instance.Unlock = slices.DeleteFunc(instance.Unlock, func(property string) bool {
+		return slices.Contains(properties, property)
+	})
+	if len(instance.Unlock) == 0 {
+		instance.Unlock = nil
+	}
+	return nil
+}
+
+// editMembers rewrites the JSON members of g. edit may change members and
+// returns the member order to write; keys no longer in members are skipped.
+func editMembers(g *GameObject, edit func(keys []string, members map[string]json.RawMessage) []string) error {
+	data, err := marshalNoEscape(g)
+	if err != nil {
+		return err
+	}
+	keys, members, err := decodeObject(data)
+	if err != nil {
+		return err
+	}
+	keys = edit(keys, members)
+
+	var result GameObject
+	if err := json.Unmarshal(encodeMembers(keys, members), &result); err != nil {
+		return err
+	}
+	*g = result
+	return nil
+}
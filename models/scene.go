@@ -1,11 +1,18 @@
 package models
 
+import (
+	"bytes"
+	"encoding/json"
+)
+
 type Scene struct {
 	ID          string        `json:"id"`
 	SceneType   string        `json:"sceneType"`
 	Settings    SceneSettings `json:"settings"`
 	DisplayList []GameObject  `json:"displayList"`
 	Lists       []ObjectList  `json:"lists,omitempty"`
+
+	Extra RawFields `json:"-"` // meta, plainLists, codeSnippets and other fields not modelled above
 }
 
 type SceneSettings struct {
@@ -13,6 +20,8 @@ type SceneSettings struct {
 	BorderWidth  int      `json:"borderWidth"`
 	BorderHeight int      `json:"borderHeight"`
 	PreloadPacks []string `json:"preloadPackFiles"`
+
+	Extra RawFields `json:"-"` // compilerOutputLanguage, exportClass and other settings
 }
 
 type GameObject struct {
@@ -49,7 +58,7 @@ type GameObject struct {
 	PaddingRight    *float64 `json:"paddingRight,omitempty"`
 	PaddingBottom   *float64 `json:"paddingBottom,omitempty"`
 
-	Properties map[string]any `json:"-"` // For component properties
+	Properties RawFields `json:"-"` // Component properties and any other fields not modelled above
 }
 
 type Texture struct {
@@ -61,4 +70,81 @@ type ObjectList struct {
 	ID        string   `json:"id"`
 	Label     string   `json:"label"`
 	ObjectIDs []string `json:"objectIds"`
-}
\ No newline at end of file
+}
+
+type sceneAlias Scene
+type sceneSettingsAlias SceneSettings
+type gameObjectAlias GameObject
+
+func (s *Scene) UnmarshalJSON(data []byte) error {
+	return unmarshalWithFields(data, (*sceneAlias)(s), &s.Extra)
+}
+
+func (s Scene) MarshalJSON() ([]byte, error) {
+	return marshalWithFields((*sceneAlias)(&s), &s.Extra)
+}
+
+func (s *SceneSettings) UnmarshalJSON(data []byte) error {
+	return unmarshalWithFields(data, (*sceneSettingsAlias)(s), &s.Extra)
+}
+
+func (s SceneSettings) MarshalJSON() ([]byte, error) {
+	return marshalWithFields((*sceneSettingsAlias)(&s), &s.Extra)
+}
+
+// bitmapTextMembers are BitmapText members that are numbers where the
+// Text fields of GameObject are strings. They are kept in Properties.
+var bitmapTextMembers = []string{"fontSize", "align"}
+
+func (g *GameObject) UnmarshalJSON(data []byte) error {
+	var head struct {
+		Type string `json:"type"`
+	}
+	if err := json.Unmarshal(data, &head); err == nil && head.Type == TypeBitmapText {
+		return unmarshalWithFields(data, (*gameObjectAlias)(g), &g.Properties, bitmapTextMembers...)
+	}
+	return unmarshalWithFields(data, (*gameObjectAlias)(g), &g.Properties)
+}
+
+func (g GameObject) MarshalJSON() ([]byte, error) {
+	return marshalWithFields((*gameObjectAlias)(&g), &g.Properties)
+}
+
+// WalkObjects calls fn for every object in the display list, parents
+// before their children
+func (s *Scene) WalkObjects(fn func(obj *GameObject)) {
+	walkObjects(s.DisplayList, fn)
+}
+
+func walkObjects(objects []GameObject, fn func(obj *GameObject)) {
+	for i := range objects {
+		fn(&objects[i])
+		walkObjects(objects[i].List, fn)
+	}
+}
+
+// UsesPrefab reports whether any object in the scene is an instance of the prefab
+func (s *Scene) UsesPrefab(prefabID string) bool {
+	found := false
+	s.WalkObjects(func(obj *GameObject) {
+		if obj.PrefabId == prefabID {
+			found = true
+		}
+	})
+	return found
+}
+
+// Encode returns the scene formatted the way Phaser Editor writes .scene
+// files: four-space indentation, no HTML escaping, no trailing newline
+func (s *Scene) Encode() ([]byte, error) {
+	data, err := marshalNoEscape(s)
+	if err != nil {
+		return nil, err
+	}
+
+	var buf bytes.Buffer
+	if err := json.Indent(&buf, data, "", "    "); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
@@ -0,0 +1,95 @@
+package models
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"testing"
+)
+
+// testdata/Town.scene has members no model declares, keys out of the
+// order of the struct fields, zero values such as "x": 0 and strings
+// with <, > and &, all of which must be written back as they were read.
+func loadTestScene(t *testing.T) ([]byte, *Scene) {
+	t.Helper()
+	data, err := os.ReadFile("testdata/Town.scene")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var scene Scene
+	if err := json.Unmarshal(data, &scene); err != nil {
+		t.Fatal(err)
+	}
+	return data, &scene
+}
+
+func TestSceneRoundTrip(t *testing.T) {
+	data, scene := loadTestScene(t)
+
+	got, err := scene.Encode()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(got, data) {
+		t.Errorf("Encode changed the scene:\n%s", got)
+	}
+}
+
+func TestSceneEditOnlyChangesEditedMember(t *testing.T) {
+	data, scene := loadTestScene(t)
+
+	title := &scene.DisplayList[3]
+	title.Text = "<i>Fish & Chips</i>"
+	title.Y = 20
+
+	got, err := scene.Encode()
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := bytes.Replace(data, []byte(`"text": "<b>Fish & Chips</b>"`), []byte(`"text": "<i>Fish & Chips</i>"`), 1)
+	want = bytes.Replace(want, []byte(`"x": 10,
+            "y": 10,`), []byte(`"x": 10,
+            "y": 20,`), 1)
+	if !bytes.Equal(got, want) {
+		t.Errorf("Encode after edit =\n%s\nwant\n%s", got, want)
+	}
+}
+
+func TestTypedObjectsRoundTrip(t *testing.T) {
+	_, scene := loadTestScene(t)
+
+	objects, err := scene.TypedObjects()
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := marshalNoEscape(objects)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want, err := marshalNoEscape(scene.DisplayList)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(got, want) {
+		t.Errorf("typed display list =\n%s\nwant\n%s", got, want)
+	}
+
+	if text, ok := objects[4].(*BitmapText); !ok || text.FontSize == nil || *text.FontSize != 24 {
+		t.Errorf("objects[4] = %#v, want BitmapText with fontSize 24", objects[4])
+	}
+}
+
+func TestSceneNullMembers(t *testing.T) {
+	var scene Scene
+	data := `{"id": "a1", "sceneType": "SCENE", "settings": null, "displayList": [null], "lists": null}`
+	if err := json.Unmarshal([]byte(data), &scene); err != nil {
+		t.Fatalf("Unmarshal: %v", err)
+	}
+	if scene.ID != "a1" || len(scene.DisplayList) != 1 || scene.DisplayList[0].ID != "" {
+		t.Errorf("scene = %+v", scene)
+	}
+
+	if err := json.Unmarshal([]byte(`{"id": "a1", "settings": 5}`), &scene); err == nil {
+		t.Error("Unmarshal accepted a number for settings")
+	}
+}
@@ -0,0 +1,336 @@
+package services
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+
+	"tuxedo-core/models"
+)
+
+// Kinds of AssetReport.Missing entries
+const (
+	MissingTexture     = "texture"      // No pack or atlas provides the key
+	MissingFrame       = "frame"        // The texture has no frame of that name
+	MissingTextureFile = "texture-file" // A file of the texture does not exist
+	MissingPreloadPack = "preload-pack" // A preloadPackFiles entry does not exist
+)
+
+// Kinds of AssetReport.Unused entries
+const (
+	UnusedPack    = "pack"    // No scene preloads the pack, uses one of its textures, or loads it from another pack
+	UnusedTexture = "texture" // No scene uses the key; the pack declaring it is used
+	UnusedFile    = "file"    // No pack, atlas or scene refers to the file
+)
+
+// AssetReport cross-references the textures and preload packs the scenes
+// and prefabs use against the files under the assets directory. Paths are
+// relative to the assets directory.
+type AssetReport struct {
+	Scenes      int            `json:"scenes"`
+	Assets      int            `json:"assets"`
+	AssetBytes  int64          `json:"assetBytes"`
+	Missing     []MissingAsset `json:"missing"`
+	Unused      []UnusedAsset  `json:"unused"`
+	UnusedFiles int            `json:"unusedFiles"`
+	UnusedBytes int64          `json:"unusedBytes"` // Each file counted once
+}
+
+// MissingAsset is a reference of a scene to an asset that does not exist
+type MissingAsset struct {
+	Kind     string `json:"kind"`
+	Scene    string `json:"scene"`
+	ObjectID string `json:"objectId,omitempty"`
+	Key      string `json:"key,omitempty"`
+	Frame    string `json:"frame,omitempty"`
+	Path     string `json:"path,omitempty"` // Preload pack URL or missing texture file
+	Message  string `json:"message"`
+}
+
+// UnusedAsset is a pack, texture or file nothing refers to. Files are the
+// assets that can be deleted along with it: files other packs, textures or
+// report entries still use are left out.
+type UnusedAsset struct {
+	Kind  string   `json:"kind"`
+	Path  string   `json:"path"`
+	Key   string   `json:"key,omitempty"`
+	Pack  string   `json:"pack,omitempty"` // Pack declaring an unused texture
+	Files []string `json:"files"`
+	Bytes int64    `json:"bytes"`
+}
+
+type assetReporter struct {
+	report   *AssetReport
+	textures *Textures
+	sizes    map[string]int64
+	packs    map[string]*models.Pack
+
+	usedKeys map[string]bool
+	reached  map[string]bool // Asset paths the scenes lead to
+	queue    []string        // Reached packs whose entries are not followed yet
+	claimed  map[string]bool // Asset paths already listed as unused
+}
+
+// BuildAssetReport reads every scene and prefab and reports the texture
+// keys, frames and preload packs they refer to that do not exist, then the
+// packs, textures and files no scene leads to. A pack counts as used when
+// a scene preloads it or uses a texture it declares, and its entries other
+// than textures, such as audio, count as used with it.
+func BuildAssetReport(scenes *SceneService, index *ProjectIndex, textures *Textures) (*AssetReport, error) {
+	r := newAssetReporter(index, textures)
+	assets := index.Assets()
+	for _, asset := range assets {
+		r.report.AssetBytes += asset.Size
+	}
+	r.report.Assets = len(assets)
+
+	names, err := scenes.SceneNames()
+	if err != nil {
+		return nil, err
+	}
+	for _, name := range names {
+		scene, err := scenes.LoadScene(name)
+		if err != nil {
+			continue // Reported by validation
+		}
+		r.report.Scenes++
+		r.scanScene(name, scene)
+	}
+	r.followPacks()
+
+	r.unusedPacks()
+	r.unusedTextures()
+	for _, asset := range assets {
+		r.addUnused(UnusedAsset{Kind: UnusedFile, Path: asset.Path}, []string{asset.Path})
+	}
+	return r.report, nil
+}
+
+func newAssetReporter(index *ProjectIndex, textures *Textures) *assetReporter {
+	r := &assetReporter{
+		report:   &AssetReport{Missing: []MissingAsset{}, Unused: []UnusedAsset{}},
+		textures: textures,
+		sizes:    map[string]int64{},
+		packs:    map[string]*models.Pack{},
+		usedKeys: map[string]bool{},
+		reached:  map[string]bool{},
+		claimed:  map[string]bool{},
+	}
+	for _, asset := range index.Assets() {
+		r.sizes[asset.Path] = asset.Size
+	}
+	for _, pack := range index.Packs() {
+		r.packs[pack.Path] = pack.Pack
+	}
+	return r
+}
+
+// AssetUsers returns the scenes and prefabs whose textures or preload
+// packs lead to the asset at rel, or to a file under the folder rel
+func AssetUsers(scenes *SceneService, index *ProjectIndex, textures *Textures, rel string) ([]string, error) {
+	names, err := scenes.SceneNames()
+	if err != nil {
+		return nil, err
+	}
+
+	shared := newAssetReporter(index, textures)
+	users := []string{}
+	for _, name := range names {
+		scene, err := scenes.LoadScene(name)
+		if err != nil {
+			continue
+		}
+		// Each scene is followed on its own, reading the same files
+		r := &assetReporter{
+			report:   &AssetReport{},
+			textures: textures,
+			sizes:    shared.sizes,
+			packs:    shared.packs,
+			usedKeys: map[string]bool{},
+			reached:  map[string]bool{},
+		}
+		r.scanScene(name, scene)
+		r.followPacks()
+		for file := range r.reached {
+			if file == rel || strings.HasPrefix(file, rel+"/") {
+				users = append(users, name)
+				break
+			}
+		}
+	}
+	return users, nil
+}
+
+func (r *assetReporter) scanScene(name string, scene *models.Scene) {
+	for _, url := range scene.Settings.PreloadPacks {
+		rel := r.textures.urlAsset(url)
+		if _, ok := r.sizes[rel]; !ok {
+			r.missing(MissingAsset{Kind: MissingPreloadPack, Scene: name, Path: url,
+				Message: fmt.Sprintf("Preload pack %s does not exist", url)})
+			continue
+		}
+		r.reach(rel)
+	}
+
+	scene.WalkObjects(func(obj *models.GameObject) {
+		// Prefab instances only use their own texture when it is unlocked
+		if obj.Texture == nil || obj.Texture.Key == "" || (obj.PrefabId != "" && !slices.Contains(obj.Unlock, "texture")) {
+			return
+		}
+		r.useTexture(name, obj.ID, obj.Texture.Key, obj.Texture.Frame)
+	})
+}
+
+func (r *assetReporter) useTexture(scene, objectID, key, frame string) {
+	source, ok := r.textures.Texture(key)
+	if !ok {
+		r.missing(MissingAsset{Kind: MissingTexture, Scene: scene, ObjectID: objectID, Key: key, Frame: frame,
+			Message: fmt.Sprintf("Texture %s is not in any asset pack", key)})
+		return
+	}
+
+	switch {
+	case source.IsImage():
+		if frame != "" && frame != BaseFrame {
+			r.missing(MissingAsset{Kind: MissingFrame, Scene: scene, ObjectID: objectID, Key: key, Frame: frame,
+				Message: fmt.Sprintf("Image %s has no frame %s", key, frame)})
+		}
+	case source.HasFrames() && frame != "":
+		if _, ok := source.Frame(frame); !ok {
+			r.missing(MissingAsset{Kind: MissingFrame, Scene: scene, ObjectID: objectID, Key: key, Frame: frame,
+				Message: fmt.Sprintf("Texture %s has no frame %s", key, frame)})
+		}
+	}
+
+	if r.usedKeys[key] {
+		return
+	}
+	r.usedKeys[key] = true
+	// A texture's files are reported missing once, for the first object
+	// using it
+	for _, file := range textureFiles(source) {
+		if _, ok := r.sizes[file]; !ok {
+			r.missing(MissingAsset{Kind: MissingTextureFile, Scene: scene, ObjectID: objectID, Key: key, Path: file,
+				Message: fmt.Sprintf("Texture %s file %s does not exist", key, file)})
+		}
+		r.reach(file)
+	}
+	if source.Pack != "" {
+		r.reach(source.Pack)
+	}
+	for _, pack := range source.AlsoIn {
+		r.reach(pack)
+	}
+}
+
+// followPacks marks the files the entries of reached packs load, skipping
+// textures no scene uses. Packs loaded by a packfile entry are followed in
+// turn.
+func (r *assetReporter) followPacks() {
+	for len(r.queue) > 0 {
+		rel := r.queue[0]
+		r.queue = r.queue[1:]
+		for _, section := range r.packs[rel].Sections {
+			for _, file := range section.Files {
+				if file.IsTexture() && !r.usedKeys[section.FileKey(&file)] {
+					continue
+				}
+				for _, url := range file.URLs() {
+					r.reach(r.textures.urlAsset(section.ResolveURL(url)))
+				}
+			}
+		}
+	}
+}
+
+func (r *assetReporter) reach(rel string) {
+	if r.reached[rel] {
+		return
+	}
+	r.reached[rel] = true
+	if _, ok := r.packs[rel]; ok {
+		r.queue = append(r.queue, rel)
+	}
+}
+
+// unusedPacks reports the packs not reached, with the files of their
+// entries
+func (r *assetReporter) unusedPacks() {
+	paths := make([]string, 0, len(r.packs))
+	for rel := range r.packs {
+		paths = append(paths, rel)
+	}
+	slices.SortFunc(paths, CompareTreePaths)
+
+	for _, rel := range paths {
+		if r.reached[rel] {
+			continue
+		}
+		files := []string{rel}
+		for _, section := range r.packs[rel].Sections {
+			for _, file := range section.Files {
+				if source, ok := r.textures.Texture(section.FileKey(&file)); ok && source.Pack == rel {
+					files = append(files, textureFiles(source)...)
+				}
+				for _, url := range file.URLs() {
+					files = append(files, r.textures.urlAsset(section.ResolveURL(url)))
+				}
+			}
+		}
+		r.addUnused(UnusedAsset{Kind: UnusedPack, Path: rel}, files)
+	}
+}
+
+// unusedTextures reports the keys no scene uses, in packs that are used
+// and in atlases outside any pack. Keys of unused packs are covered by
+// the pack.
+func (r *assetReporter) unusedTextures() {
+	for _, key := range r.textures.Keys() {
+		source, _ := r.textures.Texture(key)
+		if r.usedKeys[key] || (source.Pack != "" && !r.reached[source.Pack]) {
+			continue
+		}
+		files := textureFiles(source)
+		unused := UnusedAsset{Kind: UnusedTexture, Key: key, Pack: source.Pack}
+		if len(files) > 0 {
+			unused.Path = files[0]
+		}
+		// Listed even when its files are shared, as the pack entry can go
+		r.addUnused(unused, files)
+	}
+}
+
+// addUnused reports an unused asset with those of its files that nothing
+// uses and no earlier entry lists. File entries without such a file are
+// dropped.
+func (r *assetReporter) addUnused(unused UnusedAsset, files []string) {
+	unused.Files = []string{}
+	for _, file := range files {
+		size, ok := r.sizes[file]
+		if !ok || r.reached[file] || r.claimed[file] {
+			continue
+		}
+		r.claimed[file] = true
+		unused.Files = append(unused.Files, file)
+		unused.Bytes += size
+	}
+	if unused.Kind == UnusedFile && len(unused.Files) == 0 {
+		return
+	}
+	r.report.Unused = append(r.report.Unused, unused)
+	r.report.UnusedFiles += len(unused.Files)
+	r.report.UnusedBytes += unused.Bytes
+}
+
+func (r *assetReporter) missing(m MissingAsset) {
+	r.report.Missing = append(r.report.Missing, m)
+}
+
+// textureFiles returns the atlas and image files of a texture
+func textureFiles(source *TextureSource) []string {
+	var files []string
+	if source.Atlas != "" {
+		files = append(files, source.Atlas)
+	}
+	return append(files, source.Images...)
+}
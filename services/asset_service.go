@@ -0,0 +1,626 @@
+package services
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+
+	"tuxedo-core/models"
+)
+
+// Errors returned when an upload cannot be added to a pack
+var (
+	ErrPackKeyExists  = errors.New("key already in pack")
+	ErrUnregisterable = errors.New("cannot add to pack")
+)
+
+var (
+	imageExtensions = []string{".png", ".jpg", ".jpeg", ".gif", ".webp"}
+	audioExtensions = []string{".mp3", ".ogg", ".wav", ".m4a", ".aac", ".opus"}
+)
+
+// AssetInUseError is returned when deleting an asset scenes still use
+type AssetInUseError struct {
+	Path   string
+	Scenes []string
+}
+
+func (e *AssetInUseError) Error() string {
+	return fmt.Sprintf("%s is used by %s", e.Path, strings.Join(e.Scenes, ", "))
+}
+
+// AssetService writes, moves and deletes files under the assets directory
+// and keeps the asset packs and scenes referring to them in step
+type AssetService struct {
+	assetsPath string
+	webRoot    string // Folder pack URLs are relative to
+	index      *ProjectIndex
+	scenes     *SceneService
+	textures   *TextureIndex
+
+	mu sync.Mutex // Serializes changes to asset files and packs
+}
+
+// UploadFile is an uploaded file. Its contents are read through Open when
+// the file is written, or to tell an atlas from other JSON.
+type UploadFile struct {
+	Name string // File name, without folders
+	Open func() (io.ReadCloser, error)
+}
+
+// UploadOptions says where an upload goes and how it is registered
+type UploadOptions struct {
+	Folder    string // Relative to the assets directory
+	Pack      string // Pack to register the files in; empty to only write them
+	Section   string // Section of the pack; the first section when empty
+	Key       string // Key of the entry; the file name without extension when empty
+	Overwrite bool   // Replace existing files and pack entries of the same key
+}
+
+// AssetChange reports what an upload, move or delete touched. Paths are
+// relative to the assets directory.
+type AssetChange struct {
+	Files    []string          `json:"files"`             // Files written, moved or deleted
+	Packs    []string          `json:"packs,omitempty"`   // Packs rewritten
+	Entries  []models.PackFile `json:"entries,omitempty"` // Entries added to the pack, or removed from packs by a delete
+	Scenes   []string          `json:"scenes,omitempty"`  // Scenes whose preloadPackFiles were updated
+	Warnings []string          `json:"warnings,omitempty"`
+}
+
+func NewAssetService(assetsPath, webRoot string, index *ProjectIndex, scenes *SceneService, textures *TextureIndex) *AssetService {
+	return &AssetService{assetsPath: assetsPath, webRoot: webRoot, index: index, scenes: scenes, textures: textures}
+}
+
+// Upload writes files into a folder and, when opts.Pack is set, adds them
+// to the pack. Files sharing a name apart from the extension make one
+// entry, such as an atlas JSON and its image, or one sound in several
+// formats. Nothing is written when a file exists or a key is already in
+// the pack, unless opts.Overwrite is set. An upload that fails part way
+// leaves the folder and the pack as they were.
+func (a *AssetService) Upload(files []UploadFile, opts UploadOptions) (*AssetChange, error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	change := &AssetChange{Files: []string{}}
+	for _, file := range files {
+		rel := path.Join(opts.Folder, file.Name)
+		if _, err := os.Stat(a.path(rel)); err == nil && !opts.Overwrite {
+			return nil, &fs.PathError{Op: "upload", Path: rel, Err: os.ErrExist}
+		}
+		change.Files = append(change.Files, rel)
+	}
+
+	var pack *models.Pack
+	if opts.Pack != "" {
+		var err error
+		if pack, change.Entries, err = a.addToPack(files, opts); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := os.MkdirAll(a.path(opts.Folder), 0755); err != nil {
+		return nil, err
+	}
+	// A failed write, or a pack that cannot be saved, undoes the files
+	// written so far, so no file is left behind that the pack misses
+	var written []uploadedFile
+	for i, file := range files {
+		upload, err := a.writeUpload(change.Files[i], file)
+		if err != nil {
+			a.undoUpload(written)
+			return nil, err
+		}
+		written = append(written, upload)
+	}
+
+	if pack != nil {
+		if err := a.writePack(opts.Pack, pack); err != nil {
+			a.undoUpload(written)
+			return nil, err
+		}
+		change.Packs = []string{opts.Pack}
+	}
+	for _, upload := range written {
+		if upload.backup != "" {
+			os.Remove(upload.backup)
+		}
+		a.index.RefreshAsset(upload.rel)
+	}
+	return change, nil
+}
+
+// uploadedFile is a file an upload wrote, with the backup of the file it
+// replaced, if any
+type uploadedFile struct {
+	rel    string
+	backup string
+}
+
+// writeUpload streams an uploaded file to rel. A file it replaces is kept
+// as a hidden backup next to it until the upload is done or undone.
+func (a *AssetService) writeUpload(rel string, file UploadFile) (uploadedFile, error) {
+	upload := uploadedFile{rel: rel}
+	r, err := file.Open()
+	if err != nil {
+		return upload, err
+	}
+	defer r.Close()
+
+	target := a.path(rel)
+	if _, err := os.Stat(target); err == nil {
+		if upload.backup, err = backupFile(target); err != nil {
+			return upload, err
+		}
+	}
+	if err := WriteFileAtomicFrom(target, r, 0644); err != nil {
+		if upload.backup != "" {
+			os.Remove(upload.backup)
+		}
+		return upload, err
+	}
+	return upload, nil
+}
+
+// undoUpload removes the files an upload wrote and puts back the files
+// they replaced
+func (a *AssetService) undoUpload(written []uploadedFile) {
+	for _, upload := range slices.Backward(written) {
+		if upload.backup != "" {
+			os.Rename(upload.backup, a.path(upload.rel))
+		} else {
+			os.Remove(a.path(upload.rel))
+		}
+		a.index.RefreshAsset(upload.rel)
+	}
+}
+
+// backupFile keeps the contents of path in a hidden file next to it and
+// returns the backup's path. The backup is a hard link where the file
+// system allows, as the atomic write replacing path leaves it untouched.
+func backupFile(path string) (string, error) {
+	tmp, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+".*.bak")
+	if err != nil {
+		return "", err
+	}
+	backup := tmp.Name()
+	tmp.Close()
+	os.Remove(backup)
+	if err := os.Link(path, backup); err == nil {
+		return backup, nil
+	}
+
+	src, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer src.Close()
+	if err := WriteFileAtomicFrom(backup, src, 0644); err != nil {
+		return "", err
+	}
+	return backup, nil
+}
+
+func readUpload(file *UploadFile) ([]byte, error) {
+	r, err := file.Open()
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+	return io.ReadAll(r)
+}
+
+// addToPack reads the pack and adds entries for the uploaded files to it,
+// returning the changed pack and the entries
+func (a *AssetService) addToPack(files []UploadFile, opts UploadOptions) (*models.Pack, []models.PackFile, error) {
+	data, err := os.ReadFile(a.path(opts.Pack))
+	if err != nil {
+		return nil, nil, err
+	}
+	pack, err := models.ParsePack(data)
+	if err != nil {
+		return nil, nil, fmt.Errorf("%s: %w", opts.Pack, err)
+	}
+
+	section := &pack.Sections[0]
+	if opts.Section != "" {
+		i := slices.IndexFunc(pack.Sections, func(s models.PackSection) bool { return s.Name == opts.Section })
+		if i < 0 {
+			pack.Sections = append(pack.Sections, models.PackSection{Name: opts.Section, Files: []models.PackFile{}})
+			i = len(pack.Sections) - 1
+		}
+		section = &pack.Sections[i]
+	}
+
+	// Group the files by name without extension, in upload order
+	var stems []string
+	groups := map[string][]UploadFile{}
+	for _, file := range files {
+		stem := strings.TrimSuffix(file.Name, path.Ext(file.Name))
+		if _, ok := groups[stem]; !ok {
+			stems = append(stems, stem)
+		}
+		groups[stem] = append(groups[stem], file)
+	}
+	if opts.Key != "" && len(stems) > 1 {
+		return nil, nil, fmt.Errorf("%w: a key can only be given for a single asset, not %s", ErrUnregisterable, strings.Join(stems, ", "))
+	}
+
+	var entries []models.PackFile
+	for _, stem := range stems {
+		key := stem
+		if opts.Key != "" {
+			key = opts.Key
+		}
+		entry, err := a.packEntry(section, key, opts.Folder, groups[stem])
+		if err != nil {
+			return nil, nil, err
+		}
+		if err := addPackEntry(pack, section, entry, opts.Overwrite); err != nil {
+			return nil, nil, err
+		}
+		entries = append(entries, entry)
+	}
+	return pack, entries, nil
+}
+
+// addPackEntry adds an entry to a section. An entry of the same key
+// anywhere in the pack is replaced in place when overwrite is set.
+func addPackEntry(pack *models.Pack, section *models.PackSection, entry models.PackFile, overwrite bool) error {
+	key := section.FileKey(&entry)
+	for i := range pack.Sections {
+		existing := &pack.Sections[i]
+		for j := range existing.Files {
+			if existing.FileKey(&existing.Files[j]) != key {
+				continue
+			}
+			if !overwrite {
+				return fmt.Errorf("%w: %s in section %s", ErrPackKeyExists, key, existing.Name)
+			}
+			existing.Files[j] = entry
+			return nil
+		}
+	}
+	section.Files = append(section.Files, entry)
+	return nil
+}
+
+// packEntry builds the pack entry loading a group of uploaded files
+func (a *AssetService) packEntry(section *models.PackSection, key, folder string, files []UploadFile) (models.PackFile, error) {
+	entry := models.PackFile{Key: key}
+	url := func(name string) (string, error) {
+		return a.sectionURL(section, path.Join(folder, name))
+	}
+
+	var jsonFile *UploadFile
+	var images, audio, other []string
+	for i, file := range files {
+		ext := strings.ToLower(path.Ext(file.Name))
+		switch {
+		case ext == ".json":
+			jsonFile = &files[i]
+		case slices.Contains(imageExtensions, ext):
+			images = append(images, file.Name)
+		case slices.Contains(audioExtensions, ext):
+			audio = append(audio, file.Name)
+		default:
+			other = append(other, file.Name)
+		}
+	}
+	names := func() string {
+		var list []string
+		for _, file := range files {
+			list = append(list, file.Name)
+		}
+		return strings.Join(list, ", ")
+	}
+
+	var err error
+	switch {
+	case jsonFile != nil && len(audio) == 0 && len(other) == 0 && len(images) <= 1:
+		data, err := readUpload(jsonFile)
+		if err != nil {
+			return entry, err
+		}
+		atlas, atlasErr := models.ParseAtlas(data)
+		switch {
+		case atlasErr != nil && len(images) > 0:
+			return entry, fmt.Errorf("%w: %s is not an atlas for %s", ErrUnregisterable, jsonFile.Name, images[0])
+		case atlasErr != nil:
+			entry.Type = models.PackJSON
+			entry.URL, err = url(jsonFile.Name)
+		case len(atlas.Textures) > 1:
+			entry.Type = models.PackMultiAtlas
+			if entry.URL, err = url(jsonFile.Name); err == nil {
+				entry.Path, err = a.sectionURL(section, folder)
+			}
+		default:
+			entry.Type = models.PackAtlas
+			image := atlas.Textures[0].Image
+			if len(images) > 0 {
+				image = images[0]
+			}
+			if entry.AtlasURL, err = url(jsonFile.Name); err == nil {
+				entry.TextureURL, err = url(image)
+			}
+		}
+
+	case jsonFile == nil && len(images) == 1 && len(audio) == 0 && len(other) == 0:
+		entry.Type = models.PackImage
+		entry.URL, err = url(images[0])
+
+	case jsonFile == nil && len(images) == 0 && len(audio) > 0 && len(other) == 0:
+		entry.Type = models.PackAudio
+		var urls []any
+		for _, name := range audio {
+			u, urlErr := url(name)
+			if urlErr != nil {
+				return entry, urlErr
+			}
+			urls = append(urls, u)
+		}
+		// Several formats of one sound are alternatives the loader picks from
+		entry.URL = urls
+		if len(urls) == 1 {
+			entry.URL = urls[0]
+		}
+
+	case len(files) == 1 && strings.EqualFold(path.Ext(files[0].Name), ".svg"):
+		entry.Type = models.PackSVG
+		entry.URL, err = url(files[0].Name)
+
+	default:
+		return entry, fmt.Errorf("%w: cannot tell which kind of entry loads %s", ErrUnregisterable, names())
+	}
+	return entry, err
+}
+
+// sectionURL returns the URL an entry of the section uses for an asset:
+// its web root relative URL without the section's base URL and path
+func (a *AssetService) sectionURL(section *models.PackSection, rel string) (string, error) {
+	url := a.assetURL(rel)
+	prefix := section.BaseURL + section.Path
+	if !strings.HasPrefix(url, prefix) {
+		return "", fmt.Errorf("%w: section %s loads files from %s, not %s", ErrUnregisterable, section.Name, prefix, url)
+	}
+	return strings.TrimPrefix(url, prefix), nil
+}
+
+// Move renames an asset file or folder and points the pack entries and
+// the preloadPackFiles of scenes that refer to it, or to files under it,
+// at the new path. It fails with os.ErrExist if to exists.
+func (a *AssetService) Move(from, to string) (*AssetChange, error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	if _, err := os.Stat(a.path(from)); err != nil {
+		return nil, err
+	}
+	moved := a.filesUnder(from)
+	if err := moveFile(a.path(from), a.path(to)); err != nil {
+		return nil, err
+	}
+	a.index.RefreshAsset(from)
+	a.index.RefreshAsset(to)
+
+	change := &AssetChange{Files: []string{}}
+	for _, rel := range moved {
+		change.Files = append(change.Files, to+strings.TrimPrefix(rel, from))
+	}
+	movedPath := func(rel string) (string, bool) {
+		if rel == from || strings.HasPrefix(rel, from+"/") {
+			return to + strings.TrimPrefix(rel, from), true
+		}
+		return "", false
+	}
+
+	for _, packFile := range a.index.Packs() {
+		data, err := os.ReadFile(a.path(packFile.Path))
+		if err != nil {
+			change.Warnings = append(change.Warnings, err.Error())
+			continue
+		}
+		// Parsed again, as the index's packs are shared
+		pack, err := models.ParsePack(data)
+		if err != nil {
+			continue
+		}
+		changed := false
+		for i := range pack.Sections {
+			section := &pack.Sections[i]
+			for j := range section.Files {
+				section.Files[j].MapURLs(func(url string) string {
+					newPath, ok := movedPath(a.urlAsset(section.ResolveURL(url)))
+					if !ok {
+						return url
+					}
+					newURL, err := a.sectionURL(section, newPath)
+					if err != nil {
+						change.Warnings = append(change.Warnings, fmt.Sprintf("%s: %v", packFile.Path, err))
+						return url
+					}
+					if strings.HasSuffix(url, "/") {
+						newURL += "/"
+					}
+					changed = true
+					return newURL
+				})
+			}
+		}
+		if !changed {
+			continue
+		}
+		if err := a.writePack(packFile.Path, pack); err != nil {
+			change.Warnings = append(change.Warnings, fmt.Sprintf("%s not updated: %v", packFile.Path, err))
+			continue
+		}
+		change.Packs = append(change.Packs, packFile.Path)
+	}
+
+	scenes, err := a.movePreloadPacks(movedPath)
+	if err != nil {
+		change.Warnings = append(change.Warnings, "preloadPackFiles not updated: "+err.Error())
+	}
+	change.Scenes = scenes
+	return change, nil
+}
+
+// movePreloadPacks rewrites the preloadPackFiles of scenes listing a pack
+// that moved
+func (a *AssetService) movePreloadPacks(movedPath func(rel string) (string, bool)) ([]string, error) {
+	var names []string
+	for _, entry := range a.index.Scenes() {
+		if slices.ContainsFunc(entry.PreloadPacks, func(url string) bool {
+			_, ok := movedPath(a.urlAsset(url))
+			return ok
+		}) {
+			names = append(names, entry.Name)
+		}
+	}
+	if len(names) == 0 {
+		return nil, nil
+	}
+
+	unlock := a.scenes.LockScenes(names...)
+	defer unlock()
+
+	files := map[string][]byte{}
+	for _, name := range names {
+		data, err := a.scenes.ReadSceneFile(name)
+		if err != nil {
+			return nil, err
+		}
+		var scene models.Scene
+		if err := json.Unmarshal(data, &scene); err != nil {
+			return nil, fmt.Errorf("%s: %w", name, err)
+		}
+		for i, url := range scene.Settings.PreloadPacks {
+			if newPath, ok := movedPath(a.urlAsset(url)); ok {
+				scene.Settings.PreloadPacks[i] = a.assetURL(newPath)
+			}
+		}
+		if files[name], err = scene.Encode(); err != nil {
+			return nil, err
+		}
+	}
+	if err := a.scenes.WriteSceneFiles(files); err != nil {
+		return nil, err
+	}
+	return names, nil
+}
+
+// Delete removes an asset file or folder and the pack entries loading the
+// files it removes. Unless force is set, it fails with an
+// *AssetInUseError when a scene's textures or preloaded packs lead to it.
+func (a *AssetService) Delete(rel string, force bool) (*AssetChange, error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	if _, err := os.Stat(a.path(rel)); err != nil {
+		return nil, err
+	}
+	if !force {
+		users, err := AssetUsers(a.scenes, a.index, a.textures.Textures(), rel)
+		if err != nil {
+			return nil, err
+		}
+		if len(users) > 0 {
+			return nil, &AssetInUseError{Path: rel, Scenes: users}
+		}
+	}
+
+	change := &AssetChange{Files: a.filesUnder(rel)}
+	if err := os.RemoveAll(a.path(rel)); err != nil {
+		return nil, err
+	}
+	a.index.RefreshAsset(rel)
+
+	deleted := func(url string) bool {
+		p := a.urlAsset(url)
+		return p == rel || strings.HasPrefix(p, rel+"/")
+	}
+	for _, packFile := range a.index.Packs() {
+		data, err := os.ReadFile(a.path(packFile.Path))
+		if err != nil {
+			change.Warnings = append(change.Warnings, err.Error())
+			continue
+		}
+		// Parsed again, as the index's packs are shared
+		pack, err := models.ParsePack(data)
+		if err != nil {
+			continue
+		}
+		var removed []models.PackFile
+		for i := range pack.Sections {
+			section := &pack.Sections[i]
+			section.Files = slices.DeleteFunc(section.Files, func(file models.PackFile) bool {
+				if !slices.ContainsFunc(file.URLs(), func(url string) bool { return deleted(section.ResolveURL(url)) }) {
+					return false
+				}
+				removed = append(removed, file)
+				return true
+			})
+		}
+		if len(removed) == 0 {
+			continue
+		}
+		if err := a.writePack(packFile.Path, pack); err != nil {
+			for _, file := range removed {
+				change.Warnings = append(change.Warnings, fmt.Sprintf("%s still loads %s", packFile.Path, file.Key))
+			}
+			continue
+		}
+		change.Packs = append(change.Packs, packFile.Path)
+		change.Entries = append(change.Entries, removed...)
+	}
+	return change, nil
+}
+
+// filesUnder returns the indexed files at rel or under the folder rel
+func (a *AssetService) filesUnder(rel string) []string {
+	files := []string{}
+	for _, asset := range a.index.Assets() {
+		if asset.Path == rel || strings.HasPrefix(asset.Path, rel+"/") {
+			files = append(files, asset.Path)
+		}
+	}
+	return files
+}
+
+func (a *AssetService) writePack(rel string, pack *models.Pack) error {
+	data, err := pack.Encode()
+	if err != nil {
+		return err
+	}
+	if err := WriteFileAtomic(a.path(rel), data, 0644); err != nil {
+		return err
+	}
+	a.index.RefreshAsset(rel)
+	return nil
+}
+
+func (a *AssetService) path(rel string) string {
+	return filepath.Join(a.assetsPath, filepath.FromSlash(rel))
+}
+
+// urlAsset converts a loader URL to a path relative to the assets directory
+func (a *AssetService) urlAsset(url string) string {
+	return urlAssetPath(a.webRoot, a.assetsPath, url)
+}
+
+// assetURL returns the web root relative URL of an asset, the form packs
+// and scenes refer to assets by
+func (a *AssetService) assetURL(rel string) string {
+	url, err := filepath.Rel(a.webRoot, a.path(rel))
+	if err != nil || strings.HasPrefix(url, "..") {
+		return "assets/" + rel
+	}
+	return filepath.ToSlash(url)
+}
@@ -0,0 +1,66 @@
+package services
+
+import (
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func uploadString(name, contents string) UploadFile {
+	return UploadFile{Name: name, Open: func() (io.ReadCloser, error) {
+		return io.NopCloser(strings.NewReader(contents)), nil
+	}}
+}
+
+func TestFailedUploadLeavesTheFolderAsItWas(t *testing.T) {
+	root := t.TempDir()
+	assets := filepath.Join(root, "assets")
+	folder := filepath.Join(assets, "town")
+	if err := os.MkdirAll(filepath.Join(root, "scenes"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.MkdirAll(folder, 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(folder, "sign.png"), []byte("old sign"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	index := NewProjectIndex(filepath.Join(root, "scenes"), assets, "")
+	if err := index.Build(); err != nil {
+		t.Fatal(err)
+	}
+	service := NewAssetService(assets, root, index, NewSceneService(filepath.Join(root, "scenes")), nil)
+
+	failed := errors.New("connection reset")
+	files := []UploadFile{
+		uploadString("sign.png", "new sign"),
+		uploadString("tree.png", "tree"),
+		{Name: "bench.png", Open: func() (io.ReadCloser, error) { return nil, failed }},
+	}
+	if _, err := service.Upload(files, UploadOptions{Folder: "town", Overwrite: true}); !errors.Is(err, failed) {
+		t.Fatalf("Upload: %v, want %v", err, failed)
+	}
+
+	entries, err := os.ReadDir(folder)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 1 || entries[0].Name() != "sign.png" {
+		var names []string
+		for _, entry := range entries {
+			names = append(names, entry.Name())
+		}
+		t.Errorf("folder holds %v, want [sign.png]", names)
+	}
+	if data, err := os.ReadFile(filepath.Join(folder, "sign.png")); err != nil || string(data) != "old sign" {
+		t.Errorf("sign.png = %q, %v, want the old contents", data, err)
+	}
+	for _, asset := range index.Assets() {
+		if asset.Path == "town/tree.png" {
+			t.Error("tree.png is still indexed")
+		}
+	}
+}
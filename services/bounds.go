@@ -0,0 +1,447 @@
+package services
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"tuxedo-core/models"
+)
+
+// Text is measured without the font, from average glyph proportions. The
+// sizes of Text objects without a fixed or explicit size are estimates.
+const (
+	textCharWidth   = 0.6 // Average glyph width, as a fraction of the font size
+	textLineHeight  = 1.2 // Line height, as a fraction of the font size
+	defaultFontSize = 16  // Phaser's default Text font size, in pixels
+)
+
+// Phaser's default sizes for shapes and zones created without one
+const (
+	defaultShapeSize = 128
+	defaultZoneSize  = 1
+)
+
+// Rect is an axis-aligned rectangle in scene coordinates
+type Rect struct {
+	X      float64 `json:"x"`
+	Y      float64 `json:"y"`
+	Width  float64 `json:"width"`
+	Height float64 `json:"height"`
+}
+
+// WorldTransform is an object's transform composed with those of its
+// parents. Matrix is the affine matrix [a b c d tx ty] mapping the
+// object's local coordinates to scene coordinates.
+type WorldTransform struct {
+	X      float64    `json:"x"`
+	Y      float64    `json:"y"`
+	ScaleX float64    `json:"scaleX"`
+	ScaleY float64    `json:"scaleY"`
+	Angle  float64    `json:"angle"` // Degrees
+	Matrix [6]float64 `json:"matrix"`
+}
+
+// ObjectBounds is the world transform and the bounds of one object. As in
+// Phaser, the bounds of a trimmed atlas frame cover its untrimmed source
+// size.
+type ObjectBounds struct {
+	ID            string         `json:"id"`
+	Label         string         `json:"label"`
+	Type          string         `json:"type"`
+	Parent        string         `json:"parent,omitempty"`
+	Visible       bool           `json:"visible"` // False when the object or one of its parents is hidden
+	World         WorldTransform `json:"world"`
+	Width         float64        `json:"width"`                   // Unscaled size; zero for containers and layers
+	Height        float64        `json:"height"`                  // Unscaled size; zero for containers and layers
+	Bounds        *Rect          `json:"bounds"`                  // Nil when the size of the object is unknown
+	TrimmedBounds *Rect          `json:"trimmedBounds,omitempty"` // Pixels a trimmed frame covers within Bounds
+	Estimated     bool           `json:"estimated,omitempty"`     // The size is measured approximately
+	Problem       string         `json:"problem,omitempty"`       // Why the size is unknown
+}
+
+// ComputeBounds returns the world transform and axis-aligned bounds of
+// every object, parents before their children, and the bounds of all
+// visible objects together. Sizes come from the texture frames, or from
+// the object's width and height for text, shapes and zones. A container's
+// bounds enclose its children.
+func ComputeBounds(objects models.Objects, textures *Textures) ([]ObjectBounds, *Rect) {
+	c := &boundsComputer{textures: textures}
+	c.addObjects(objects, "", identity, true)
+
+	var scene *Rect
+	for _, obj := range c.result {
+		if obj.Visible && obj.Bounds != nil {
+			scene = union(scene, obj.Bounds)
+		}
+	}
+	return c.result, scene
+}
+
+type boundsComputer struct {
+	textures *Textures
+	result   []ObjectBounds
+}
+
+// addObjects adds the objects under parent and returns their combined
+// bounds
+func (c *boundsComputer) addObjects(objects models.Objects, parent string, world matrix, visible bool) *Rect {
+	var bounds *Rect
+	for _, obj := range objects {
+		if b := c.addObject(obj, parent, world, visible); b != nil {
+			bounds = union(bounds, b)
+		}
+	}
+	return bounds
+}
+
+func (c *boundsComputer) addObject(obj models.Object, parent string, parentWorld matrix, parentVisible bool) *Rect {
+	base := obj.Base()
+	objectType := base.Type
+	if _, ok := obj.(*models.Instance); ok {
+		objectType = "Instance"
+	}
+
+	world := parentWorld
+	visible := parentVisible
+	if transform := objectTransform(obj); transform != nil {
+		world = parentWorld.multiply(localMatrix(transform))
+		visible = visible && (transform.Visible == nil || *transform.Visible)
+	}
+	if layer, ok := obj.(*models.Layer); ok {
+		visible = visible && (layer.Visible == nil || *layer.Visible)
+	}
+
+	index := len(c.result)
+	c.result = append(c.result, ObjectBounds{
+		ID:      base.ID,
+		Label:   base.Label,
+		Type:    objectType,
+		Parent:  parent,
+		Visible: visible,
+		World:   world.transform(),
+	})
+
+	if children := models.Children(obj); children != nil {
+		bounds := c.addObjects(children, base.ID, world, visible)
+		entry := &c.result[index]
+		entry.Bounds = bounds
+		if instance, ok := obj.(*models.Instance); ok && bounds == nil {
+			entry.Problem = instanceProblem(instance)
+		}
+		return bounds
+	}
+
+	entry := &c.result[index]
+	shape, err := c.shape(obj)
+	if err != nil {
+		entry.Problem = err.Error()
+		if instance, ok := obj.(*models.Instance); ok {
+			entry.Problem = instanceProblem(instance)
+		}
+		return nil
+	}
+	entry.Width, entry.Height = shape.width, shape.height
+	entry.Estimated = shape.estimated
+	entry.Bounds = world.bounds(shape.rect)
+	if shape.trimmed != nil {
+		entry.TrimmedBounds = world.bounds(*shape.trimmed)
+	}
+	return entry.Bounds
+}
+
+// instanceProblem explains why a prefab instance was not expanded
+func instanceProblem(instance *models.Instance) string {
+	var origin ObjectOrigin
+	if ok, _ := instance.Properties.Decode(originProperty, &origin); ok && origin.Error != "" {
+		return origin.Error
+	}
+	return fmt.Sprintf("prefab %s is not expanded", instance.PrefabId)
+}
+
+// objectShape is the area an object covers in its local coordinates
+type objectShape struct {
+	width, height float64
+	rect          Rect  // Area relative to the object's position
+	trimmed       *Rect // Area holding the pixels of a trimmed frame
+	estimated     bool
+}
+
+// shape returns the local area of a sized object
+func (c *boundsComputer) shape(obj models.Object) (objectShape, error) {
+	switch o := obj.(type) {
+	case *models.Image:
+		return c.textureShape(o.Texture, o.Origin, nil)
+	case *models.Sprite:
+		return c.textureShape(o.Texture, o.Origin, nil)
+	case *models.TileSprite:
+		return c.textureShape(o.Texture, o.Origin, &o.Size)
+	case *models.NineSlice:
+		return c.textureShape(o.Texture, o.Origin, &o.Size)
+	case *models.Text:
+		return textShape(o), nil
+	case *models.BitmapText:
+		return bitmapTextShape(o)
+	case *models.Rectangle:
+		return sizedShape(o.Size, defaultShapeSize, o.Origin, 0.5), nil
+	case *models.Ellipse:
+		return sizedShape(o.Size, defaultShapeSize, o.Origin, 0.5), nil
+	case *models.Zone:
+		return sizedShape(o.Size, defaultZoneSize, o.Origin, 0.5), nil
+	case *models.Polygon:
+		return polygonShape(o)
+	}
+	return objectShape{}, fmt.Errorf("size of %s objects is unknown", obj.Base().Type)
+}
+
+// textureShape sizes an object by its texture frame. Objects with their
+// own size, like tile sprites, only take the frame size when they have none.
+func (c *boundsComputer) textureShape(texture *models.Texture, origin models.Origin, size *models.Size) (objectShape, error) {
+	var frame models.AtlasFrame
+	var frameErr error
+	if texture == nil || texture.Key == "" {
+		frameErr = fmt.Errorf("object has no texture")
+	} else {
+		frame, frameErr = c.textures.Frame(texture.Key, texture.Frame)
+	}
+
+	if size != nil && size.Width != nil && size.Height != nil {
+		return originShape(*size.Width, *size.Height, origin, 0.5), nil
+	}
+	if frameErr != nil {
+		return objectShape{}, frameErr
+	}
+
+	width, height := float64(frame.SourceSize.W), float64(frame.SourceSize.H)
+	if size != nil {
+		if size.Width != nil {
+			width = *size.Width
+		}
+		if size.Height != nil {
+			height = *size.Height
+		}
+	}
+	shape := originShape(width, height, origin, 0.5)
+	if frame.Trimmed && size == nil {
+		trim := frame.SpriteSourceSize
+		shape.trimmed = &Rect{
+			X:      shape.rect.X + float64(trim.X),
+			Y:      shape.rect.Y + float64(trim.Y),
+			Width:  float64(trim.W),
+			Height: float64(trim.H),
+		}
+	}
+	return shape, nil
+}
+
+// textShape sizes a Text by its fixed size, its explicit size or, failing
+// those, an estimate from the font size
+func textShape(text *models.Text) objectShape {
+	fontSize := float64(defaultFontSize)
+	if size, err := strconv.ParseFloat(strings.TrimSuffix(strings.TrimSpace(text.FontSize), "px"), 64); err == nil && size > 0 {
+		fontSize = size
+	}
+	width, height := estimateText(text.Text, fontSize)
+	width += value(text.PaddingLeft, 0) + value(text.PaddingRight, 0)
+	height += value(text.PaddingTop, 0) + value(text.PaddingBottom, 0)
+	estimated := true
+
+	if w := firstPositive(text.FixedWidth, text.Width); w > 0 {
+		width = w
+		if h := firstPositive(text.FixedHeight, text.Height); h > 0 {
+			height = h
+			estimated = false
+		}
+	} else if h := firstPositive(text.FixedHeight, text.Height); h > 0 {
+		height = h
+	}
+
+	shape := originShape(width, height, text.Origin, 0)
+	shape.estimated = estimated
+	return shape
+}
+
+// bitmapTextShape estimates the size of a BitmapText from its font size
+func bitmapTextShape(text *models.BitmapText) (objectShape, error) {
+	if text.FontSize == nil || *text.FontSize <= 0 {
+		return objectShape{}, fmt.Errorf("size of bitmap text without a font size is unknown")
+	}
+	width, height := estimateText(text.Text, *text.FontSize)
+	if text.MaxWidth != nil && *text.MaxWidth > 0 {
+		width = min(width, *text.MaxWidth)
+	}
+	shape := originShape(width, height, text.Origin, 0)
+	shape.estimated = true
+	return shape, nil
+}
+
+func estimateText(text string, fontSize float64) (float64, float64) {
+	lines := strings.Split(text, "\n")
+	longest := 0
+	for _, line := range lines {
+		longest = max(longest, utf8.RuneCountInString(line))
+	}
+	return float64(longest) * fontSize * textCharWidth, float64(len(lines)) * fontSize * textLineHeight
+}
+
+// sizedShape sizes a shape or zone by its width and height
+func sizedShape(size models.Size, defaultSize float64, origin models.Origin, defaultOrigin float64) objectShape {
+	return originShape(value(size.Width, defaultSize), value(size.Height, defaultSize), origin, defaultOrigin)
+}
+
+// polygonShape sizes a Polygon by the bounding box of its points
+func polygonShape(polygon *models.Polygon) (objectShape, error) {
+	fields := strings.Fields(strings.ReplaceAll(polygon.Points, ",", " "))
+	if len(fields) < 2 || len(fields)%2 != 0 {
+		return objectShape{}, fmt.Errorf("polygon has no points")
+	}
+	minX, minY := math.Inf(1), math.Inf(1)
+	maxX, maxY := math.Inf(-1), math.Inf(-1)
+	for i := 0; i < len(fields); i += 2 {
+		x, errX := strconv.ParseFloat(fields[i], 64)
+		y, errY := strconv.ParseFloat(fields[i+1], 64)
+		if errX != nil || errY != nil {
+			return objectShape{}, fmt.Errorf("polygon points are invalid: %s", polygon.Points)
+		}
+		minX, minY = min(minX, x), min(minY, y)
+		maxX, maxY = max(maxX, x), max(maxY, y)
+	}
+
+	shape := originShape(maxX-minX, maxY-minY, polygon.Origin, 0.5)
+	shape.rect.X += minX
+	shape.rect.Y += minY
+	return shape, nil
+}
+
+// originShape places a width by height area so the origin is at the
+// object's position
+func originShape(width, height float64, origin models.Origin, defaultOrigin float64) objectShape {
+	originX := value(origin.OriginX, defaultOrigin)
+	originY := value(origin.OriginY, defaultOrigin)
+	return objectShape{
+		width:  width,
+		height: height,
+		rect:   Rect{X: -originX * width, Y: -originY * height, Width: width, Height: height},
+	}
+}
+
+// objectTransform returns the transform of objects that have one
+func objectTransform(obj models.Object) *models.Transform {
+	switch o := obj.(type) {
+	case *models.Image:
+		return &o.Transform
+	case *models.Sprite:
+		return &o.Transform
+	case *models.TileSprite:
+		return &o.Transform
+	case *models.NineSlice:
+		return &o.Transform
+	case *models.Text:
+		return &o.Transform
+	case *models.BitmapText:
+		return &o.Transform
+	case *models.Rectangle:
+		return &o.Transform
+	case *models.Ellipse:
+		return &o.Transform
+	case *models.Polygon:
+		return &o.Transform
+	case *models.Zone:
+		return &o.Transform
+	case *models.Container:
+		return &o.Transform
+	case *models.Instance:
+		return &o.Transform
+	case *models.Generic:
+		return &o.Transform
+	}
+	return nil
+}
+
+func value(v *float64, fallback float64) float64 {
+	if v == nil {
+		return fallback
+	}
+	return *v
+}
+
+func firstPositive(values ...*float64) float64 {
+	for _, v := range values {
+		if v != nil && *v > 0 {
+			return *v
+		}
+	}
+	return 0
+}
+
+func union(a, b *Rect) *Rect {
+	if a == nil {
+		r := *b
+		return &r
+	}
+	minX, minY := min(a.X, b.X), min(a.Y, b.Y)
+	maxX, maxY := max(a.X+a.Width, b.X+b.Width), max(a.Y+a.Height, b.Y+b.Height)
+	return &Rect{X: minX, Y: minY, Width: maxX - minX, Height: maxY - minY}
+}
+
+// matrix is an affine transform [a b c d tx ty] mapping (x, y) to
+// (a*x + c*y + tx, b*x + d*y + ty), as in Phaser's TransformMatrix
+type matrix [6]float64
+
+var identity = matrix{1, 0, 0, 1, 0, 0}
+
+// localMatrix returns the transform of an object within its parent:
+// scale, then rotate, then translate
+func localMatrix(t *models.Transform) matrix {
+	scaleX, scaleY := value(t.ScaleX, 1), value(t.ScaleY, 1)
+	sin, cos := math.Sincos(value(t.Angle, 0) * math.Pi / 180)
+	return matrix{cos * scaleX, sin * scaleX, -sin * scaleY, cos * scaleY, t.X, t.Y}
+}
+
+// multiply returns m applied after child
+func (m matrix) multiply(child matrix) matrix {
+	return matrix{
+		m[0]*child[0] + m[2]*child[1],
+		m[1]*child[0] + m[3]*child[1],
+		m[0]*child[2] + m[2]*child[3],
+		m[1]*child[2] + m[3]*child[3],
+		m[0]*child[4] + m[2]*child[5] + m[4],
+		m[1]*child[4] + m[3]*child[5] + m[5],
+	}
+}
+
+func (m matrix) apply(x, y float64) (float64, float64) {
+	return m[0]*x + m[2]*y + m[4], m[1]*x + m[3]*y + m[5]
+}
+
+// bounds returns the axis-aligned bounds of a local rectangle
+func (m matrix) bounds(r Rect) *Rect {
+	minX, minY := math.Inf(1), math.Inf(1)
+	maxX, maxY := math.Inf(-1), math.Inf(-1)
+	for _, corner := range [][2]float64{{r.X, r.Y}, {r.X + r.Width, r.Y}, {r.X, r.Y + r.Height}, {r.X + r.Width, r.Y + r.Height}} {
+		x, y := m.apply(corner[0], corner[1])
+		minX, minY = min(minX, x), min(minY, y)
+		maxX, maxY = max(maxX, x), max(maxY, y)
+	}
+	return &Rect{X: minX, Y: minY, Width: maxX - minX, Height: maxY - minY}
+}
+
+// transform decomposes the matrix into position, scale and angle. A
+// mirrored matrix gets a negative vertical scale.
+func (m matrix) transform() WorldTransform {
+	scaleX := math.Hypot(m[0], m[1])
+	scaleY := 0.0
+	if scaleX != 0 {
+		scaleY = (m[0]*m[3] - m[1]*m[2]) / scaleX
+	}
+	return WorldTransform{
+		X:      m[4],
+		Y:      m[5],
+		ScaleX: scaleX,
+		ScaleY: scaleY,
+		Angle:  math.Atan2(m[1], m[0]) * 180 / math.Pi,
+		Matrix: m,
+	}
+}
@@ -0,0 +1,282 @@
+package services
+
+import (
+	"encoding/json"
+	"math"
+	"testing"
+
+	"tuxedo-core/models"
+)
+
+// testTextures holds the texture "town" with an untrimmed "bench" frame
+// and a "sign" frame trimmed from 40x20 to 30x10
+func testTextures() *Textures {
+	source := &TextureSource{
+		Key:  "town",
+		Type: models.PackAtlas,
+		Frames: []TextureFrame{
+			{AtlasFrame: untrimmedFrame("bench", models.FrameRect{W: 16, H: 8})},
+			{AtlasFrame: models.AtlasFrame{
+				Name:             "sign",
+				Frame:            models.FrameRect{X: 16, W: 30, H: 10},
+				Trimmed:          true,
+				SpriteSourceSize: models.FrameRect{X: 4, Y: 2, W: 30, H: 10},
+				SourceSize:       models.FrameSize{W: 40, H: 20},
+			}},
+		},
+		frames: map[string]int{"bench": 0, "sign": 1},
+	}
+	return &Textures{textures: map[string]*TextureSource{"town": source}}
+}
+
+const boundsTolerance = 1e-9
+
+func near(a, b float64) bool {
+	return math.Abs(a-b) < boundsTolerance
+}
+
+func sameRect(a, b *Rect) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	return near(a.X, b.X) && near(a.Y, b.Y) && near(a.Width, b.Width) && near(a.Height, b.Height)
+}
+
+func sameMatrix(a, b matrix) bool {
+	for i := range a {
+		if !near(a[i], b[i]) {
+			return false
+		}
+	}
+	return true
+}
+
+func float(v float64) *float64 {
+	return &v
+}
+
+func TestMatrix(t *testing.T) {
+	tests := []struct {
+		name      string
+		m         matrix
+		want      matrix
+		in, out   [2]float64 // A point and where the matrix maps it
+		transform WorldTransform
+	}{
+		{
+			name:      "identity",
+			m:         localMatrix(&models.Transform{}),
+			want:      identity,
+			in:        [2]float64{3, 4},
+			out:       [2]float64{3, 4},
+			transform: WorldTransform{ScaleX: 1, ScaleY: 1},
+		},
+		{
+			name:      "scaled, rotated and moved",
+			m:         localMatrix(&models.Transform{X: 100, Y: 50, ScaleX: float(2), ScaleY: float(3), Angle: float(90)}),
+			want:      matrix{0, 2, -3, 0, 100, 50},
+			in:        [2]float64{1, 1},
+			out:       [2]float64{97, 52},
+			transform: WorldTransform{X: 100, Y: 50, ScaleX: 2, ScaleY: 3, Angle: 90},
+		},
+		{
+			name:      "mirrored",
+			m:         localMatrix(&models.Transform{ScaleY: float(-1)}),
+			want:      matrix{1, 0, 0, -1, 0, 0},
+			in:        [2]float64{2, 5},
+			out:       [2]float64{2, -5},
+			transform: WorldTransform{ScaleX: 1, ScaleY: -1},
+		},
+		{
+			name: "child of a rotated parent",
+			m: localMatrix(&models.Transform{X: 10, Y: 20, Angle: float(90)}).
+				multiply(localMatrix(&models.Transform{X: 5, ScaleX: float(2), ScaleY: float(2)})),
+			want:      matrix{0, 2, -2, 0, 10, 25},
+			in:        [2]float64{1, 0},
+			out:       [2]float64{10, 27},
+			transform: WorldTransform{X: 10, Y: 25, ScaleX: 2, ScaleY: 2, Angle: 90},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if !sameMatrix(tt.m, tt.want) {
+				t.Errorf("matrix = %v, want %v", tt.m, tt.want)
+			}
+			if x, y := tt.m.apply(tt.in[0], tt.in[1]); !near(x, tt.out[0]) || !near(y, tt.out[1]) {
+				t.Errorf("apply(%v) = %v, %v, want %v", tt.in, x, y, tt.out)
+			}
+			got := tt.m.transform()
+			want := tt.transform
+			if !near(got.X, want.X) || !near(got.Y, want.Y) || !near(got.ScaleX, want.ScaleX) || !near(got.ScaleY, want.ScaleY) || !near(got.Angle, want.Angle) {
+				t.Errorf("transform = %+v, want %+v", got, want)
+			}
+		})
+	}
+}
+
+func TestMatrixBounds(t *testing.T) {
+	m := localMatrix(&models.Transform{X: 100, Y: 50, Angle: float(45)})
+	got := m.bounds(Rect{X: -1, Y: -1, Width: 2, Height: 2})
+	if want := (&Rect{X: 100 - math.Sqrt2, Y: 50 - math.Sqrt2, Width: 2 * math.Sqrt2, Height: 2 * math.Sqrt2}); !sameRect(got, want) {
+		t.Errorf("bounds = %+v, want %+v", got, want)
+	}
+}
+
+func TestTextureShape(t *testing.T) {
+	tests := []struct {
+		name    string
+		texture *models.Texture
+		origin  models.Origin
+		size    *models.Size
+		want    objectShape
+		wantErr string
+	}{
+		{
+			name:    "untrimmed frame",
+			texture: &models.Texture{Key: "town", Frame: "bench"},
+			want:    objectShape{width: 16, height: 8, rect: Rect{X: -8, Y: -4, Width: 16, Height: 8}},
+		},
+		{
+			name:    "trimmed frame covers its source size",
+			texture: &models.Texture{Key: "town", Frame: "sign"},
+			want: objectShape{width: 40, height: 20, rect: Rect{X: -20, Y: -10, Width: 40, Height: 20},
+				trimmed: &Rect{X: -16, Y: -8, Width: 30, Height: 10}},
+		},
+		{
+			name:    "trimmed frame with its origin at the top left",
+			texture: &models.Texture{Key: "town", Frame: "sign"},
+			origin:  models.Origin{OriginX: float(0), OriginY: float(0)},
+			want: objectShape{width: 40, height: 20, rect: Rect{Width: 40, Height: 20},
+				trimmed: &Rect{X: 4, Y: 2, Width: 30, Height: 10}},
+		},
+		{
+			name:    "own size overrides the frame",
+			texture: &models.Texture{Key: "town", Frame: "sign"},
+			size:    &models.Size{Width: float(100)},
+			want:    objectShape{width: 100, height: 20, rect: Rect{X: -50, Y: -10, Width: 100, Height: 20}},
+		},
+		{
+			name:    "own size without a texture",
+			texture: &models.Texture{Key: "missing"},
+			size:    &models.Size{Width: float(10), Height: float(6)},
+			want:    objectShape{width: 10, height: 6, rect: Rect{X: -5, Y: -3, Width: 10, Height: 6}},
+		},
+		{
+			name:    "missing frame",
+			texture: &models.Texture{Key: "town", Frame: "tree"},
+			wantErr: "frame tree not found in texture town",
+		},
+		{
+			name:    "no texture",
+			wantErr: "object has no texture",
+		},
+	}
+	c := &boundsComputer{textures: testTextures()}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := c.textureShape(tt.texture, tt.origin, tt.size)
+			if tt.wantErr != "" {
+				if err == nil || err.Error() != tt.wantErr {
+					t.Fatalf("error = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got.width != tt.want.width || got.height != tt.want.height || got.rect != tt.want.rect || !sameRect(got.trimmed, tt.want.trimmed) {
+				t.Errorf("shape = %+v (trimmed %+v), want %+v (trimmed %+v)", got, got.trimmed, tt.want, tt.want.trimmed)
+			}
+		})
+	}
+}
+
+func TestComputeBounds(t *testing.T) {
+	// A container scaled by 2 and turned 90 degrees holds the trimmed sign
+	// 10 pixels along its x axis, which ends up 20 pixels down the scene
+	var objects models.Objects
+	err := json.Unmarshal([]byte(`[
+		{"type": "Container", "id": "c1", "label": "board", "x": 100, "y": 50, "scaleX": 2, "scaleY": 2, "angle": 90, "list": [
+			{"type": "Image", "id": "i1", "label": "sign", "x": 10, "texture": {"key": "town", "frame": "sign"}}
+		]},
+		{"type": "Rectangle", "id": "r1", "label": "box", "width": 10, "height": 10},
+		{"type": "Rectangle", "id": "r2", "label": "hidden", "x": 1000, "y": 1000, "visible": false},
+		{"type": "Image", "id": "i2", "label": "lost", "texture": {"key": "town", "frame": "tree"}}
+	]`), &objects)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	result, scene := ComputeBounds(objects, testTextures())
+
+	tests := []struct {
+		id            string
+		parent        string
+		visible       bool
+		world         WorldTransform
+		width, height float64
+		bounds        *Rect
+		trimmed       *Rect
+		problem       string
+	}{
+		{
+			id: "c1", visible: true,
+			world:  WorldTransform{X: 100, Y: 50, ScaleX: 2, ScaleY: 2, Angle: 90, Matrix: matrix{0, 2, -2, 0, 100, 50}},
+			bounds: &Rect{X: 80, Y: 30, Width: 40, Height: 80},
+		},
+		{
+			id: "i1", parent: "c1", visible: true,
+			world: WorldTransform{X: 100, Y: 70, ScaleX: 2, ScaleY: 2, Angle: 90, Matrix: matrix{0, 2, -2, 0, 100, 70}},
+			width: 40, height: 20,
+			bounds:  &Rect{X: 80, Y: 30, Width: 40, Height: 80},
+			trimmed: &Rect{X: 96, Y: 38, Width: 20, Height: 60},
+		},
+		{
+			id: "r1", visible: true,
+			world: WorldTransform{ScaleX: 1, ScaleY: 1, Matrix: identity},
+			width: 10, height: 10,
+			bounds: &Rect{X: -5, Y: -5, Width: 10, Height: 10},
+		},
+		{
+			id:    "r2",
+			world: WorldTransform{X: 1000, Y: 1000, ScaleX: 1, ScaleY: 1, Matrix: matrix{1, 0, 0, 1, 1000, 1000}},
+			width: 128, height: 128,
+			bounds: &Rect{X: 936, Y: 936, Width: 128, Height: 128},
+		},
+		{
+			id: "i2", visible: true,
+			world:   WorldTransform{ScaleX: 1, ScaleY: 1, Matrix: identity},
+			problem: "frame tree not found in texture town",
+		},
+	}
+	if len(result) != len(tests) {
+		t.Fatalf("got %d objects, want %d", len(result), len(tests))
+	}
+	for i, tt := range tests {
+		t.Run(tt.id, func(t *testing.T) {
+			got := result[i]
+			if got.ID != tt.id || got.Parent != tt.parent || got.Visible != tt.visible || got.Problem != tt.problem {
+				t.Errorf("object = %s under %q, visible %v, problem %q", got.ID, got.Parent, got.Visible, got.Problem)
+			}
+			w := got.World
+			if !near(w.X, tt.world.X) || !near(w.Y, tt.world.Y) || !near(w.ScaleX, tt.world.ScaleX) || !near(w.ScaleY, tt.world.ScaleY) ||
+				!near(w.Angle, tt.world.Angle) || !sameMatrix(w.Matrix, tt.world.Matrix) {
+				t.Errorf("world = %+v, want %+v", w, tt.world)
+			}
+			if got.Width != tt.width || got.Height != tt.height {
+				t.Errorf("size = %vx%v, want %vx%v", got.Width, got.Height, tt.width, tt.height)
+			}
+			if !sameRect(got.Bounds, tt.bounds) {
+				t.Errorf("bounds = %+v, want %+v", got.Bounds, tt.bounds)
+			}
+			if !sameRect(got.TrimmedBounds, tt.trimmed) {
+				t.Errorf("trimmed bounds = %+v, want %+v", got.TrimmedBounds, tt.trimmed)
+			}
+		})
+	}
+
+	// The hidden rectangle and the object without a size are left out
+	if want := (&Rect{X: -5, Y: -5, Width: 125, Height: 115}); !sameRect(scene, want) {
+		t.Errorf("scene bounds = %+v, want %+v", scene, want)
+	}
+}
@@ -0,0 +1,341 @@
+package services
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"log"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+
+	"tuxedo-core/models"
+
+	"github.com/fsnotify/fsnotify"
+)
+
+// Component definitions are read from Phaser Editor user component files
+// and from tuxedo's own JSON format
+const (
+	phaserComponentsExt = ".components"
+	tuxedoComponentsExt = ".components.json"
+)
+
+// ComponentFileError reports a component file or definition that could not
+// be used
+type ComponentFileError struct {
+	File    string `json:"file"` // Relative to the registry's root folder
+	Message string `json:"message"`
+}
+
+// ComponentRegistry holds the user component definitions of the project.
+// Definitions are read from every .components (Phaser Editor) and
+// .components.json (tuxedo) file under its root folder and reloaded when
+// one of those files changes.
+type ComponentRegistry struct {
+	root string
+
+	mu          sync.RWMutex
+	definitions map[string]models.ComponentDefinition
+	errors      []ComponentFileError
+
+	unsubscribe func()
+	watcher     *FileWatcher
+}
+
+// componentsFile is the layout shared by both formats. Phaser Editor
+// writes gameObjectType, a type object and defValue; the tuxedo format
+// uses gameObjectTypes, a type name and default.
+type componentsFile struct {
+	Components []struct {
+		Name            string   `json:"name"`
+		DisplayName     string   `json:"displayName"`
+		GameObjectType  string   `json:"gameObjectType"`
+		GameObjectTypes []string `json:"gameObjectTypes"`
+		Properties      []struct {
+			Name     string          `json:"name"`
+			Label    string          `json:"label"`
+			Type     propertyType    `json:"type"`
+			Options  []string        `json:"options"`
+			DefValue json.RawMessage `json:"defValue"`
+			Default  json.RawMessage `json:"default"`
+		} `json:"properties"`
+	} `json:"components"`
+}
+
+// propertyType is a property type written either as a name or, by Phaser
+// Editor, as {"id": "option", "options": [...]}
+type propertyType struct {
+	ID      string   `json:"id"`
+	Options []string `json:"options"`
+}
+
+func (t *propertyType) UnmarshalJSON(data []byte) error {
+	if err := json.Unmarshal(data, &t.ID); err == nil {
+		return nil
+	}
+	type plain propertyType
+	return json.Unmarshal(data, (*plain)(t))
+}
+
+func NewComponentRegistry(root string) *ComponentRegistry {
+	return &ComponentRegistry{root: root, definitions: map[string]models.ComponentDefinition{}}
+}
+
+// Load reads every component file. A component defined in more than one
+// file keeps the first definition, in path order. A missing root folder
+// means the project defines no components.
+func (r *ComponentRegistry) Load() error {
+	var files []string
+	err := walkTree(r.root, func(p string, info os.FileInfo) {
+		if !info.IsDir() && isComponentsFile(p) {
+			files = append(files, p)
+		}
+	})
+	if errors.Is(err, fs.ErrNotExist) {
+		log.Printf("Component registry: %s does not exist, no components are defined", r.root)
+	} else if err != nil {
+		return err
+	}
+	slices.Sort(files)
+
+	definitions := map[string]models.ComponentDefinition{}
+	var errs []ComponentFileError
+	for _, p := range files {
+		rel, _ := filepath.Rel(r.root, p)
+		rel = filepath.ToSlash(rel)
+
+		loaded, err := readComponentsFile(p, rel)
+		if err != nil {
+			errs = append(errs, ComponentFileError{File: rel, Message: err.Error()})
+			continue
+		}
+		for _, definition := range loaded {
+			if existing, ok := definitions[definition.Name]; ok {
+				errs = append(errs, ComponentFileError{
+					File:    rel,
+					Message: fmt.Sprintf("component %s is already defined in %s", definition.Name, existing.Source),
+				})
+				continue
+			}
+			definitions[definition.Name] = definition
+		}
+	}
+
+	r.mu.Lock()
+	r.definitions = definitions
+	r.errors = errs
+	r.mu.Unlock()
+	return nil
+}
+
+// Start loads the definitions and reloads them whenever a component file
+// changes. A root folder inside the scenes or assets tree follows the
+// events of the index, which must already be started; only a folder
+// outside both is watched on its own.
+func (r *ComponentRegistry) Start(index *ProjectIndex) error {
+	if err := r.Load(); err != nil {
+		return err
+	}
+
+	for _, tree := range []string{SceneTree, AssetTree} {
+		if isInside(index.Root(tree), r.root) {
+			r.unsubscribe = index.Subscribe(tree, r.handleEvents)
+			return nil
+		}
+	}
+
+	if _, err := os.Stat(r.root); err != nil {
+		return nil // Nothing to watch
+	}
+	watcher, err := NewFileWatcher(r.root)
+	if err != nil {
+		return err
+	}
+	watcher.WatchDebounced(indexDebounce, r.handleEvents)
+	r.watcher = watcher
+	return nil
+}
+
+func (r *ComponentRegistry) handleEvents(events []fsnotify.Event) {
+	// Folders, which have no extension, may hold component files
+	changed := slices.ContainsFunc(events, func(event fsnotify.Event) bool {
+		return isInside(r.root, event.Name) && (isComponentsFile(event.Name) || filepath.Ext(event.Name) == "")
+	})
+	if !changed {
+		return
+	}
+	if err := r.Load(); err != nil {
+		log.Printf("Component registry: %v", err)
+	}
+}
+
+// Close stops following component file changes
+func (r *ComponentRegistry) Close() {
+	if r.unsubscribe != nil {
+		r.unsubscribe()
+	}
+	if r.watcher != nil {
+		r.watcher.Close()
+	}
+}
+
+// isInside reports whether p is dir or a path below it
+func isInside(dir, p string) bool {
+	rel, err := filepath.Rel(dir, p)
+	return err == nil && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator))
+}
+
+// Definitions returns every component definition, sorted by name
+func (r *ComponentRegistry) Definitions() []models.ComponentDefinition {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	definitions := make([]models.ComponentDefinition, 0, len(r.definitions))
+	for _, definition := range r.definitions {
+		definitions = append(definitions, definition)
+	}
+	slices.SortFunc(definitions, func(a, b models.ComponentDefinition) int { return strings.Compare(a.Name, b.Name) })
+	return definitions
+}
+
+// Definition returns the definition of one component
+func (r *ComponentRegistry) Definition(name string) (models.ComponentDefinition, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	definition, ok := r.definitions[name]
+	return definition, ok
+}
+
+// Errors returns the problems found in the component files
+func (r *ComponentRegistry) Errors() []ComponentFileError {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	return slices.Clone(r.errors)
+}
+
+// Check validates the components of obj and its children. Projects without
+// any component definitions are not checked.
+func (r *ComponentRegistry) Check(obj *models.GameObject) []models.ComponentError {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	if len(r.definitions) == 0 {
+		return nil
+	}
+	return models.CheckComponents(obj, r.definitions)
+}
+
+// ComponentCheckError is returned by scene writes that would add component
+// uses not matching their definitions
+type ComponentCheckError struct {
+	Scene  string
+	Errors []models.ComponentError
+}
+
+func (e *ComponentCheckError) Error() string {
+	messages := make([]string, len(e.Errors))
+	for i, err := range e.Errors {
+		messages[i] = err.Error()
+	}
+	return fmt.Sprintf("scene %s does not match its component definitions: %s", e.Scene, strings.Join(messages, "; "))
+}
+
+// CheckScene validates the components of every object of a scene
+func (r *ComponentRegistry) CheckScene(scene *models.Scene) []models.ComponentError {
+	var errs []models.ComponentError
+	for i := range scene.DisplayList {
+		errs = append(errs, r.Check(&scene.DisplayList[i])...)
+	}
+	return errs
+}
+
+// checkWrite returns a ComponentCheckError when data, the new contents of
+// a scene, has component problems that current, its contents on disk, does
+// not. Problems already in the file do not block unrelated edits, and data
+// that is not a scene is left to the caller.
+func (r *ComponentRegistry) checkWrite(name string, current, data []byte) error {
+	var scene models.Scene
+	if json.Unmarshal(data, &scene) != nil {
+		return nil
+	}
+	errs := r.CheckScene(&scene)
+	if len(errs) == 0 {
+		return nil
+	}
+
+	var previous models.Scene
+	if current != nil && json.Unmarshal(current, &previous) == nil {
+		existing := r.CheckScene(&previous)
+		errs = slices.DeleteFunc(errs, func(err models.ComponentError) bool { return slices.Contains(existing, err) })
+	}
+	if len(errs) == 0 {
+		return nil
+	}
+	return &ComponentCheckError{Scene: name, Errors: errs}
+}
+
+func isComponentsFile(p string) bool {
+	return strings.HasSuffix(p, phaserComponentsExt) || strings.HasSuffix(p, tuxedoComponentsExt)
+}
+
+// readComponentsFile parses the definitions in a component file of either
+// format
+func readComponentsFile(p, rel string) ([]models.ComponentDefinition, error) {
+	data, err := os.ReadFile(p)
+	if err != nil {
+		return nil, err
+	}
+	var file componentsFile
+	if err := json.Unmarshal(data, &file); err != nil {
+		return nil, err
+	}
+
+	definitions := make([]models.ComponentDefinition, 0, len(file.Components))
+	for i, component := range file.Components {
+		if component.Name == "" {
+			return nil, fmt.Errorf("component %d has no name", i)
+		}
+		definition := models.ComponentDefinition{
+			Name:            component.Name,
+			DisplayName:     component.DisplayName,
+			GameObjectTypes: []string{},
+			Properties:      []models.ComponentProperty{},
+			Source:          rel,
+		}
+		for _, objectType := range append(component.GameObjectTypes, component.GameObjectType) {
+			// Phaser Editor names classes, e.g. Phaser.GameObjects.Image;
+			// the base GameObject class fits every object
+			objectType = strings.TrimPrefix(objectType, "Phaser.GameObjects.")
+			if objectType != "" && objectType != "GameObject" && !slices.Contains(definition.GameObjectTypes, objectType) {
+				definition.GameObjectTypes = append(definition.GameObjectTypes, objectType)
+			}
+		}
+		for _, property := range component.Properties {
+			definition.Properties = append(definition.Properties, models.ComponentProperty{
+				Name:    property.Name,
+				Label:   property.Label,
+				Type:    property.Type.ID,
+				Options: append(property.Options, property.Type.Options...),
+				Default: firstRaw(property.Default, property.DefValue),
+			})
+		}
+		definitions = append(definitions, definition)
+	}
+	return definitions, nil
+}
+
+// firstRaw returns the first non-empty JSON value
+func firstRaw(values ...json.RawMessage) json.RawMessage {
+	for _, v := range values {
+		if len(v) > 0 {
+			return v
+		}
+	}
+	return nil
+}
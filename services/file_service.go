@@ -2,37 +2,135 @@ package services
 
 import (
 	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
 
 	"github.com/fsnotify/fsnotify"
 )
 
 type FileWatcher struct {
-    watcher *fsnotify.Watcher
-    path    string
+	watcher *fsnotify.Watcher
+	path    string
+	done    chan struct{}
 }
 
+// NewFileWatcher watches path and every directory below it.
+// Directories created later are added as soon as their create event arrives.
 func NewFileWatcher(path string) (*FileWatcher, error) {
-    watcher, err := fsnotify.NewWatcher()
-    if err != nil {
-        return nil, err
-    }
-    
-    if err := watcher.Add(path); err != nil {
-        return nil, err
-    }
-    
-    return &FileWatcher{watcher: watcher, path: path}, nil
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return nil, err
+	}
+
+	fw := &FileWatcher{watcher: watcher, path: path, done: make(chan struct{})}
+	if err := fw.addRecursive(path); err != nil {
+		watcher.Close()
+		return nil, err
+	}
+
+	return fw, nil
+}
+
+// addRecursive registers root and all of its subdirectories with the watcher
+func (fw *FileWatcher) addRecursive(root string) error {
+	return filepath.Walk(root, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if !info.IsDir() {
+			return nil
+		}
+		if path != root && isIgnoredName(info.Name()) {
+			return filepath.SkipDir
+		}
+		return fw.watcher.Add(path)
+	})
 }
 
 func (fw *FileWatcher) Watch(callback func(event fsnotify.Event)) {
-    go func() {
-        for {
-            select {
-            case event := <-fw.watcher.Events:
-                callback(event)
-            case err := <-fw.watcher.Errors:
-                log.Println("Watcher error:", err)
-            }
-        }
-    }()
-}
\ No newline at end of file
+	go func() {
+		for {
+			select {
+			case event, ok := <-fw.watcher.Events:
+				if !ok {
+					return
+				}
+				if event.Has(fsnotify.Create) {
+					if info, err := os.Stat(event.Name); err == nil && info.IsDir() && !isIgnoredName(info.Name()) {
+						if err := fw.addRecursive(event.Name); err != nil {
+							log.Println("Watcher error:", err)
+						}
+					}
+				}
+				callback(event)
+			case err, ok := <-fw.watcher.Errors:
+				if !ok {
+					return
+				}
+				log.Println("Watcher error:", err)
+			case <-fw.done:
+				return
+			}
+		}
+	}()
+}
+
+// WatchDebounced collects events until no new event has arrived for delay,
+// then hands the batch to callback. Events for the same file are merged so
+// each path appears once with the union of its operations.
+func (fw *FileWatcher) WatchDebounced(delay time.Duration, callback func(events []fsnotify.Event)) {
+	var (
+		mu      sync.Mutex
+		pending = map[string]fsnotify.Op{}
+		order   []string
+		timer   *time.Timer
+	)
+
+	flush := func() {
+		mu.Lock()
+		batch := make([]fsnotify.Event, 0, len(order))
+		for _, name := range order {
+			batch = append(batch, fsnotify.Event{Name: name, Op: pending[name]})
+		}
+		pending = map[string]fsnotify.Op{}
+		order = nil
+		mu.Unlock()
+
+		if len(batch) > 0 {
+			callback(batch)
+		}
+	}
+
+	fw.Watch(func(event fsnotify.Event) {
+		mu.Lock()
+		defer mu.Unlock()
+
+		if _, seen := pending[event.Name]; !seen {
+			order = append(order, event.Name)
+		}
+		pending[event.Name] |= event.Op
+
+		if timer == nil {
+			timer = time.AfterFunc(delay, flush)
+		} else {
+			timer.Reset(delay)
+		}
+	})
+}
+
+// Close stops watching and releases the underlying watcher
+func (fw *FileWatcher) Close() error {
+	close(fw.done)
+	return fw.watcher.Close()
+}
+
+// isIgnoredName reports whether a file or directory should never produce
+// reload notifications: hidden entries, editor backups and temp files.
+func isIgnoredName(name string) bool {
+	return strings.HasPrefix(name, ".") ||
+		strings.HasSuffix(name, "~") ||
+		strings.HasSuffix(name, ".tmp")
+}
@@ -0,0 +1,202 @@
+package services
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"image"
+	"image/draw"
+	"image/png"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// Errors returned by FrameImages.Render
+var (
+	ErrTextureNotFound = errors.New("texture not found")
+	ErrFrameNotFound   = errors.New("frame not found")
+	ErrImageUnreadable = errors.New("image cannot be read")
+	ErrEmptyFrame      = errors.New("frame has no pixels")
+)
+
+// Rendered frames and decoded images kept in memory. Rendering the frames
+// of one atlas one after the other decodes its image once.
+const (
+	maxCachedFrames = 1024
+	maxCachedImages = 4
+)
+
+// FrameImages renders single texture frames as PNG images the size of the
+// sprite they were cut from. Results are cached until the atlas or image
+// file changes.
+type FrameImages struct {
+	textures *TextureIndex
+
+	mu     sync.Mutex // Guards the caches only; decoding and encoding run unlocked
+	frames map[frameImageKey]*RenderedFrame
+	images map[string]*decodedImage // image path -> decoded image
+}
+
+type frameImageKey struct {
+	key, frame string
+}
+
+// RenderedFrame is a frame encoded as PNG
+type RenderedFrame struct {
+	PNG     []byte
+	ETag    string
+	ModTime time.Time // Latest change of the atlas or image file
+	stamp   string
+}
+
+type decodedImage struct {
+	stamp string
+	image image.Image
+}
+
+func NewFrameImages(textures *TextureIndex) *FrameImages {
+	return &FrameImages{
+		textures: textures,
+		frames:   map[frameImageKey]*RenderedFrame{},
+		images:   map[string]*decodedImage{},
+	}
+}
+
+// Render returns a frame of a texture as PNG. Rotated frames are turned
+// back upright, and trimmed frames are padded back to their source size
+// with transparent pixels, so the image lines up with the object in the
+// scene.
+func (f *FrameImages) Render(key, frame string) (*RenderedFrame, error) {
+	source, ok := f.textures.Textures().Texture(key)
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrTextureNotFound, key)
+	}
+	found, ok := source.Frame(frame)
+	if !ok {
+		return nil, fmt.Errorf("%w: %s in texture %s", ErrFrameNotFound, frame, key)
+	}
+
+	assetsPath := f.textures.index.assetsPath
+	files := []string{filepath.Join(assetsPath, filepath.FromSlash(found.Image))}
+	if source.Atlas != "" {
+		files = append(files, filepath.Join(assetsPath, filepath.FromSlash(source.Atlas)))
+	}
+	stamp, modTime, err := fileStamps(files)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrImageUnreadable, err)
+	}
+
+	if found.SourceSize.W <= 0 || found.SourceSize.H <= 0 {
+		return nil, fmt.Errorf("%w: %s in texture %s is %dx%d", ErrEmptyFrame, frame, key, found.SourceSize.W, found.SourceSize.H)
+	}
+
+	cacheKey := frameImageKey{key, frame}
+	f.mu.Lock()
+	cached, ok := f.frames[cacheKey]
+	f.mu.Unlock()
+	if ok && cached.stamp == stamp {
+		return cached, nil
+	}
+
+	sheet, err := f.decode(files[0], stamp)
+	if err != nil {
+		return nil, err
+	}
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, cropFrame(sheet, found)); err != nil {
+		return nil, err
+	}
+
+	rendered := &RenderedFrame{PNG: buf.Bytes(), ETag: ETag(buf.Bytes()), ModTime: modTime, stamp: stamp}
+	f.mu.Lock()
+	if len(f.frames) >= maxCachedFrames {
+		evictOne(f.frames)
+	}
+	f.frames[cacheKey] = rendered
+	f.mu.Unlock()
+	return rendered, nil
+}
+
+// decode returns the decoded image file, from the cache when unchanged.
+// Two requests for an image not cached yet may both decode it.
+func (f *FrameImages) decode(p, stamp string) (image.Image, error) {
+	f.mu.Lock()
+	cached, ok := f.images[p]
+	f.mu.Unlock()
+	if ok && cached.stamp == stamp {
+		return cached.image, nil
+	}
+
+	file, err := os.Open(p)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrImageUnreadable, err)
+	}
+	defer file.Close()
+	decoded, _, err := image.Decode(file)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %s: %v", ErrImageUnreadable, filepath.Base(p), err)
+	}
+
+	f.mu.Lock()
+	if len(f.images) >= maxCachedImages {
+		evictOne(f.images)
+	}
+	f.images[p] = &decodedImage{stamp: stamp, image: decoded}
+	f.mu.Unlock()
+	return decoded, nil
+}
+
+// cropFrame cuts a frame out of its image and places it in a transparent
+// image of the frame's source size
+func cropFrame(sheet image.Image, frame *TextureFrame) image.Image {
+	trim := frame.SpriteSourceSize
+	out := image.NewNRGBA(image.Rect(0, 0, frame.SourceSize.W, frame.SourceSize.H))
+	origin := sheet.Bounds().Min.Add(image.Pt(frame.Frame.X, frame.Frame.Y))
+
+	if !frame.Rotated {
+		target := image.Rect(trim.X, trim.Y, trim.X+trim.W, trim.Y+trim.H)
+		draw.Draw(out, target, sheet, origin, draw.Src)
+		return out
+	}
+
+	// The frame is stored turned 90 degrees clockwise: its trim.H wide and
+	// trim.W tall area holds the pixel (x, y) at (trim.H-1-y, x)
+	area := image.Rect(0, 0, trim.H, trim.W).Add(origin).Intersect(sheet.Bounds())
+	for y := 0; y < trim.H; y++ {
+		for x := 0; x < trim.W; x++ {
+			p := origin.Add(image.Pt(trim.H-1-y, x))
+			if p.In(area) {
+				out.Set(trim.X+x, trim.Y+y, sheet.At(p.X, p.Y))
+			}
+		}
+	}
+	return out
+}
+
+// fileStamps describes the size and modification time of files, so a
+// change to any of them changes the stamp
+func fileStamps(files []string) (string, time.Time, error) {
+	var stamp string
+	var latest time.Time
+	for _, p := range files {
+		info, err := os.Stat(p)
+		if err != nil {
+			return "", time.Time{}, err
+		}
+		stamp += fmt.Sprintf("%s:%d:%d;", p, info.Size(), info.ModTime().UnixNano())
+		if info.ModTime().After(latest) {
+			latest = info.ModTime()
+		}
+	}
+	return stamp, latest, nil
+}
+
+// evictOne removes an arbitrary entry to make room in a cache
+func evictOne[K comparable, V any](cache map[K]V) {
+	for k := range cache {
+		delete(cache, k)
+		return
+	}
+}
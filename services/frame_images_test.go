@@ -0,0 +1,105 @@
+package services
+
+import (
+	"image"
+	"image/color"
+	"testing"
+
+	"tuxedo-core/models"
+)
+
+// testPalette colors the pixels of test images by letter; '.' is
+// transparent
+var testPalette = map[byte]color.NRGBA{
+	'.': {},
+	'a': {R: 255, A: 255}, 'b': {G: 255, A: 255}, 'c': {B: 255, A: 255},
+	'd': {R: 255, G: 255, A: 255}, 'e': {G: 255, B: 255, A: 255}, 'f': {R: 255, B: 255, A: 255},
+}
+
+// letterImage draws rows of letters into an image whose top left corner
+// is at min
+func letterImage(min image.Point, rows ...string) *image.NRGBA {
+	img := image.NewNRGBA(image.Rect(0, 0, len(rows[0]), len(rows)).Add(min))
+	for y, row := range rows {
+		for x := range len(row) {
+			img.SetNRGBA(min.X+x, min.Y+y, testPalette[row[x]])
+		}
+	}
+	return img
+}
+
+func TestCropFrame(t *testing.T) {
+	// The sheet holds the 3x2 sprite "abc/def" upright at (0, 0), and
+	// turned clockwise at (3, 0)
+	sheet := []string{
+		"abcda",
+		"defeb",
+		"...fc",
+	}
+	trimmed := models.FrameRect{X: 1, Y: 1, W: 3, H: 2}
+	padded := []string{
+		".....",
+		".abc.",
+		".def.",
+		".....",
+	}
+
+	tests := []struct {
+		name  string
+		sheet image.Image
+		frame models.AtlasFrame
+		want  []string
+	}{
+		{
+			name:  "untrimmed",
+			sheet: letterImage(image.Point{}, sheet...),
+			frame: models.AtlasFrame{Frame: models.FrameRect{W: 3, H: 2}, SpriteSourceSize: models.FrameRect{W: 3, H: 2}, SourceSize: models.FrameSize{W: 3, H: 2}},
+			want:  []string{"abc", "def"},
+		},
+		{
+			name:  "trimmed",
+			sheet: letterImage(image.Point{}, sheet...),
+			frame: models.AtlasFrame{Frame: models.FrameRect{W: 3, H: 2}, Trimmed: true, SpriteSourceSize: trimmed, SourceSize: models.FrameSize{W: 5, H: 4}},
+			want:  padded,
+		},
+		{
+			name:  "rotated and trimmed",
+			sheet: letterImage(image.Point{}, sheet...),
+			frame: models.AtlasFrame{Frame: models.FrameRect{X: 3, W: 3, H: 2}, Rotated: true, Trimmed: true, SpriteSourceSize: trimmed, SourceSize: models.FrameSize{W: 5, H: 4}},
+			want:  padded,
+		},
+		{
+			name:  "rotated on a sheet not at the origin",
+			sheet: letterImage(image.Pt(10, 20), sheet...),
+			frame: models.AtlasFrame{Frame: models.FrameRect{X: 3, W: 3, H: 2}, Rotated: true, Trimmed: true, SpriteSourceSize: trimmed, SourceSize: models.FrameSize{W: 5, H: 4}},
+			want:  padded,
+		},
+		{
+			name:  "rotated past the edge of the sheet",
+			sheet: letterImage(image.Point{}, sheet...),
+			frame: models.AtlasFrame{Frame: models.FrameRect{X: 3, Y: 1, W: 3, H: 2}, Rotated: true, Trimmed: true, SpriteSourceSize: trimmed, SourceSize: models.FrameSize{W: 5, H: 4}},
+			want: []string{
+				".....",
+				".bc..",
+				".ef..",
+				".....",
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := cropFrame(tt.sheet, &TextureFrame{AtlasFrame: tt.frame})
+			want := letterImage(image.Point{}, tt.want...)
+			if got.Bounds() != want.Bounds() {
+				t.Fatalf("bounds = %v, want %v", got.Bounds(), want.Bounds())
+			}
+			for y := range want.Bounds().Dy() {
+				for x := range want.Bounds().Dx() {
+					if c := color.NRGBAModel.Convert(got.At(x, y)); c != want.At(x, y) {
+						t.Errorf("pixel (%d, %d) = %v, want %v (%q)", x, y, c, want.At(x, y), tt.want[y][x])
+					}
+				}
+			}
+		})
+	}
+}
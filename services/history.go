@@ -0,0 +1,177 @@
+package services
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+const revisionExt = ".rev"
+
+// revisionTimeFormat sorts lexically in time order
+const revisionTimeFormat = "20060102T150405.000000000Z"
+
+// ErrRevisionNotFound is returned for unknown revision IDs
+var ErrRevisionNotFound = errors.New("revision not found")
+
+// HistoryStore keeps previous versions of scene files in a hidden folder,
+// one subfolder per scene, pruned by count and age
+type HistoryStore struct {
+	root         string
+	maxRevisions int
+	maxAge       time.Duration
+}
+
+// Revision describes one stored version of a scene
+type Revision struct {
+	ID   string    `json:"id"`
+	Time time.Time `json:"time"`
+	Size int64     `json:"size"`
+}
+
+// NewHistoryStore creates a store under root. A maxRevisions or maxAge of
+// zero disables that limit.
+func NewHistoryStore(root string, maxRevisions int, maxAge time.Duration) *HistoryStore {
+	return &HistoryStore{root: root, maxRevisions: maxRevisions, maxAge: maxAge}
+}
+
+// Save stores data as the newest revision of the scene. Saving the same
+// contents as the newest revision is a no-op.
+func (h *HistoryStore) Save(name string, data []byte) (*Revision, error) {
+	dir := h.sceneDir(name)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, err
+	}
+
+	revisions, err := h.List(name)
+	if err != nil {
+		return nil, err
+	}
+	if len(revisions) > 0 {
+		if latest, err := h.Read(name, revisions[0].ID); err == nil && bytes.Equal(latest, data) {
+			return &revisions[0], nil
+		}
+	}
+
+	now := time.Now().UTC()
+	id := now.Format(revisionTimeFormat)
+	for i := 0; fileExists(filepath.Join(dir, id+revisionExt)); i++ {
+		now = now.Add(time.Nanosecond)
+		id = now.Format(revisionTimeFormat)
+	}
+
+	if err := WriteFileAtomic(filepath.Join(dir, id+revisionExt), data, 0644); err != nil {
+		return nil, err
+	}
+
+	h.prune(name)
+
+	return &Revision{ID: id, Time: now, Size: int64(len(data))}, nil
+}
+
+// List returns the revisions of a scene, newest first
+func (h *HistoryStore) List(name string) ([]Revision, error) {
+	entries, err := os.ReadDir(h.sceneDir(name))
+	if os.IsNotExist(err) {
+		return []Revision{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	revisions := []Revision{}
+	for _, entry := range entries {
+		id, ok := strings.CutSuffix(entry.Name(), revisionExt)
+		if !ok || entry.IsDir() {
+			continue
+		}
+		t, err := time.Parse(revisionTimeFormat, id)
+		if err != nil {
+			continue
+		}
+		info, err := entry.Info()
+		if err != nil {
+			continue
+		}
+		revisions = append(revisions, Revision{ID: id, Time: t, Size: info.Size()})
+	}
+
+	sort.Slice(revisions, func(i, j int) bool { return revisions[i].ID > revisions[j].ID })
+	return revisions, nil
+}
+
+// Read returns the contents of one revision
+func (h *HistoryStore) Read(name, id string) ([]byte, error) {
+	if _, err := time.Parse(revisionTimeFormat, id); err != nil {
+		return nil, ErrRevisionNotFound
+	}
+	data, err := os.ReadFile(filepath.Join(h.sceneDir(name), id+revisionExt))
+	if os.IsNotExist(err) {
+		return nil, ErrRevisionNotFound
+	}
+	return data, err
+}
+
+// Move carries the history of a scene over to its new name. Only the
+// scene's revision files move: its folder may also hold the histories of
+// scenes in a folder of the same name, such as rooms/Town/Igloo next to
+// rooms/Town.
+func (h *HistoryStore) Move(from, to string) error {
+	revisions, err := h.List(from)
+	if err != nil || len(revisions) == 0 {
+		return err
+	}
+	existing, err := h.List(to)
+	if err != nil {
+		return err
+	}
+	if len(existing) > 0 {
+		return os.ErrExist
+	}
+
+	fromDir, toDir := h.sceneDir(from), h.sceneDir(to)
+	if err := os.MkdirAll(toDir, 0755); err != nil {
+		return err
+	}
+	for _, rev := range revisions {
+		if err := os.Rename(filepath.Join(fromDir, rev.ID+revisionExt), filepath.Join(toDir, rev.ID+revisionExt)); err != nil {
+			return err
+		}
+	}
+	// Left in place while it holds other scenes' histories
+	os.Remove(fromDir)
+	return nil
+}
+
+// prune removes revisions beyond the retention limits, always keeping the newest
+func (h *HistoryStore) prune(name string) {
+	revisions, err := h.List(name)
+	if err != nil {
+		return
+	}
+
+	cutoff := time.Now().Add(-h.maxAge)
+	for i, rev := range revisions {
+		if i == 0 {
+			continue
+		}
+		tooMany := h.maxRevisions > 0 && i >= h.maxRevisions
+		tooOld := h.maxAge > 0 && rev.Time.Before(cutoff)
+		if tooMany || tooOld {
+			os.Remove(filepath.Join(h.sceneDir(name), rev.ID+revisionExt))
+		}
+	}
+}
+
+func (h *HistoryStore) sceneDir(name string) string {
+	return filepath.Join(h.root, filepath.FromSlash(name))
+}
+
+func fileExists(path string) bool {
+	_, err := os.Stat(path)
+	return err == nil
+}
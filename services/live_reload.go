@@ -0,0 +1,273 @@
+package services
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+	"github.com/gorilla/websocket"
+)
+
+// Live reload message types sent to connected editors
+const (
+	MessageSceneCreated = "scene-created"
+	MessageSceneChanged = "scene-changed"
+	MessageSceneDeleted = "scene-deleted"
+	MessageAssetChanged = "asset-changed"
+)
+
+const (
+	clientSendBuffer = 64
+	writeWait        = 10 * time.Second
+	pongWait         = 60 * time.Second
+	pingPeriod       = (pongWait * 9) / 10
+)
+
+// LiveReloadMessage is the JSON payload pushed over the websocket
+type LiveReloadMessage struct {
+	Type  string `json:"type"`
+	Scene string `json:"scene,omitempty"` // Scene name without extension, e.g. rooms/town/Town
+	Path  string `json:"path,omitempty"`  // Asset web path, e.g. /assets/media/rooms/town/town.png
+}
+
+// LiveReloadHub follows the file system events of the project index and
+// broadcasts change notifications to every connected websocket client
+type LiveReloadHub struct {
+	index      *ProjectIndex
+	scenesPath string
+	assetsPath string
+
+	mu      sync.Mutex
+	clients map[*liveReloadClient]bool
+	scenes  map[string]bool // scene names known to exist, used to tell creates from updates
+
+	unsubscribes []func()
+}
+
+type liveReloadClient struct {
+	conn *websocket.Conn
+	send chan LiveReloadMessage
+}
+
+func NewLiveReloadHub(index *ProjectIndex) *LiveReloadHub {
+	return &LiveReloadHub{
+		index:      index,
+		scenesPath: index.Root(SceneTree),
+		assetsPath: index.Root(AssetTree),
+		clients:    make(map[*liveReloadClient]bool),
+		scenes:     make(map[string]bool),
+	}
+}
+
+// Start subscribes to the scene and asset events of the index, which must
+// already be started
+func (h *LiveReloadHub) Start() {
+	h.mu.Lock()
+	for _, name := range h.index.SceneNames() {
+		h.scenes[name] = true
+	}
+	h.mu.Unlock()
+
+	h.unsubscribes = append(h.unsubscribes,
+		h.index.Subscribe(SceneTree, h.handleSceneEvents),
+		h.index.Subscribe(AssetTree, h.handleAssetEvents),
+	)
+}
+
+// Close stops following the index and disconnects all clients
+func (h *LiveReloadHub) Close() {
+	for _, unsubscribe := range h.unsubscribes {
+		unsubscribe()
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	for c := range h.clients {
+		close(c.send)
+		delete(h.clients, c)
+	}
+}
+
+func (h *LiveReloadHub) handleSceneEvents(events []fsnotify.Event) {
+	for _, event := range events {
+		if isIgnoredName(filepath.Base(event.Name)) {
+			continue
+		}
+		if filepath.Ext(event.Name) != ".scene" {
+			h.handleSceneFolderEvent(event.Name)
+			continue
+		}
+		h.updateScene(event.Name)
+	}
+}
+
+// handleSceneFolderEvent reports the scenes inside a folder that was moved
+// into or out of the tree, since those produce no per-file events.
+func (h *LiveReloadHub) handleSceneFolderEvent(dir string) {
+	info, err := os.Stat(dir)
+	if err == nil && info.IsDir() {
+		filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+			if err == nil && !info.IsDir() && filepath.Ext(path) == ".scene" {
+				h.updateScene(path)
+			}
+			return nil
+		})
+		return
+	}
+	if err == nil {
+		return
+	}
+
+	prefix, ok := h.sceneName(dir)
+	if !ok {
+		return
+	}
+	h.mu.Lock()
+	var removed []string
+	for name := range h.scenes {
+		if strings.HasPrefix(name, prefix+"/") {
+			removed = append(removed, name)
+		}
+	}
+	h.mu.Unlock()
+
+	for _, name := range removed {
+		h.updateScene(filepath.Join(h.scenesPath, filepath.FromSlash(name)+".scene"))
+	}
+}
+
+// updateScene compares the file on disk with the known scene set and
+// broadcasts the matching created, changed or deleted message
+func (h *LiveReloadHub) updateScene(path string) {
+	name, ok := h.sceneName(path)
+	if !ok {
+		return
+	}
+
+	_, statErr := os.Stat(path)
+	exists := statErr == nil
+
+	h.mu.Lock()
+	known := h.scenes[name]
+	if exists {
+		h.scenes[name] = true
+	} else {
+		delete(h.scenes, name)
+	}
+	h.mu.Unlock()
+
+	switch {
+	case !exists && known:
+		h.Broadcast(LiveReloadMessage{Type: MessageSceneDeleted, Scene: name})
+	case exists && !known:
+		h.Broadcast(LiveReloadMessage{Type: MessageSceneCreated, Scene: name})
+	case exists:
+		h.Broadcast(LiveReloadMessage{Type: MessageSceneChanged, Scene: name})
+	}
+}
+
+func (h *LiveReloadHub) handleAssetEvents(events []fsnotify.Event) {
+	for _, event := range events {
+		if isIgnoredName(filepath.Base(event.Name)) {
+			continue
+		}
+		if info, err := os.Stat(event.Name); err == nil && info.IsDir() {
+			continue
+		}
+		relPath, err := filepath.Rel(h.assetsPath, event.Name)
+		if err != nil || strings.HasPrefix(relPath, "..") {
+			continue
+		}
+		h.Broadcast(LiveReloadMessage{Type: MessageAssetChanged, Path: "/assets/" + filepath.ToSlash(relPath)})
+	}
+}
+
+// sceneName converts an absolute .scene path to the name used by the scenes API
+func (h *LiveReloadHub) sceneName(path string) (string, bool) {
+	relPath, err := filepath.Rel(h.scenesPath, path)
+	if err != nil || strings.HasPrefix(relPath, "..") {
+		return "", false
+	}
+	return filepath.ToSlash(strings.TrimSuffix(relPath, ".scene")), true
+}
+
+// Broadcast queues msg for every client. Clients whose buffer is full are
+// dropped rather than allowed to stall the hub.
+func (h *LiveReloadHub) Broadcast(msg LiveReloadMessage) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	for c := range h.clients {
+		select {
+		case c.send <- msg:
+		default:
+			close(c.send)
+			delete(h.clients, c)
+		}
+	}
+}
+
+// Serve registers conn with the hub and blocks until the client disconnects
+func (h *LiveReloadHub) Serve(conn *websocket.Conn) {
+	client := &liveReloadClient{conn: conn, send: make(chan LiveReloadMessage, clientSendBuffer)}
+
+	h.mu.Lock()
+	h.clients[client] = true
+	h.mu.Unlock()
+
+	go client.writePump()
+	client.readPump()
+
+	h.mu.Lock()
+	if h.clients[client] {
+		close(client.send)
+		delete(h.clients, client)
+	}
+	h.mu.Unlock()
+}
+
+// readPump discards incoming messages and keeps the read deadline alive
+func (c *liveReloadClient) readPump() {
+	defer c.conn.Close()
+
+	c.conn.SetReadDeadline(time.Now().Add(pongWait))
+	c.conn.SetPongHandler(func(string) error {
+		return c.conn.SetReadDeadline(time.Now().Add(pongWait))
+	})
+
+	for {
+		if _, _, err := c.conn.ReadMessage(); err != nil {
+			return
+		}
+	}
+}
+
+func (c *liveReloadClient) writePump() {
+	ticker := time.NewTicker(pingPeriod)
+	defer func() {
+		ticker.Stop()
+		c.conn.Close()
+	}()
+
+	for {
+		select {
+		case msg, ok := <-c.send:
+			c.conn.SetWriteDeadline(time.Now().Add(writeWait))
+			if !ok {
+				c.conn.WriteMessage(websocket.CloseMessage, []byte{})
+				return
+			}
+			if err := c.conn.WriteJSON(msg); err != nil {
+				return
+			}
+		case <-ticker.C:
+			c.conn.SetWriteDeadline(time.Now().Add(writeWait))
+			if err := c.conn.WriteMessage(websocket.PingMessage, nil); err != nil {
+				return
+			}
+		}
+	}
+}
@@ -0,0 +1,84 @@
+package services
+
+import (
+	"bytes"
+	"os"
+	"path"
+	"path/filepath"
+	"time"
+
+	"tuxedo-core/models"
+)
+
+// PackFile is a pack file of the project
+type PackFile struct {
+	Path string // Slash separated, relative to the assets directory
+	Pack *models.Pack
+}
+
+type cachedPack struct {
+	size    int64
+	modTime time.Time
+	pack    *models.Pack
+}
+
+// Packs returns every asset pack under the assets directory, sorted by
+// path. JSON files are parsed once and again only after they change; the
+// packs are shared, so callers must not modify them.
+func (idx *ProjectIndex) Packs() []PackFile {
+	assets := idx.Assets()
+
+	idx.packsMu.Lock()
+	defer idx.packsMu.Unlock()
+
+	if idx.packs == nil {
+		idx.packs = map[string]*cachedPack{}
+	}
+	seen := map[string]bool{}
+	var packs []PackFile
+	for _, asset := range assets {
+		if path.Ext(asset.Path) != ".json" {
+			continue
+		}
+		seen[asset.Path] = true
+
+		cached := idx.packs[asset.Path]
+		if cached == nil || cached.size != asset.Size || !cached.modTime.Equal(asset.ModTime) {
+			cached = &cachedPack{size: asset.Size, modTime: asset.ModTime, pack: idx.readPack(asset.Path)}
+			idx.packs[asset.Path] = cached
+		}
+		if cached.pack != nil {
+			packs = append(packs, PackFile{Path: asset.Path, Pack: cached.pack})
+		}
+	}
+	for rel := range idx.packs {
+		if !seen[rel] {
+			delete(idx.packs, rel)
+		}
+	}
+	return packs
+}
+
+// Pack returns the pack at the asset path, if it is one
+func (idx *ProjectIndex) Pack(rel string) (*models.Pack, bool) {
+	for _, pack := range idx.Packs() {
+		if pack.Path == rel {
+			return pack.Pack, true
+		}
+	}
+	return nil, false
+}
+
+// readPack parses the asset at rel, returning nil when it is not a pack
+func (idx *ProjectIndex) readPack(rel string) *models.Pack {
+	data, err := os.ReadFile(filepath.Join(idx.assetsPath, filepath.FromSlash(rel)))
+	// Atlases can be large; only files with a files list can be packs
+	if err != nil || !bytes.Contains(data, []byte(`"files"`)) {
+		return nil
+	}
+	pack, err := models.ParsePack(data)
+	if err != nil {
+		return nil
+	}
+	return pack
+}
@@ -0,0 +1,319 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"slices"
+	"strings"
+
+	"tuxedo-core/models"
+)
+
+// Errors wrapped when a prefab cannot be resolved
+var (
+	ErrPrefabCycle    = errors.New("prefab cycle") // The prefab contains or inherits from itself
+	ErrPrefabNotFound = errors.New("not found")    // No PREFAB scene has the ID
+)
+
+// ObjectOrigin tags an object of an expanded scene with where it is defined
+type ObjectOrigin struct {
+	Scene    string `json:"scene"`              // Scene or prefab file the object is defined in
+	ObjectID string `json:"objectId"`           // ID of the object in that file
+	PrefabID string `json:"prefabId,omitempty"` // Prefab the object is an instance of
+	Error    string `json:"error,omitempty"`    // Why an instance could not be expanded
+}
+
+// originProperty is the member expanded objects carry their origin in
+const originProperty = "origin"
+
+// FindPrefab returns the name and content of the PREFAB scene with the ID
+func (s *SceneService) FindPrefab(id string) (string, *models.Scene, error) {
+	if s.index != nil {
+		name, ok := s.index.PrefabScene(id)
+		if !ok {
+			return "", nil, os.ErrNotExist
+		}
+		scene, err := s.LoadScene(name)
+		return name, scene, err
+	}
+
+	names, err := s.SceneNames()
+	if err != nil {
+		return "", nil, err
+	}
+	for _, name := range names {
+		scene, err := s.LoadScene(name)
+		if err == nil && scene.SceneType == "PREFAB" && scene.ID == id {
+			return name, scene, nil
+		}
+	}
+	return "", nil, os.ErrNotExist
+}
+
+// Prefabs returns the name of every PREFAB scene by prefab ID, from the
+// index when one is used
+func (s *SceneService) Prefabs() (map[string]string, error) {
+	if s.index != nil {
+		return s.index.Prefabs(), nil
+	}
+
+	names, err := s.SceneNames()
+	if err != nil {
+		return nil, err
+	}
+	prefabs := map[string]string{}
+	for _, name := range names {
+		scene, err := s.LoadScene(name)
+		if err == nil && scene.SceneType == "PREFAB" {
+			prefabs[scene.ID] = name
+		}
+	}
+	return prefabs, nil
+}
+
+// ExpandPrefabs replaces every prefab instance in the scene with the
+// prefab's objects, resolved the way the compiler resolves them: only the
+// instance's unlocked properties and added components override the prefab.
+// Nested prefabs and prefab variants are expanded too. Objects that come
+// from a prefab get IDs prefixed with the instance ID ("inst/child") so
+// they stay unique, and every object is tagged with its ObjectOrigin.
+// Instances that cannot be expanded, because the prefab is missing or
+// contains itself, are left as they are with the reason in their origin.
+func (s *SceneService) ExpandPrefabs(name string, scene *models.Scene) error {
+	return s.NewPrefabResolver().Expand(name, scene)
+}
+
+// PrefabResolver resolves prefabs with the semantics of ExpandPrefabs and
+// keeps each prefab it resolved, so callers looking up many prefabs, such
+// as the compiler, read every file once. It does not see changes made
+// after a prefab was resolved.
+type PrefabResolver struct {
+	scenes   *SceneService
+	resolved map[string]*ResolvedPrefab
+}
+
+// ResolvedPrefab is a PREFAB scene with its root object expanded. The root
+// of a prefab variant has the type and properties it inherits from the
+// prefab it is based on.
+type ResolvedPrefab struct {
+	Name  string
+	Scene *models.Scene
+	Root  *models.GameObject
+}
+
+// NewPrefabResolver returns a resolver reading prefabs through the service
+func (s *SceneService) NewPrefabResolver() *PrefabResolver {
+	return &PrefabResolver{scenes: s, resolved: map[string]*ResolvedPrefab{}}
+}
+
+// Resolve returns the prefab with the ID. It fails with ErrPrefabNotFound
+// when no prefab has the ID, and with ErrPrefabCycle when the prefab
+// inherits from itself or its root contains itself.
+func (r *PrefabResolver) Resolve(id string) (*ResolvedPrefab, error) {
+	return r.resolvePrefab(id, nil)
+}
+
+// Expand expands the prefab instances of a scene as ExpandPrefabs does
+func (r *PrefabResolver) Expand(name string, scene *models.Scene) error {
+	expanded, err := r.expandObjects(scene.DisplayList, name, nil)
+	if err != nil {
+		return err
+	}
+	scene.DisplayList = expanded
+	return nil
+}
+
+// expandObjects expands the objects defined in the scene or prefab file
+// source. stack holds the IDs of the prefabs being expanded, outermost first.
+func (r *PrefabResolver) expandObjects(objects []models.GameObject, source string, stack []string) ([]models.GameObject, error) {
+	expanded := make([]models.GameObject, 0, len(objects))
+	for i := range objects {
+		obj, err := r.expandObject(&objects[i], source, stack)
+		if err != nil {
+			return nil, err
+		}
+		expanded = append(expanded, obj)
+	}
+	return expanded, nil
+}
+
+func (r *PrefabResolver) expandObject(obj *models.GameObject, source string, stack []string) (models.GameObject, error) {
+	origin := ObjectOrigin{Scene: source, ObjectID: obj.ID, PrefabID: obj.PrefabId}
+
+	if obj.PrefabId == "" {
+		result := *obj
+		result.Properties = obj.Properties.Clone()
+		children, err := r.expandObjects(obj.List, source, stack)
+		if err != nil {
+			return models.GameObject{}, err
+		}
+		result.List = children
+		return result, tagOrigin(&result, origin)
+	}
+
+	prefab, err := r.resolvePrefab(obj.PrefabId, stack)
+	// A cycle below a prefab makes that prefab unresolvable as well; the
+	// instance in the file being expanded carries the reason
+	if errors.Is(err, ErrPrefabCycle) && len(stack) > 0 {
+		return models.GameObject{}, err
+	}
+	if err != nil {
+		result := *obj
+		result.Properties = obj.Properties.Clone()
+		origin.Error = err.Error()
+		return result, tagOrigin(&result, origin)
+	}
+
+	// Children added on the instance are defined in source, not the prefab
+	ownChildren, err := r.expandObjects(obj.List, source, stack)
+	if err != nil {
+		return models.GameObject{}, err
+	}
+	instance := *obj
+	instance.List = nil
+
+	result, err := models.ApplyInstance(prefab.Root, &instance)
+	if err != nil {
+		return models.GameObject{}, err
+	}
+	for i := range result.List {
+		prefixIDs(&result.List[i], obj.ID+"/")
+	}
+	result.List = append(result.List, ownChildren...)
+	return result, tagOrigin(&result, origin)
+}
+
+// resolvePrefab returns the prefab with its root object fully expanded
+func (r *PrefabResolver) resolvePrefab(id string, stack []string) (*ResolvedPrefab, error) {
+	for i, outer := range stack {
+		if outer == id {
+			chain := append(append([]string(nil), stack[i:]...), id)
+			return nil, fmt.Errorf("%w: %s", ErrPrefabCycle, strings.Join(chain, " -> "))
+		}
+	}
+	if resolved, ok := r.resolved[id]; ok {
+		return resolved, nil
+	}
+
+	name, prefab, err := r.scenes.FindPrefab(id)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, fmt.Errorf("prefab %s %w", id, ErrPrefabNotFound)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if len(prefab.DisplayList) == 0 {
+		return nil, fmt.Errorf("prefab %s has no root object", name)
+	}
+
+	root, err := r.expandObject(&prefab.DisplayList[0], name, append(stack, id))
+	if err != nil {
+		return nil, err
+	}
+	resolved := &ResolvedPrefab{Name: name, Scene: prefab, Root: &root}
+	r.resolved[id] = resolved
+	return resolved, nil
+}
+
+// prefixIDs renames obj and its children so copies brought in by different
+// instances do not collide
+func prefixIDs(obj *models.GameObject, prefix string) {
+	obj.ID = prefix + obj.ID
+	for i := range obj.List {
+		prefixIDs(&obj.List[i], prefix)
+	}
+}
+
+func tagOrigin(obj *models.GameObject, origin ObjectOrigin) error {
+	return obj.Properties.Set(originProperty, origin)
+}
+
+// PrefabUsage lists the instances of a prefab in one scene or prefab
+type PrefabUsage struct {
+	Scene     string           `json:"scene"`
+	SceneType string           `json:"sceneType"`
+	PrefabID  string           `json:"prefabId,omitempty"` // ID of the scene, if it is a prefab itself
+	Instances []PrefabInstance `json:"instances"`
+}
+
+// PrefabInstance is an object that brings in a prefab, either directly or
+// by instantiating a prefab that contains it
+type PrefabInstance struct {
+	ObjectID string   `json:"objectId"`
+	Label    string   `json:"label"`
+	PrefabID string   `json:"prefabId"` // Prefab the object is an instance of
+	Via      []string `json:"via"`      // Prefabs between the instance and the prefab looked up, outermost first; empty for direct instances
+	Unlock   []string `json:"unlock"`   // Properties the instance overrides
+}
+
+// PrefabUsages returns every scene and prefab that instantiates the prefab,
+// directly or through nested prefabs and variants, sorted by scene name.
+// Scenes that cannot be parsed are skipped.
+func (s *SceneService) PrefabUsages(id string) ([]PrefabUsage, error) {
+	// via maps each prefab that contains id to the chain leading to it
+	via := map[string][]string{id: {}}
+	scenes := map[string]*models.Scene{}
+	queue := []string{id}
+
+	for len(queue) > 0 {
+		prefab := queue[0]
+		queue = queue[1:]
+
+		users, err := s.ScenesUsingPrefab(prefab)
+		if err != nil {
+			return nil, err
+		}
+		for _, name := range users {
+			scene, ok := scenes[name]
+			if !ok {
+				scene, err = s.LoadScene(name)
+				if err != nil {
+					continue
+				}
+				scenes[name] = scene
+			}
+			if scene.SceneType != "PREFAB" || scene.ID == "" {
+				continue
+			}
+			if _, seen := via[scene.ID]; !seen {
+				via[scene.ID] = append([]string{scene.ID}, via[prefab]...)
+				queue = append(queue, scene.ID)
+			}
+		}
+	}
+
+	names := make([]string, 0, len(scenes))
+	for name := range scenes {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+
+	usages := []PrefabUsage{}
+	for _, name := range names {
+		scene := scenes[name]
+		usage := PrefabUsage{Scene: name, SceneType: scene.SceneType, Instances: []PrefabInstance{}}
+		if scene.SceneType == "PREFAB" {
+			usage.PrefabID = scene.ID
+		}
+		scene.WalkObjects(func(obj *models.GameObject) {
+			chain, ok := via[obj.PrefabId]
+			if obj.PrefabId == "" || !ok {
+				return
+			}
+			unlock := obj.Unlock
+			if unlock == nil {
+				unlock = []string{}
+			}
+			usage.Instances = append(usage.Instances, PrefabInstance{
+				ObjectID: obj.ID,
+				Label:    obj.Label,
+				PrefabID: obj.PrefabId,
+				Via:      chain,
+				Unlock:   unlock,
+			})
+		})
+		usages = append(usages, usage)
+	}
+	return usages, nil
+}
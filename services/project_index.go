@@ -0,0 +1,656 @@
+package services
+
+import (
+	"encoding/json"
+	"log"
+	"maps"
+	"os"
+	"path"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+
+	"tuxedo-core/models"
+)
+
+// indexCacheVersion changes whenever the cached entry format does, so an
+// old cache is rebuilt instead of misread
+const indexCacheVersion = 2
+
+const (
+	indexDebounce  = 150 * time.Millisecond
+	indexSaveDelay = 2 * time.Second
+)
+
+// SceneEntry is the indexed metadata of a .scene file
+type SceneEntry struct {
+	Name         string     `json:"name"` // e.g. rooms/town/Town
+	ID           string     `json:"id"`
+	SceneType    string     `json:"sceneType"`
+	SceneKey     string     `json:"sceneKey"`
+	ObjectCount  int        `json:"objectCount"`
+	PreloadPacks []string   `json:"preloadPacks"`
+	Prefabs      []string   `json:"prefabs,omitempty"` // IDs of the prefabs instantiated in the scene
+	Root         *RootEntry `json:"root,omitempty"`    // Root object of a PREFAB scene
+	Size         int64      `json:"size"`
+	ModTime      time.Time  `json:"modTime"`
+	Error        string     `json:"error,omitempty"` // Set when the file could not be parsed
+}
+
+// Folder is the folder of the scene relative to the scenes directory,
+// empty for scenes at the top level
+func (e *SceneEntry) Folder() string {
+	if dir := path.Dir(e.Name); dir != "." {
+		return dir
+	}
+	return ""
+}
+
+// RootEntry is the indexed root object of a prefab
+type RootEntry struct {
+	ID         string          `json:"id"`
+	Label      string          `json:"label"`
+	Type       string          `json:"type,omitempty"`     // Empty for prefab variants
+	PrefabID   string          `json:"prefabId,omitempty"` // Prefab a variant is based on
+	Texture    *models.Texture `json:"texture,omitempty"`
+	Components []string        `json:"components,omitempty"`
+}
+
+// AssetEntry is an indexed file under the assets directory
+type AssetEntry struct {
+	Path    string    `json:"path"` // Slash separated, relative to the assets directory
+	Size    int64     `json:"size"`
+	ModTime time.Time `json:"modTime"`
+}
+
+// ProjectIndex keeps scene metadata, prefab IDs and asset files in memory
+// so requests do not walk and parse the project tree. It is built once,
+// reusing a cache file for unchanged scenes, then kept current from file
+// system events and from SceneService writes.
+type ProjectIndex struct {
+	scenesPath string
+	assetsPath string
+	cachePath  string // Empty to disable the cache file
+
+	mu           sync.RWMutex
+	scenes       map[string]*SceneEntry
+	sceneFolders map[string]bool
+	prefabs      map[string]string // prefab ID -> scene name
+	assets       map[string]*AssetEntry
+	assetNames   map[string][]string // file name -> asset paths
+
+	packsMu sync.Mutex
+	packs   map[string]*cachedPack // asset path -> parsed pack, nil for other JSON files
+
+	saveMu    sync.Mutex
+	saveTimer *time.Timer
+	watchers  []*FileWatcher
+
+	listenersMu sync.Mutex
+	listeners   []*indexListener
+}
+
+// Trees of the project the index watches
+const (
+	SceneTree = "scenes"
+	AssetTree = "assets"
+)
+
+type indexListener struct {
+	tree string
+	fn   func(events []fsnotify.Event)
+}
+
+type indexCache struct {
+	Version int           `json:"version"`
+	Scenes  []*SceneEntry `json:"scenes"`
+}
+
+func NewProjectIndex(scenesPath, assetsPath, cachePath string) *ProjectIndex {
+	return &ProjectIndex{
+		scenesPath:   scenesPath,
+		assetsPath:   assetsPath,
+		cachePath:    cachePath,
+		scenes:       map[string]*SceneEntry{},
+		sceneFolders: map[string]bool{},
+		prefabs:      map[string]string{},
+		assets:       map[string]*AssetEntry{},
+		assetNames:   map[string][]string{},
+	}
+}
+
+// Build scans the project. Scenes whose size and modification time match
+// the cache file are not parsed again.
+func (idx *ProjectIndex) Build() error {
+	cached := idx.loadCache()
+
+	scenes := map[string]*SceneEntry{}
+	folders := map[string]bool{}
+	err := walkTree(idx.scenesPath, func(p string, info os.FileInfo) {
+		rel, _ := filepath.Rel(idx.scenesPath, p)
+		rel = filepath.ToSlash(rel)
+		if info.IsDir() {
+			folders[rel] = true
+			return
+		}
+		if filepath.Ext(p) != ".scene" {
+			return
+		}
+		name := strings.TrimSuffix(rel, ".scene")
+		if entry := cached[name]; entry != nil && entry.Size == info.Size() && entry.ModTime.Equal(info.ModTime()) {
+			scenes[name] = entry
+			return
+		}
+		scenes[name] = readSceneEntry(name, p, info)
+	})
+	if err != nil {
+		return err
+	}
+
+	assets := map[string]*AssetEntry{}
+	walkTree(idx.assetsPath, func(p string, info os.FileInfo) {
+		if !info.IsDir() {
+			rel, _ := filepath.Rel(idx.assetsPath, p)
+			assets[filepath.ToSlash(rel)] = &AssetEntry{Path: filepath.ToSlash(rel), Size: info.Size(), ModTime: info.ModTime()}
+		}
+	})
+
+	idx.mu.Lock()
+	idx.scenes = scenes
+	idx.sceneFolders = folders
+	idx.assets = assets
+	idx.rebuildLookups()
+	idx.mu.Unlock()
+
+	idx.saveCache()
+	return nil
+}
+
+// Start builds the index and keeps it current by watching the scenes and
+// assets trees. Subscribers get the events of both once the index has
+// handled them, so the project is watched only once.
+func (idx *ProjectIndex) Start() error {
+	if err := idx.Build(); err != nil {
+		return err
+	}
+
+	sceneWatcher, err := NewFileWatcher(idx.scenesPath)
+	if err != nil {
+		return err
+	}
+	sceneWatcher.WatchDebounced(indexDebounce, func(events []fsnotify.Event) {
+		idx.mu.Lock()
+		for _, event := range events {
+			idx.refreshScenePath(event.Name)
+		}
+		idx.rebuildPrefabs()
+		idx.mu.Unlock()
+		idx.scheduleSave()
+		idx.notify(SceneTree, events)
+	})
+	idx.watchers = append(idx.watchers, sceneWatcher)
+
+	assetWatcher, err := NewFileWatcher(idx.assetsPath)
+	if err != nil {
+		log.Printf("Project index: not watching assets: %v", err)
+		return nil
+	}
+	assetWatcher.WatchDebounced(indexDebounce, func(events []fsnotify.Event) {
+		idx.mu.Lock()
+		for _, event := range events {
+			idx.refreshAssetPath(event.Name)
+		}
+		idx.rebuildAssetNames()
+		idx.mu.Unlock()
+		idx.notify(AssetTree, events)
+	})
+	idx.watchers = append(idx.watchers, assetWatcher)
+
+	return nil
+}
+
+// Subscribe calls fn with each debounced batch of file system events of
+// tree, SceneTree or AssetTree, after the index is updated from them. The
+// returned function cancels the subscription.
+func (idx *ProjectIndex) Subscribe(tree string, fn func(events []fsnotify.Event)) func() {
+	listener := &indexListener{tree: tree, fn: fn}
+	idx.listenersMu.Lock()
+	idx.listeners = append(idx.listeners, listener)
+	idx.listenersMu.Unlock()
+
+	return func() {
+		idx.listenersMu.Lock()
+		idx.listeners = slices.DeleteFunc(idx.listeners, func(l *indexListener) bool { return l == listener })
+		idx.listenersMu.Unlock()
+	}
+}
+
+func (idx *ProjectIndex) notify(tree string, events []fsnotify.Event) {
+	idx.listenersMu.Lock()
+	listeners := slices.Clone(idx.listeners)
+	idx.listenersMu.Unlock()
+
+	for _, listener := range listeners {
+		if listener.tree == tree {
+			listener.fn(events)
+		}
+	}
+}
+
+// Root returns the folder of a tree, SceneTree or AssetTree
+func (idx *ProjectIndex) Root(tree string) string {
+	if tree == AssetTree {
+		return idx.assetsPath
+	}
+	return idx.scenesPath
+}
+
+// Close stops watching and writes any pending cache update
+func (idx *ProjectIndex) Close() {
+	for _, w := range idx.watchers {
+		w.Close()
+	}
+	idx.saveMu.Lock()
+	if idx.saveTimer != nil {
+		idx.saveTimer.Stop()
+	}
+	idx.saveMu.Unlock()
+	idx.saveCache()
+}
+
+// RefreshScene updates the entry of a scene after it was written, deleted
+// or moved, without waiting for the file system event
+func (idx *ProjectIndex) RefreshScene(name string) {
+	idx.mu.Lock()
+	idx.refreshScenePath(filepath.Join(idx.scenesPath, filepath.FromSlash(name)+".scene"))
+	idx.rebuildPrefabs()
+	idx.mu.Unlock()
+	idx.scheduleSave()
+}
+
+// RefreshAsset updates the entries of an asset file or folder after it
+// was written, deleted or moved, without waiting for the file system event
+func (idx *ProjectIndex) RefreshAsset(rel string) {
+	idx.mu.Lock()
+	idx.refreshAssetPath(filepath.Join(idx.assetsPath, filepath.FromSlash(rel)))
+	idx.rebuildAssetNames()
+	idx.mu.Unlock()
+}
+
+// Scenes returns the entries of every scene, sorted by name
+func (idx *ProjectIndex) Scenes() []SceneEntry {
+	idx.mu.RLock()
+	defer idx.mu.RUnlock()
+
+	entries := make([]SceneEntry, 0, len(idx.scenes))
+	for _, entry := range idx.scenes {
+		entries = append(entries, *entry)
+	}
+	slices.SortFunc(entries, func(a, b SceneEntry) int { return strings.Compare(a.Name, b.Name) })
+	return entries
+}
+
+// Scene returns the entry of one scene
+func (idx *ProjectIndex) Scene(name string) (SceneEntry, bool) {
+	idx.mu.RLock()
+	defer idx.mu.RUnlock()
+
+	entry, ok := idx.scenes[name]
+	if !ok {
+		return SceneEntry{}, false
+	}
+	return *entry, true
+}
+
+// SceneNames returns the name of every scene, sorted
+func (idx *ProjectIndex) SceneNames() []string {
+	idx.mu.RLock()
+	defer idx.mu.RUnlock()
+
+	names := make([]string, 0, len(idx.scenes))
+	for name := range idx.scenes {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+	return names
+}
+
+// SceneFolders returns every folder below the scenes directory, sorted
+func (idx *ProjectIndex) SceneFolders() []string {
+	idx.mu.RLock()
+	defer idx.mu.RUnlock()
+
+	folders := make([]string, 0, len(idx.sceneFolders))
+	for folder := range idx.sceneFolders {
+		folders = append(folders, folder)
+	}
+	slices.Sort(folders)
+	return folders
+}
+
+// PrefabScene returns the name of the PREFAB scene with the ID
+func (idx *ProjectIndex) PrefabScene(id string) (string, bool) {
+	idx.mu.RLock()
+	defer idx.mu.RUnlock()
+
+	name, ok := idx.prefabs[id]
+	return name, ok
+}
+
+// Prefabs returns the name of every PREFAB scene by prefab ID
+func (idx *ProjectIndex) Prefabs() map[string]string {
+	idx.mu.RLock()
+	defer idx.mu.RUnlock()
+
+	return maps.Clone(idx.prefabs)
+}
+
+// ScenesUsingPrefab returns the names of the scenes that instantiate the
+// prefab, sorted
+func (idx *ProjectIndex) ScenesUsingPrefab(id string) []string {
+	idx.mu.RLock()
+	defer idx.mu.RUnlock()
+
+	var users []string
+	for name, entry := range idx.scenes {
+		if slices.Contains(entry.Prefabs, id) {
+			users = append(users, name)
+		}
+	}
+	slices.Sort(users)
+	return users
+}
+
+// Assets returns every file under the assets directory, sorted by path
+func (idx *ProjectIndex) Assets() []AssetEntry {
+	idx.mu.RLock()
+	defer idx.mu.RUnlock()
+
+	entries := make([]AssetEntry, 0, len(idx.assets))
+	for _, entry := range idx.assets {
+		entries = append(entries, *entry)
+	}
+	slices.SortFunc(entries, func(a, b AssetEntry) int { return CompareTreePaths(a.Path, b.Path) })
+	return entries
+}
+
+// HasAsset reports whether the asset path, relative to the assets
+// directory, exists
+func (idx *ProjectIndex) HasAsset(rel string) bool {
+	idx.mu.RLock()
+	defer idx.mu.RUnlock()
+
+	_, ok := idx.assets[rel]
+	return ok
+}
+
+// AssetsNamed returns the paths of the assets with the file name, in the
+// order a directory walk would find them
+func (idx *ProjectIndex) AssetsNamed(name string) []string {
+	idx.mu.RLock()
+	defer idx.mu.RUnlock()
+
+	return slices.Clone(idx.assetNames[name])
+}
+
+// refreshScenePath brings the index in line with the file or folder at p
+// in the scenes tree, which may have been created, changed or removed.
+// Callers hold mu and rebuild the prefab lookup afterwards.
+func (idx *ProjectIndex) refreshScenePath(p string) {
+	rel, err := filepath.Rel(idx.scenesPath, p)
+	if err != nil || rel == "." || strings.HasPrefix(rel, "..") {
+		return
+	}
+	rel = filepath.ToSlash(rel)
+	if isIgnoredPath(rel) {
+		return
+	}
+
+	info, statErr := os.Stat(p)
+
+	switch {
+	case statErr != nil:
+		// Removed: the path may have been a scene or a whole folder
+		delete(idx.scenes, strings.TrimSuffix(rel, ".scene"))
+		delete(idx.sceneFolders, rel)
+		for name := range idx.scenes {
+			if strings.HasPrefix(name, rel+"/") {
+				delete(idx.scenes, name)
+			}
+		}
+		for folder := range idx.sceneFolders {
+			if strings.HasPrefix(folder, rel+"/") {
+				delete(idx.sceneFolders, folder)
+			}
+		}
+	case info.IsDir():
+		walkTree(p, func(child string, info os.FileInfo) {
+			childRel, _ := filepath.Rel(idx.scenesPath, child)
+			childRel = filepath.ToSlash(childRel)
+			if info.IsDir() {
+				idx.sceneFolders[childRel] = true
+			} else if filepath.Ext(child) == ".scene" {
+				name := strings.TrimSuffix(childRel, ".scene")
+				idx.scenes[name] = readSceneEntry(name, child, info)
+			}
+		})
+	case filepath.Ext(p) == ".scene":
+		name := strings.TrimSuffix(rel, ".scene")
+		idx.scenes[name] = readSceneEntry(name, p, info)
+		for dir := path.Dir(rel); dir != "."; dir = path.Dir(dir) {
+			idx.sceneFolders[dir] = true
+		}
+	}
+}
+
+// refreshAssetPath brings the index in line with the file or folder at p
+// in the assets tree. Callers hold mu and rebuild the file name lookup
+// afterwards.
+func (idx *ProjectIndex) refreshAssetPath(p string) {
+	rel, err := filepath.Rel(idx.assetsPath, p)
+	if err != nil || rel == "." || strings.HasPrefix(rel, "..") {
+		return
+	}
+	rel = filepath.ToSlash(rel)
+	if isIgnoredPath(rel) {
+		return
+	}
+
+	info, statErr := os.Stat(p)
+
+	switch {
+	case statErr != nil:
+		delete(idx.assets, rel)
+		for assetPath := range idx.assets {
+			if strings.HasPrefix(assetPath, rel+"/") {
+				delete(idx.assets, assetPath)
+			}
+		}
+	case info.IsDir():
+		walkTree(p, func(child string, info os.FileInfo) {
+			if !info.IsDir() {
+				childRel, _ := filepath.Rel(idx.assetsPath, child)
+				childRel = filepath.ToSlash(childRel)
+				idx.assets[childRel] = &AssetEntry{Path: childRel, Size: info.Size(), ModTime: info.ModTime()}
+			}
+		})
+	default:
+		idx.assets[rel] = &AssetEntry{Path: rel, Size: info.Size(), ModTime: info.ModTime()}
+	}
+}
+
+// rebuildLookups recomputes the derived maps. Callers hold mu.
+func (idx *ProjectIndex) rebuildLookups() {
+	idx.rebuildPrefabs()
+	idx.rebuildAssetNames()
+}
+
+func (idx *ProjectIndex) rebuildPrefabs() {
+	idx.prefabs = map[string]string{}
+	for _, name := range slices.Sorted(maps.Keys(idx.scenes)) {
+		entry := idx.scenes[name]
+		if entry.SceneType == "PREFAB" && entry.ID != "" {
+			if _, exists := idx.prefabs[entry.ID]; !exists {
+				idx.prefabs[entry.ID] = name
+			}
+		}
+	}
+}
+
+func (idx *ProjectIndex) rebuildAssetNames() {
+	idx.assetNames = map[string][]string{}
+	for assetPath := range idx.assets {
+		name := path.Base(assetPath)
+		idx.assetNames[name] = append(idx.assetNames[name], assetPath)
+	}
+	for _, paths := range idx.assetNames {
+		slices.SortFunc(paths, CompareTreePaths)
+	}
+}
+
+// readSceneEntry parses a scene file into its index entry
+func readSceneEntry(name, p string, info os.FileInfo) *SceneEntry {
+	entry := &SceneEntry{Name: name, Size: info.Size(), ModTime: info.ModTime(), PreloadPacks: []string{}}
+
+	data, err := os.ReadFile(p)
+	if err != nil {
+		entry.Error = err.Error()
+		return entry
+	}
+	var scene models.Scene
+	if err := json.Unmarshal(data, &scene); err != nil {
+		entry.Error = err.Error()
+		return entry
+	}
+
+	entry.ID = scene.ID
+	entry.SceneType = scene.SceneType
+	entry.SceneKey = scene.Settings.SceneKey
+	if scene.Settings.PreloadPacks != nil {
+		entry.PreloadPacks = scene.Settings.PreloadPacks
+	}
+	if scene.SceneType == "PREFAB" && len(scene.DisplayList) > 0 {
+		root := &scene.DisplayList[0]
+		entry.Root = &RootEntry{
+			ID:         root.ID,
+			Label:      root.Label,
+			Type:       root.Type,
+			PrefabID:   root.PrefabId,
+			Texture:    root.Texture,
+			Components: root.Components,
+		}
+	}
+	scene.WalkObjects(func(obj *models.GameObject) {
+		entry.ObjectCount++
+		if obj.PrefabId != "" && !slices.Contains(entry.Prefabs, obj.PrefabId) {
+			entry.Prefabs = append(entry.Prefabs, obj.PrefabId)
+		}
+	})
+	return entry
+}
+
+func (idx *ProjectIndex) loadCache() map[string]*SceneEntry {
+	cached := map[string]*SceneEntry{}
+	if idx.cachePath == "" {
+		return cached
+	}
+
+	data, err := os.ReadFile(idx.cachePath)
+	if err != nil {
+		return cached
+	}
+	var cache indexCache
+	if err := json.Unmarshal(data, &cache); err != nil || cache.Version != indexCacheVersion {
+		return cached
+	}
+	for _, entry := range cache.Scenes {
+		cached[entry.Name] = entry
+	}
+	return cached
+}
+
+// scheduleSave writes the cache file once changes have settled
+func (idx *ProjectIndex) scheduleSave() {
+	if idx.cachePath == "" {
+		return
+	}
+
+	idx.saveMu.Lock()
+	defer idx.saveMu.Unlock()
+	if idx.saveTimer == nil {
+		idx.saveTimer = time.AfterFunc(indexSaveDelay, idx.saveCache)
+	} else {
+		idx.saveTimer.Reset(indexSaveDelay)
+	}
+}
+
+func (idx *ProjectIndex) saveCache() {
+	if idx.cachePath == "" {
+		return
+	}
+
+	idx.mu.RLock()
+	cache := indexCache{Version: indexCacheVersion, Scenes: make([]*SceneEntry, 0, len(idx.scenes))}
+	for _, entry := range idx.scenes {
+		cache.Scenes = append(cache.Scenes, entry)
+	}
+	data, err := json.Marshal(cache)
+	idx.mu.RUnlock()
+	if err != nil {
+		log.Printf("Project index: %v", err)
+		return
+	}
+
+	if err := os.MkdirAll(filepath.Dir(idx.cachePath), 0755); err != nil {
+		log.Printf("Project index: cache not saved: %v", err)
+		return
+	}
+	if err := WriteFileAtomic(idx.cachePath, data, 0644); err != nil {
+		log.Printf("Project index: cache not saved: %v", err)
+	}
+}
+
+// walkTree calls fn for every file and folder below root, skipping the
+// hidden and temporary entries the file watcher ignores
+func walkTree(root string, fn func(p string, info os.FileInfo)) error {
+	return filepath.Walk(root, func(p string, info os.FileInfo, err error) error {
+		if err != nil {
+			if p == root {
+				return err
+			}
+			return nil
+		}
+		if p == root {
+			return nil
+		}
+		if isIgnoredName(info.Name()) {
+			if info.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		fn(p, info)
+		return nil
+	})
+}
+
+// isIgnoredPath reports whether any element of a slash separated path is
+// ignored by the file watcher
+func isIgnoredPath(rel string) bool {
+	for _, part := range strings.Split(rel, "/") {
+		if isIgnoredName(part) {
+			return true
+		}
+	}
+	return false
+}
+
+// CompareTreePaths orders slash separated paths the way a directory walk
+// visits them, folder by folder
+func CompareTreePaths(a, b string) int {
+	return slices.Compare(strings.Split(a, "/"), strings.Split(b, "/"))
+}
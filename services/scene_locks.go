@@ -0,0 +1,83 @@
+package services
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"path"
+	"slices"
+	"strings"
+	"sync"
+)
+
+// sceneLocks serialises writes per scene name
+type sceneLocks struct {
+	mu    sync.Mutex
+	locks map[string]*sceneLock
+}
+
+type sceneLock struct {
+	sync.Mutex
+	refs int
+}
+
+func (l *sceneLocks) lock(name string) func() {
+	l.mu.Lock()
+	if l.locks == nil {
+		l.locks = make(map[string]*sceneLock)
+	}
+	lock, ok := l.locks[name]
+	if !ok {
+		lock = &sceneLock{}
+		l.locks[name] = lock
+	}
+	lock.refs++
+	l.mu.Unlock()
+
+	lock.Lock()
+
+	return func() {
+		lock.Unlock()
+
+		l.mu.Lock()
+		lock.refs--
+		if lock.refs == 0 {
+			delete(l.locks, name)
+		}
+		l.mu.Unlock()
+	}
+}
+
+// LockScenes blocks until no other request is writing any of the named
+// scenes and returns the function releasing them. Names are locked in
+// sorted order so two requests locking the same pair cannot deadlock.
+func (s *SceneService) LockScenes(names ...string) func() {
+	sorted := make([]string, 0, len(names))
+	for _, name := range names {
+		sorted = append(sorted, lockName(name))
+	}
+	slices.Sort(sorted)
+	sorted = slices.Compact(sorted)
+
+	unlocks := make([]func(), 0, len(sorted))
+	for _, name := range sorted {
+		unlocks = append(unlocks, s.locks.lock(name))
+	}
+
+	return func() {
+		for i := len(unlocks) - 1; i >= 0; i-- {
+			unlocks[i]()
+		}
+	}
+}
+
+// lockName is the file a scene name refers to, so rooms/Town,
+// rooms/./Town and rooms//Town.scene share one lock
+func lockName(name string) string {
+	return strings.TrimSuffix(strings.TrimPrefix(path.Clean("/"+name), "/"), ".scene")
+}
+
+// ETag returns the strong entity tag for a scene file's contents
+func ETag(data []byte) string {
+	sum := sha256.Sum256(data)
+	return `"` + hex.EncodeToString(sum[:16]) + `"`
+}
@@ -1,24 +1,64 @@
 package services
 
 import (
+	"bytes"
 	"encoding/json"
+	"maps"
 	"os"
 	"path/filepath"
+	"slices"
+	"strings"
+
 	"tuxedo-core/models"
 )
 
 type SceneService struct {
 	projectPath string
+	locks       sceneLocks
+	history     *HistoryStore
+	index       *ProjectIndex
+	components  *ComponentRegistry
 }
 
 func NewSceneService(projectPath string) *SceneService {
 	return &SceneService{projectPath: projectPath}
 }
 
-func (s *SceneService) LoadScene(name string) (*models.Scene, error) {
-	scenePath := filepath.Join(s.projectPath, name+".scene")
+// EnableHistory keeps the previous version of a scene in store whenever it
+// is overwritten or deleted
+func (s *SceneService) EnableHistory(store *HistoryStore) {
+	s.history = store
+}
+
+// UseIndex answers scene listings from index and keeps it current when
+// scenes are written, deleted or moved
+func (s *SceneService) UseIndex(index *ProjectIndex) {
+	s.index = index
+}
+
+// UseComponents checks every scene write against the component
+// definitions of registry
+func (s *SceneService) UseComponents(registry *ComponentRegistry) {
+	s.components = registry
+}
 
-	data, err := os.ReadFile(scenePath)
+// Index returns the project index, or nil when none is used
+func (s *SceneService) Index() *ProjectIndex {
+	return s.index
+}
+
+// Root returns the scenes folder
+func (s *SceneService) Root() string {
+	return s.projectPath
+}
+
+// History returns the revision store, or nil when history is disabled
+func (s *SceneService) History() *HistoryStore {
+	return s.history
+}
+
+func (s *SceneService) LoadScene(name string) (*models.Scene, error) {
+	data, err := s.ReadSceneFile(name)
 	if err != nil {
 		return nil, err
 	}
@@ -31,15 +71,113 @@ func (s *SceneService) LoadScene(name string) (*models.Scene, error) {
 	return &scene, nil
 }
 
-func (s *SceneService) SaveScene(name string, scene *models.Scene) error {
-	scenePath := filepath.Join(s.projectPath, name+".scene")
+// ReadSceneFile returns the raw contents of a scene file
+func (s *SceneService) ReadSceneFile(name string) ([]byte, error) {
+	return os.ReadFile(filepath.Join(s.projectPath, name+".scene"))
+}
 
-	prettyJSON, err := json.MarshalIndent(scene, "", "    ")
+func (s *SceneService) SaveScene(name string, scene *models.Scene) error {
+	prettyJSON, err := scene.Encode()
 	if err != nil {
 		return err
 	}
 
-	return os.WriteFile(scenePath, prettyJSON, 0644)
+	return s.WriteSceneFile(name, prettyJSON)
+}
+
+// WriteSceneFile atomically replaces a scene file, creating its folder if
+// needed and recording the previous contents in the history. With a
+// component registry in use, it fails with a *ComponentCheckError when the
+// new contents add component problems.
+func (s *SceneService) WriteSceneFile(name string, data []byte) error {
+	scenePath := s.path(name + ".scene")
+
+	current, err := s.ReadSceneFile(name)
+	if err == nil && bytes.Equal(current, data) {
+		return nil
+	}
+	if err := s.checkComponents(name, current, data); err != nil {
+		return err
+	}
+	if err := s.recordRevision(name); err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(scenePath), 0755); err != nil {
+		return err
+	}
+	if err := WriteFileAtomic(scenePath, data, 0644); err != nil {
+		return err
+	}
+	s.refreshIndex(name)
+	return nil
+}
+
+// WriteSceneFiles replaces several scene files as one change. The files
+// are written in name order; if one fails, those already written get
+// their previous contents back.
+func (s *SceneService) WriteSceneFiles(files map[string][]byte) error {
+	names := slices.Sorted(maps.Keys(files))
+	previous := make(map[string][]byte, len(names))
+	for _, name := range names {
+		data, err := s.ReadSceneFile(name)
+		if err != nil && !os.IsNotExist(err) {
+			return err
+		}
+		previous[name] = data
+	}
+	// Checked up front so a problem in one file writes none of them
+	for _, name := range names {
+		if err := s.checkComponents(name, previous[name], files[name]); err != nil {
+			return err
+		}
+	}
+
+	for i, name := range names {
+		if err := s.WriteSceneFile(name, files[name]); err != nil {
+			for _, written := range names[:i] {
+				if previous[written] == nil {
+					os.Remove(s.path(written + ".scene"))
+				} else {
+					WriteFileAtomic(s.path(written+".scene"), previous[written], 0644)
+				}
+				s.refreshIndex(written)
+			}
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *SceneService) checkComponents(name string, current, data []byte) error {
+	if s.components == nil {
+		return nil
+	}
+	return s.components.checkWrite(name, current, data)
+}
+
+func (s *SceneService) refreshIndex(names ...string) {
+	if s.index == nil {
+		return
+	}
+	for _, name := range names {
+		s.index.RefreshScene(filepath.ToSlash(name))
+	}
+}
+
+// recordRevision copies the current scene file into the history
+func (s *SceneService) recordRevision(name string) error {
+	if s.history == nil {
+		return nil
+	}
+	current, err := s.ReadSceneFile(name)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	_, err = s.history.Save(name, current)
+	return err
 }
 
 func (s *SceneService) ListScenes() ([]string, error) {
@@ -59,3 +197,111 @@ func (s *SceneService) ListScenes() ([]string, error) {
 
 	return scenes, err
 }
+
+// SceneNames lists every scene as a slash separated name without the
+// extension, e.g. rooms/town/Town
+func (s *SceneService) SceneNames() ([]string, error) {
+	if s.index != nil {
+		return s.index.SceneNames(), nil
+	}
+
+	files, err := s.ListScenes()
+	if err != nil {
+		return nil, err
+	}
+
+	names := make([]string, 0, len(files))
+	for _, file := range files {
+		names = append(names, strings.TrimSuffix(filepath.ToSlash(file), ".scene"))
+	}
+	return names, nil
+}
+
+// DeleteScene removes a scene file and its compiled .js output, keeping the
+// last version of the scene in the history so it can be restored. The
+// returned paths are the files removed, relative to the project path.
+func (s *SceneService) DeleteScene(name string) ([]string, error) {
+	if err := s.recordRevision(name); err != nil {
+		return nil, err
+	}
+	if err := os.Remove(s.path(name + ".scene")); err != nil {
+		return nil, err
+	}
+	deleted := []string{name + ".scene"}
+	s.refreshIndex(name)
+
+	if err := os.Remove(s.path(name + ".js")); err == nil {
+		deleted = append(deleted, name+".js")
+	} else if !os.IsNotExist(err) {
+		return deleted, err
+	}
+	return deleted, nil
+}
+
+// MoveScene renames the scene from to the name to, creating folders as
+// needed, and takes the compiled .js output and the history along. It fails with
+// os.ErrExist if a scene already exists at to. The returned paths are the
+// files created, relative to the project path.
+func (s *SceneService) MoveScene(from, to string) ([]string, error) {
+	if err := moveFile(s.path(from+".scene"), s.path(to+".scene")); err != nil {
+		return nil, err
+	}
+	moved := []string{to + ".scene"}
+	s.refreshIndex(from, to)
+
+	if _, err := os.Stat(s.path(from + ".js")); err == nil {
+		if err := moveFile(s.path(from+".js"), s.path(to+".js")); err != nil {
+			return moved, err
+		}
+		moved = append(moved, to+".js")
+	}
+
+	if s.history != nil {
+		if err := s.history.Move(from, to); err != nil {
+			return moved, err
+		}
+	}
+
+	return moved, nil
+}
+
+func (s *SceneService) path(rel string) string {
+	return filepath.Join(s.projectPath, filepath.FromSlash(rel))
+}
+
+// ScenesUsingPrefab returns the names of the scenes containing an instance
+// of the prefab. Scenes that cannot be parsed are skipped.
+func (s *SceneService) ScenesUsingPrefab(prefabID string) ([]string, error) {
+	if s.index != nil {
+		return s.index.ScenesUsingPrefab(prefabID), nil
+	}
+
+	names, err := s.SceneNames()
+	if err != nil {
+		return nil, err
+	}
+
+	var users []string
+	for _, name := range names {
+		scene, err := s.LoadScene(name)
+		if err != nil {
+			continue
+		}
+		if scene.UsesPrefab(prefabID) {
+			users = append(users, name)
+		}
+	}
+	return users, nil
+}
+
+// moveFile renames from to to, creating the target folder and refusing to
+// replace an existing file
+func moveFile(from, to string) error {
+	if _, err := os.Stat(to); err == nil {
+		return os.ErrExist
+	}
+	if err := os.MkdirAll(filepath.Dir(to), 0755); err != nil {
+		return err
+	}
+	return os.Rename(from, to)
+}
@@ -0,0 +1,69 @@
+package services
+
+import (
+	"maps"
+	"path"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"tuxedo-core/models"
+)
+
+// textureFileExts are the asset files a texture snapshot is built from
+var textureFileExts = []string{".json", ".png", ".jpg", ".jpeg", ".gif", ".webp", ".svg"}
+
+// TextureIndex keeps a snapshot of the project's textures, rebuilt from
+// the project index when a pack, atlas or image changes
+type TextureIndex struct {
+	index   *ProjectIndex
+	webRoot string
+
+	mu       sync.Mutex
+	textures *Textures
+	stamps   map[string]fileStamp // texture files the snapshot was built from
+}
+
+type fileStamp struct {
+	size    int64
+	modTime time.Time
+}
+
+// NewTextureIndex creates a texture index over the assets of index. webRoot
+// is the folder pack URLs are relative to.
+func NewTextureIndex(index *ProjectIndex, webRoot string) *TextureIndex {
+	return &TextureIndex{index: index, webRoot: webRoot}
+}
+
+// Textures returns the current snapshot, rebuilding it first if any pack,
+// atlas or image was added, changed or removed since it was built
+func (ti *TextureIndex) Textures() *Textures {
+	stamps := map[string]fileStamp{}
+	var jsonFiles []string
+	for _, asset := range ti.index.Assets() {
+		ext := strings.ToLower(path.Ext(asset.Path))
+		if !slices.Contains(textureFileExts, ext) {
+			continue
+		}
+		stamps[asset.Path] = fileStamp{size: asset.Size, modTime: asset.ModTime}
+		if ext == ".json" {
+			jsonFiles = append(jsonFiles, asset.Path)
+		}
+	}
+
+	ti.mu.Lock()
+	defer ti.mu.Unlock()
+
+	if ti.textures != nil && maps.Equal(stamps, ti.stamps) {
+		return ti.textures
+	}
+
+	packs := map[string]*models.Pack{}
+	for _, pack := range ti.index.Packs() {
+		packs[pack.Path] = pack.Pack
+	}
+	ti.textures = buildTextures(ti.webRoot, ti.index.assetsPath, jsonFiles, packs)
+	ti.stamps = stamps
+	return ti.textures
+}
@@ -0,0 +1,452 @@
+package services
+
+import (
+	"fmt"
+	"image"
+	"os"
+	"path"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+
+	_ "image/gif"
+	_ "image/jpeg"
+	_ "image/png"
+
+	"tuxedo-core/models"
+)
+
+// BaseFrame is the name Phaser gives the frame covering a whole image
+const BaseFrame = "__BASE"
+
+// Textures holds the texture keys the project's asset packs provide,
+// with the files, frames and sizes of each. It is a snapshot: it is not
+// updated when the files change.
+type Textures struct {
+	webRoot    string
+	assetsPath string
+	textures   map[string]*TextureSource
+}
+
+// TextureSource is a texture key and what it is loaded from. Paths are
+// relative to the assets directory.
+type TextureSource struct {
+	Key      string           `json:"key"`
+	Type     string           `json:"type"`              // Pack entry type: image, atlas, multiatlas, spritesheet, ...
+	Pack     string           `json:"pack,omitempty"`    // Pack declaring the key; empty for atlases found outside any pack
+	Section  string           `json:"section,omitempty"` // Section of the pack
+	Entry    *models.PackFile `json:"entry,omitempty"`   // The entry as written in the pack
+	AlsoIn   []string         `json:"alsoIn,omitempty"`  // Other packs declaring the key, which the loader ignores
+	Atlas    string           `json:"atlas,omitempty"`   // Atlas JSON file
+	Images   []string         `json:"images"`            // Image files, one per texture of a multiatlas
+	Size     models.FrameSize `json:"size"`              // Image size of images, frame size of spritesheets; zero when unknown
+	Frames   []TextureFrame   `json:"frames"`            // Atlas and spritesheet frames, in file order
+	Problems []string         `json:"problems,omitempty"`
+
+	base   *TextureFrame  // Whole image of images and spritesheets
+	frames map[string]int // frame name -> index in Frames
+}
+
+// TextureFrame is a frame of a texture and the image it is cut from
+type TextureFrame struct {
+	models.AtlasFrame
+	Image string `json:"image"` // Relative to the assets directory
+}
+
+// HasFrames reports whether the frames of the texture are known, so a
+// frame name can be checked against them
+func (t *TextureSource) HasFrames() bool {
+	return t.frames != nil
+}
+
+// IsImage reports whether the texture is a single image without frames
+func (t *TextureSource) IsImage() bool {
+	return t.Type == models.PackImage || t.Type == models.PackSVG
+}
+
+// Frame returns a frame by name. Images and spritesheets also have the
+// base frame, returned for an empty name.
+func (t *TextureSource) Frame(name string) (*TextureFrame, bool) {
+	if t.base != nil && (name == "" || name == BaseFrame || t.IsImage()) {
+		return t.base, true
+	}
+	if i, ok := t.frames[name]; ok {
+		return &t.Frames[i], true
+	}
+	return nil, false
+}
+
+// LoadTextures reads the pack files under assetsPath and the atlases and
+// images they point to. webRoot is the folder pack URLs are relative to.
+func LoadTextures(webRoot, assetsPath string) (*Textures, error) {
+	var jsonFiles []string
+	packs := map[string]*models.Pack{}
+	err := walkTree(assetsPath, func(p string, info os.FileInfo) {
+		if info.IsDir() || filepath.Ext(p) != ".json" {
+			return
+		}
+		rel, _ := filepath.Rel(assetsPath, p)
+		rel = filepath.ToSlash(rel)
+		jsonFiles = append(jsonFiles, rel)
+		if data, err := os.ReadFile(p); err == nil {
+			if pack, err := models.ParsePack(data); err == nil {
+				packs[rel] = pack
+			}
+		}
+	})
+	if err != nil {
+		return nil, err
+	}
+	slices.SortFunc(jsonFiles, CompareTreePaths)
+	return buildTextures(webRoot, assetsPath, jsonFiles, packs), nil
+}
+
+// buildTextures registers the textures of the packs, in path order, then
+// atlases no pack refers to under their file name
+func buildTextures(webRoot, assetsPath string, jsonFiles []string, packs map[string]*models.Pack) *Textures {
+	t := &Textures{webRoot: webRoot, assetsPath: assetsPath, textures: map[string]*TextureSource{}}
+
+	referenced := map[string]bool{}
+	for _, rel := range jsonFiles {
+		pack, ok := packs[rel]
+		if !ok {
+			continue
+		}
+		for _, section := range pack.Sections {
+			for _, file := range section.Files {
+				if source := t.addFile(rel, &section, file); source != nil && source.Atlas != "" {
+					referenced[source.Atlas] = true
+				}
+			}
+		}
+	}
+
+	for _, rel := range jsonFiles {
+		key := strings.TrimSuffix(path.Base(rel), ".json")
+		if _, isPack := packs[rel]; isPack || referenced[rel] || t.textures[key] != nil {
+			continue
+		}
+		data, err := os.ReadFile(t.assetPath(rel))
+		if err != nil {
+			continue
+		}
+		atlas, err := models.ParseAtlas(data)
+		if err != nil {
+			continue
+		}
+		source := &TextureSource{Key: key, Type: models.PackAtlas, Atlas: rel, Frames: []TextureFrame{}}
+		t.setAtlas(source, atlas, func(image string) string { return path.Join(path.Dir(rel), image) })
+		t.textures[key] = source
+	}
+	return t
+}
+
+// addFile registers a texture declared in a pack. A key already declared
+// by an earlier pack is only noted.
+func (t *Textures) addFile(pack string, section *models.PackSection, file models.PackFile) *TextureSource {
+	if !file.IsTexture() {
+		return nil
+	}
+	key := section.FileKey(&file)
+	if existing, ok := t.textures[key]; ok {
+		if existing.Pack != pack && !slices.Contains(existing.AlsoIn, pack) {
+			existing.AlsoIn = append(existing.AlsoIn, pack)
+		}
+		return nil
+	}
+
+	source := &TextureSource{Key: key, Type: file.Type, Pack: pack, Section: section.Name, Entry: &file, Frames: []TextureFrame{}}
+	t.textures[key] = source
+
+	switch file.Type {
+	case models.PackImage, models.PackSVG:
+		image := t.urlAsset(section.ResolveURL(file.FirstURL()))
+		source.Images = []string{image}
+		if file.Type == models.PackImage {
+			t.setImage(source, image)
+		} else if !t.exists(image) {
+			source.Problems = append(source.Problems, "image "+image+" not found")
+		}
+
+	case models.PackSpritesheet:
+		image := t.urlAsset(section.ResolveURL(file.FirstURL()))
+		source.Images = []string{image}
+		t.setImage(source, image)
+		t.cutSpritesheet(source, file.FrameConfig)
+
+	case models.PackAtlas, models.PackAseprite:
+		source.Atlas = t.urlAsset(section.ResolveURL(file.AtlasURL))
+		atlas := t.readAtlas(source)
+		textureURL := section.ResolveURL(file.TextureURL)
+		t.setAtlas(source, atlas, func(image string) string {
+			if textureURL != "" {
+				return t.urlAsset(textureURL)
+			}
+			return path.Join(path.Dir(source.Atlas), image)
+		})
+
+	case models.PackMultiAtlas:
+		source.Atlas = t.urlAsset(section.ResolveURL(file.FirstURL()))
+		atlas := t.readAtlas(source)
+		t.setAtlas(source, atlas, func(image string) string {
+			if file.Path != "" {
+				return t.urlAsset(section.ResolveURL(strings.TrimSuffix(file.Path, "/") + "/" + image))
+			}
+			return path.Join(path.Dir(source.Atlas), image)
+		})
+
+	default:
+		// XML atlases and bitmap fonts: only the image is known
+		image := t.urlAsset(section.ResolveURL(file.TextureURL))
+		source.Images = []string{image}
+		t.setImage(source, image)
+	}
+	return source
+}
+
+func (t *Textures) readAtlas(source *TextureSource) *models.Atlas {
+	data, err := os.ReadFile(t.assetPath(source.Atlas))
+	if err != nil {
+		source.Problems = append(source.Problems, "atlas "+source.Atlas+" not found")
+		return nil
+	}
+	atlas, err := models.ParseAtlas(data)
+	if err != nil {
+		source.Problems = append(source.Problems, fmt.Sprintf("atlas %s cannot be read: %v", source.Atlas, err))
+		return nil
+	}
+	return atlas
+}
+
+// setAtlas adds the frames of an atlas. imagePath maps the image name in
+// the atlas to the image file.
+func (t *Textures) setAtlas(source *TextureSource, atlas *models.Atlas, imagePath func(image string) string) {
+	source.Images = []string{}
+	if atlas == nil {
+		return
+	}
+	source.frames = map[string]int{}
+	for _, texture := range atlas.Textures {
+		image := imagePath(texture.Image)
+		source.Images = append(source.Images, image)
+		if !t.exists(image) {
+			source.Problems = append(source.Problems, "image "+image+" not found")
+		}
+		for _, frame := range texture.Frames {
+			if _, ok := source.frames[frame.Name]; !ok {
+				source.frames[frame.Name] = len(source.Frames)
+				source.Frames = append(source.Frames, TextureFrame{AtlasFrame: frame, Image: image})
+			}
+		}
+	}
+}
+
+// setImage reads the size of a single image texture and sets its base frame
+func (t *Textures) setImage(source *TextureSource, image string) {
+	size, err := t.imageSize(image)
+	if err != nil {
+		source.Problems = append(source.Problems, err.Error())
+		return
+	}
+	source.Size = size
+	source.base = &TextureFrame{AtlasFrame: untrimmedFrame(BaseFrame, models.FrameRect{W: size.W, H: size.H}), Image: image}
+}
+
+// cutSpritesheet adds the frames of a spritesheet, numbered from 0, left to
+// right and top to bottom, as the loader cuts them
+func (t *Textures) cutSpritesheet(source *TextureSource, config *models.PackFrameConfig) {
+	if config == nil || config.FrameWidth <= 0 || config.FrameHeight <= 0 {
+		source.Problems = append(source.Problems, "spritesheet has no frame size")
+		return
+	}
+	if source.base == nil {
+		return
+	}
+	imageSize := source.Size
+	source.Size = models.FrameSize{W: config.FrameWidth, H: config.FrameHeight}
+
+	margin, spacing := intValue(config.Margin), intValue(config.Spacing)
+	columns := (imageSize.W - 2*margin + spacing) / (config.FrameWidth + spacing)
+	rows := (imageSize.H - 2*margin + spacing) / (config.FrameHeight + spacing)
+	total := max(columns*rows, 0)
+	start := min(intValue(config.StartFrame), total)
+	end := total - 1
+	if config.EndFrame != nil && *config.EndFrame >= 0 && *config.EndFrame < total {
+		end = *config.EndFrame
+	}
+
+	source.frames = map[string]int{}
+	for i := start; i <= end; i++ {
+		rect := models.FrameRect{
+			X: margin + (i%columns)*(config.FrameWidth+spacing),
+			Y: margin + (i/columns)*(config.FrameHeight+spacing),
+			W: config.FrameWidth,
+			H: config.FrameHeight,
+		}
+		name := strconv.Itoa(i - start)
+		source.frames[name] = len(source.Frames)
+		source.Frames = append(source.Frames, TextureFrame{AtlasFrame: untrimmedFrame(name, rect), Image: source.base.Image})
+	}
+}
+
+func untrimmedFrame(name string, rect models.FrameRect) models.AtlasFrame {
+	return models.AtlasFrame{
+		Name:             name,
+		Frame:            rect,
+		SpriteSourceSize: models.FrameRect{W: rect.W, H: rect.H},
+		SourceSize:       models.FrameSize{W: rect.W, H: rect.H},
+	}
+}
+
+func intValue(v *int) int {
+	if v == nil {
+		return 0
+	}
+	return *v
+}
+
+func (t *Textures) imageSize(rel string) (models.FrameSize, error) {
+	f, err := os.Open(t.assetPath(rel))
+	if err != nil {
+		return models.FrameSize{}, fmt.Errorf("image %s not found", rel)
+	}
+	defer f.Close()
+	config, _, err := image.DecodeConfig(f)
+	if err != nil {
+		return models.FrameSize{}, fmt.Errorf("image %s cannot be read: %v", rel, err)
+	}
+	return models.FrameSize{W: config.Width, H: config.Height}, nil
+}
+
+func (t *Textures) exists(rel string) bool {
+	_, err := os.Stat(t.assetPath(rel))
+	return err == nil
+}
+
+// urlAsset converts a loader URL to a path relative to the assets directory
+func (t *Textures) urlAsset(url string) string {
+	return urlAssetPath(t.webRoot, t.assetsPath, url)
+}
+
+func urlAssetPath(webRoot, assetsPath, url string) string {
+	full := filepath.Join(webRoot, filepath.FromSlash(strings.TrimPrefix(url, "/")))
+	rel, err := filepath.Rel(assetsPath, full)
+	if err != nil {
+		return url
+	}
+	return filepath.ToSlash(rel)
+}
+
+func (t *Textures) assetPath(rel string) string {
+	return filepath.Join(t.assetsPath, filepath.FromSlash(rel))
+}
+
+// Texture returns the source of a texture key
+func (t *Textures) Texture(key string) (*TextureSource, bool) {
+	source, ok := t.textures[key]
+	return source, ok
+}
+
+// Keys returns every texture key, sorted
+func (t *Textures) Keys() []string {
+	keys := make([]string, 0, len(t.textures))
+	for key := range t.textures {
+		keys = append(keys, key)
+	}
+	slices.Sort(keys)
+	return keys
+}
+
+// Frame returns the frame an object shows for a texture key and frame
+// name
+func (t *Textures) Frame(key, frame string) (models.AtlasFrame, error) {
+	source, ok := t.textures[key]
+	if !ok {
+		return models.AtlasFrame{}, fmt.Errorf("texture %s not found", key)
+	}
+	found, ok := source.Frame(frame)
+	if ok {
+		return found.AtlasFrame, nil
+	}
+	if len(source.Problems) > 0 {
+		return models.AtlasFrame{}, fmt.Errorf("texture %s: %s", key, source.Problems[0])
+	}
+	if !source.HasFrames() {
+		return models.AtlasFrame{}, fmt.Errorf("size of texture %s is unknown", key)
+	}
+	return models.AtlasFrame{}, fmt.Errorf("frame %s not found in texture %s", frame, key)
+}
+
+// maxSuggestions caps the keys suggested for an unknown texture key
+const maxSuggestions = 5
+
+// FrameRef names a frame of a texture
+type FrameRef struct {
+	Key   string `json:"key"`
+	Frame string `json:"frame"`
+}
+
+// Suggest returns the texture keys closest to an unknown key, best first,
+// and the frames named like it, for a frame name used as a key. Keys match
+// when they differ only in case, contain one another or are a few edits
+// apart.
+func (t *Textures) Suggest(key string) ([]string, []FrameRef) {
+	type candidate struct {
+		key      string
+		distance int
+	}
+	lower := strings.ToLower(key)
+	limit := max(2, len(key)/3)
+
+	var candidates []candidate
+	var frames []FrameRef
+	for _, other := range t.Keys() {
+		otherLower := strings.ToLower(other)
+		distance := editDistance(lower, otherLower)
+		switch {
+		case otherLower == lower:
+			distance = 0
+		case strings.Contains(otherLower, lower) || strings.Contains(lower, otherLower):
+			distance = min(distance, limit)
+		}
+		if distance <= limit {
+			candidates = append(candidates, candidate{other, distance})
+		}
+
+		if source := t.textures[other]; source.frames != nil {
+			if _, ok := source.frames[key]; ok {
+				frames = append(frames, FrameRef{Key: other, Frame: key})
+			}
+		}
+	}
+
+	slices.SortStableFunc(candidates, func(a, b candidate) int { return a.distance - b.distance })
+	suggestions := []string{}
+	for _, c := range candidates[:min(len(candidates), maxSuggestions)] {
+		suggestions = append(suggestions, c.key)
+	}
+	return suggestions, frames
+}
+
+// editDistance is the Levenshtein distance between a and b, in runes
+func editDistance(a, b string) int {
+	ra, rb := []rune(a), []rune(b)
+	previous := make([]int, len(rb)+1)
+	current := make([]int, len(rb)+1)
+	for j := range previous {
+		previous[j] = j
+	}
+	for i := 1; i <= len(ra); i++ {
+		current[0] = i
+		for j := 1; j <= len(rb); j++ {
+			cost := 1
+			if ra[i-1] == rb[j-1] {
+				cost = 0
+			}
+			current[j] = min(previous[j]+1, current[j-1]+1, previous[j-1]+cost)
+		}
+		previous, current = current, previous
+	}
+	return previous[len(rb)]
+}
@@ -0,0 +1,70 @@
+package validation
+
+import (
+	"path/filepath"
+	"strings"
+
+	"tuxedo-core/models"
+	"tuxedo-core/services"
+)
+
+// Project is what scenes are checked against: the prefabs, the texture
+// keys the assets provide and the component definitions
+type Project struct {
+	webRoot    string
+	prefabs    map[string]*models.Scene
+	textures   *services.Textures
+	components *services.ComponentRegistry
+}
+
+// LoadProject loads the prefabs the scene service lists, from its index
+// when it uses one. webRoot is the folder pack URLs such as
+// assets/media/... are relative to. components may be nil when the
+// definitions are not known.
+func LoadProject(scenes *services.SceneService, webRoot string, textures *services.Textures, components *services.ComponentRegistry) (*Project, error) {
+	p := &Project{
+		webRoot:    webRoot,
+		prefabs:    map[string]*models.Scene{},
+		textures:   textures,
+		components: components,
+	}
+
+	names, err := scenes.Prefabs()
+	if err != nil {
+		return nil, err
+	}
+	for id, name := range names {
+		scene, err := scenes.LoadScene(name)
+		if err != nil {
+			continue // Reported when the scene itself is validated
+		}
+		p.prefabs[id] = scene
+	}
+
+	return p, nil
+}
+
+// urlPath converts a loader URL to a file path
+func (p *Project) urlPath(url string) string {
+	return filepath.Join(p.webRoot, filepath.FromSlash(strings.TrimPrefix(url, "/")))
+}
+
+// prefabRootType returns the type of a prefab's root object, following
+// variants to the prefab they are based on. It is "" when a prefab of the
+// chain is missing or the chain is a cycle.
+func (p *Project) prefabRootType(id string) string {
+	seen := map[string]bool{}
+	for !seen[id] {
+		seen[id] = true
+		prefab := p.prefabs[id]
+		if prefab == nil || len(prefab.DisplayList) == 0 {
+			return ""
+		}
+		root := &prefab.DisplayList[0]
+		if root.PrefabId == "" {
+			return root.Type
+		}
+		id = root.PrefabId
+	}
+	return ""
+}
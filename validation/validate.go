@@ -0,0 +1,300 @@
+// Package validation checks scenes for broken references that Phaser Editor
+// would otherwise only reveal at runtime: duplicate object IDs, lists,
+// masks and prefab instances pointing at nothing, unknown textures and
+// frames, and missing preload packs.
+package validation
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"slices"
+	"strings"
+
+	"tuxedo-core/models"
+	"tuxedo-core/services"
+)
+
+// Severity of a diagnostic. Errors break the scene at runtime, warnings are
+// suspicious but harmless.
+type Severity string
+
+const (
+	SeverityError   Severity = "error"
+	SeverityWarning Severity = "warning"
+)
+
+// Diagnostic is a single problem found in a scene
+type Diagnostic struct {
+	Severity Severity `json:"severity"`
+	Code     string   `json:"code"`
+	Message  string   `json:"message"`
+	Scene    string   `json:"scene"`
+	ObjectID string   `json:"objectId,omitempty"`
+	Path     string   `json:"path,omitempty"` // JSON pointer into the scene file
+}
+
+// Report collects the diagnostics of one or more scenes
+type Report struct {
+	Scenes      int          `json:"scenes"`
+	Errors      int          `json:"errors"`
+	Warnings    int          `json:"warnings"`
+	Diagnostics []Diagnostic `json:"diagnostics"`
+}
+
+func (r *Report) add(d Diagnostic) {
+	switch d.Severity {
+	case SeverityError:
+		r.Errors++
+	case SeverityWarning:
+		r.Warnings++
+	}
+	r.Diagnostics = append(r.Diagnostics, d)
+}
+
+// ValidateProject checks every scene in the project
+func ValidateProject(scenes *services.SceneService, project *Project) (*Report, error) {
+	names, err := scenes.SceneNames()
+	if err != nil {
+		return nil, err
+	}
+
+	report := &Report{Diagnostics: []Diagnostic{}}
+	for _, name := range names {
+		if err := validateInto(report, scenes, project, name); err != nil {
+			return nil, err
+		}
+	}
+	return report, nil
+}
+
+// ValidateScene checks a single scene. It fails with fs.ErrNotExist if the
+// scene does not exist.
+func ValidateScene(scenes *services.SceneService, project *Project, name string) (*Report, error) {
+	report := &Report{Diagnostics: []Diagnostic{}}
+	if err := validateInto(report, scenes, project, name); err != nil {
+		return nil, err
+	}
+	return report, nil
+}
+
+func validateInto(report *Report, scenes *services.SceneService, project *Project, name string) error {
+	data, err := scenes.ReadSceneFile(name)
+	if err != nil {
+		return err
+	}
+	report.Scenes++
+
+	var scene models.Scene
+	if err := json.Unmarshal(data, &scene); err != nil {
+		report.add(Diagnostic{
+			Severity: SeverityError,
+			Code:     "invalid-scene",
+			Message:  err.Error(),
+			Scene:    name,
+		})
+		return nil
+	}
+
+	v := &validator{project: project, name: name, scene: &scene, report: report, ids: map[string]string{}}
+	v.validate()
+	return nil
+}
+
+type validator struct {
+	project *Project
+	name    string
+	scene   *models.Scene
+	report  *Report
+	ids     map[string]string // object ID -> path of its first occurrence
+}
+
+func (v *validator) errorf(code, objectID, path, format string, args ...any) {
+	v.diagnostic(SeverityError, code, objectID, path, format, args...)
+}
+
+func (v *validator) warnf(code, objectID, path, format string, args ...any) {
+	v.diagnostic(SeverityWarning, code, objectID, path, format, args...)
+}
+
+func (v *validator) diagnostic(severity Severity, code, objectID, path, format string, args ...any) {
+	v.report.add(Diagnostic{
+		Severity: severity,
+		Code:     code,
+		Message:  fmt.Sprintf(format, args...),
+		Scene:    v.name,
+		ObjectID: objectID,
+		Path:     path,
+	})
+}
+
+func (v *validator) validate() {
+	for i, pack := range v.scene.Settings.PreloadPacks {
+		if _, err := os.Stat(v.project.urlPath(pack)); err != nil {
+			v.errorf("missing-preload-pack", "", fmt.Sprintf("/settings/preloadPackFiles/%d", i),
+				"Preload pack %s does not exist", pack)
+		}
+	}
+
+	if v.scene.SceneType == "PREFAB" && len(v.scene.DisplayList) == 0 {
+		v.errorf("empty-prefab", "", "/displayList", "Prefab has no root object")
+	}
+
+	v.validateObjects(v.scene.DisplayList, "/displayList")
+
+	if objects, err := v.scene.TypedObjects(); err != nil {
+		v.errorf("invalid-object", "", "/displayList", "Objects do not match the fields of their type: %v", err)
+	} else {
+		byID := map[string]models.Object{}
+		models.WalkTyped(objects, func(obj models.Object) { byID[obj.Base().ID] = obj })
+		v.validateMasks(objects, byID, "/displayList")
+	}
+
+	for i, list := range v.scene.Lists {
+		for j, id := range list.ObjectIDs {
+			if _, ok := v.ids[id]; !ok {
+				v.errorf("list-missing-object", id, fmt.Sprintf("/lists/%d/objectIds/%d", i, j),
+					"List %s refers to object %s, which is not in the scene", list.Label, id)
+			}
+		}
+	}
+}
+
+func (v *validator) validateObjects(objects []models.GameObject, path string) {
+	for i := range objects {
+		objPath := fmt.Sprintf("%s/%d", path, i)
+		v.validateObject(&objects[i], objPath)
+		v.validateObjects(objects[i].List, objPath+"/list")
+	}
+}
+
+func (v *validator) validateObject(obj *models.GameObject, path string) {
+	if first, ok := v.ids[obj.ID]; ok {
+		v.errorf("duplicate-object-id", obj.ID, path, "Object ID %s is already used at %s", obj.ID, first)
+	} else {
+		v.ids[obj.ID] = path
+	}
+
+	var prefab *models.Scene
+	if obj.PrefabId != "" {
+		prefab = v.project.prefabs[obj.PrefabId]
+		if prefab == nil {
+			v.errorf("missing-prefab", obj.ID, path+"/prefabId", "Prefab %s does not exist", obj.PrefabId)
+		}
+	}
+
+	// Prefab instances only use their own texture when it is unlocked
+	if obj.Texture != nil && obj.Texture.Key != "" && (obj.PrefabId == "" || slices.Contains(obj.Unlock, "texture")) {
+		v.validateTexture(obj, path+"/texture")
+	}
+
+	for i, property := range obj.Unlock {
+		if !v.knownUnlock(obj, prefab, property) {
+			v.warnf("unknown-unlock-property", obj.ID, fmt.Sprintf("%s/unlock/%d", path, i),
+				"Unlocked property %s is not a known object or component property", property)
+		}
+	}
+}
+
+func (v *validator) validateTexture(obj *models.GameObject, path string) {
+	key, frame := obj.Texture.Key, obj.Texture.Frame
+
+	tex, ok := v.project.textures.Texture(key)
+	if !ok {
+		v.errorf("missing-texture", obj.ID, path+"/key", "Texture %s is not in any asset pack", key)
+		return
+	}
+
+	switch {
+	case tex.IsImage():
+		if frame != "" && frame != services.BaseFrame {
+			v.errorf("missing-frame", obj.ID, path+"/frame", "Image %s has no frame %s", key, frame)
+		}
+	case tex.HasFrames() && frame != "":
+		if _, ok := tex.Frame(frame); !ok {
+			kind := "Atlas"
+			if tex.Type == models.PackSpritesheet {
+				kind = "Spritesheet"
+			}
+			v.errorf("missing-frame", obj.ID, path+"/frame", "%s %s has no frame %s", kind, key, frame)
+		}
+	}
+}
+
+// validateMasks checks that masks refer to an object of the scene that can
+// serve as that kind of mask
+func (v *validator) validateMasks(objects models.Objects, byID map[string]models.Object, path string) {
+	for i, obj := range objects {
+		objPath := fmt.Sprintf("%s/%d", path, i)
+		if mask := obj.Base().Mask; mask != nil {
+			v.validateMask(obj.Base().ID, mask, byID, objPath+"/mask")
+		}
+		v.validateMasks(models.Children(obj), byID, objPath+"/list")
+	}
+}
+
+func (v *validator) validateMask(id string, mask *models.Mask, byID map[string]models.Object, path string) {
+	target, ok := byID[mask.ObjectID]
+	if !ok || mask.ObjectID == id {
+		v.errorf("invalid-mask", id, path+"/objectId", "Mask object %s is not another object of the scene", mask.ObjectID)
+		return
+	}
+	// An instance can serve as a mask its prefab's root type can serve as
+	if instance, ok := target.(*models.Instance); ok {
+		if typed, err := instance.As(v.project.prefabRootType(instance.PrefabId)); err == nil {
+			target = typed
+		}
+	}
+
+	switch mask.Type {
+	case "geometry":
+		switch target.(type) {
+		case *models.Rectangle, *models.Ellipse, *models.Polygon:
+		default:
+			v.errorf("invalid-mask", id, path+"/objectId", "Geometry mask %s is not a Rectangle, Ellipse or Polygon", mask.ObjectID)
+		}
+	case "bitmap":
+		switch target.(type) {
+		case *models.Image, *models.Sprite, *models.TileSprite, *models.NineSlice:
+		default:
+			v.errorf("invalid-mask", id, path+"/objectId", "Bitmap mask %s is not a textured object", mask.ObjectID)
+		}
+	default:
+		v.errorf("invalid-mask", id, path+"/type", "Mask type must be geometry or bitmap, not %q", mask.Type)
+	}
+}
+
+// knownUnlock reports whether property names an object property, or a
+// property of a component the instance or its prefab uses. Properties of
+// components the project defines must be in the definition.
+func (v *validator) knownUnlock(obj *models.GameObject, prefab *models.Scene, property string) bool {
+	if models.IsObjectProperty(property) {
+		return true
+	}
+	component, name, ok := strings.Cut(property, ".")
+	if !ok {
+		return false
+	}
+
+	if v.project.components != nil {
+		if definition, ok := v.project.components.Definition(component); ok {
+			_, ok := definition.Property(name)
+			return ok
+		}
+	}
+	if obj.HasComponent(component) {
+		return true
+	}
+
+	// Follow the prefab chain, a variant inherits its parent's components
+	seen := map[string]bool{}
+	for prefab != nil && len(prefab.DisplayList) > 0 && !seen[prefab.ID] {
+		seen[prefab.ID] = true
+		root := &prefab.DisplayList[0]
+		if root.HasComponent(component) {
+			return true
+		}
+		prefab = v.project.prefabs[root.PrefabId]
+	}
+	return false
+}